@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/internal/bandwidth"
+	"github.com/0x0FACED/rapid/internal/lan/client"
+	"github.com/0x0FACED/rapid/internal/lan/mdnss"
+	"github.com/0x0FACED/rapid/internal/lan/server"
+	"github.com/0x0FACED/rapid/internal/mirror"
+	"github.com/0x0FACED/rapid/pkg/generator"
+	"github.com/google/uuid"
+)
+
+// rapid daemon [-addr 0.0.0.0:8070] [-dir ./test-dir] [-accept] [-token ""] [-jobs jobs.json] [-once] [-dry-run] [-upload 0] [-download 0] [-web-upload 0] [-web-quota 0]
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	addr := fs.String("addr", "0.0.0.0:8070", "address of LAN server")
+	dir := fs.String("dir", "./test-dir", "downloads directory")
+	name := fs.String("name", "", "instance name, generated if empty")
+	accept := fs.Bool("accept", false, "accept folders mirrored by peers")
+	token := fs.String("token", "", "sync token that peers send to mirror folders, generated if empty")
+	jobsFile := fs.String("jobs", "", "JSON file with sync jobs")
+	once := fs.Bool("once", false, "run every job once and exit")
+	dryRun := fs.Bool("dry-run", false, "print what jobs would sync and exit")
+	upload := fs.Int64("upload", 0, "upload limit in KiB/s, 0 means unlimited")
+	download := fs.Int64("download", 0, "download limit in KiB/s, 0 means unlimited")
+	webUpload := fs.Int64("web-upload", 0, "largest file browsers can upload in MiB, 0 means 4096")
+	webQuota := fs.Int64("web-quota", 0, "total size of uploads of one browser session in MiB, 0 means 16384")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	jobs, err := loadJobs(*jobsFile)
+	if err != nil {
+		return err
+	}
+
+	bw := bandwidth.New(configs.BandwidthConfig{Upload: *upload * 1024, Download: *download * 1024})
+	c := client.New(nil)
+	c.SetBandwidth(bw)
+	resolve := func(job *mirror.Job) (mirror.Peer, error) {
+		return c.MirrorPeer(job.Peer, job.Token), nil
+	}
+
+	if *once || *dryRun {
+		return runJobsOnce(jobs, resolve, *dryRun)
+	}
+
+	_, portStr, err := net.SplitHostPort(*addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+
+	if *name == "" {
+		*name, err = generator.GenerateName()
+		if err != nil {
+			*name = uuid.NewString()
+		}
+	}
+	if _, err := mdnss.New(*name, port); err != nil {
+		return err
+	}
+
+	s := server.New(configs.LANServerConfig{
+		Address:         *addr,
+		DownloadsDir:    *dir,
+		WebUploadLimit:  *webUpload << 20,
+		WebSessionQuota: *webQuota << 20,
+	})
+	// server and client share limits, so totals cover both directions of traffic
+	s.Bandwidth().SetConfig(bw.Config())
+	c.SetBandwidth(s.Bandwidth())
+	if *accept {
+		if *token == "" {
+			*token = mirror.NewToken()
+		}
+		s.SetMirrorStore(mirror.NewStore(filepath.Join(*dir, mirror.DefaultDir)), *token)
+	}
+	go func() {
+		if err := s.Start(); err != nil {
+			log.Fatalln(err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, job := range jobs {
+		go job.Schedule(ctx, resolve)
+	}
+
+	fmt.Printf("Daemon %s is running, %d sync jobs\n", *name, len(jobs))
+	if *accept {
+		fmt.Println("Sync token:", *token)
+	}
+	_, pin := s.WebAccess()
+	for _, u := range s.WebURLs() {
+		fmt.Printf("Browser access: %s (PIN %s)\n", u, pin)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// loadJobs reads list of jobs, peer of every job is LAN address
+func loadJobs(name string) ([]*mirror.Job, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*mirror.Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("invalid jobs file: %w", err)
+	}
+	for _, job := range jobs {
+		if err := job.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+func runJobsOnce(jobs []*mirror.Job, resolve func(*mirror.Job) (mirror.Peer, error), dryRun bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, job := range jobs {
+		peer, err := resolve(job)
+		if err != nil {
+			return err
+		}
+
+		plan, err := job.Run(ctx, peer, dryRun)
+		fmt.Printf("Job %s -> %s/%s:\n%s", job.Name, job.Peer, job.Folder, plan)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+	}
+	return nil
+}
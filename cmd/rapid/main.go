@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 
 	"fyne.io/fyne/v2/app"
 	"github.com/0x0FACED/rapid/configs"
@@ -17,6 +18,11 @@ import (
 
 // TODO: refactor
 func main() {
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	var name string
 	var err error
 	name, err = generator.GenerateName()
@@ -32,6 +38,7 @@ func main() {
 
 	c := client.New(mdnss)
 	s := server.New(configs.LANServerConfig{Address: "0.0.0.0:8070", DownloadsDir: "./test-dir"})
+	c.SetBandwidth(s.Bandwidth())
 	go s.Start()
 
 	lanController := controller.NewLANController(c, s, name)
@@ -42,7 +49,31 @@ func main() {
 		return
 	}
 
+	chatController := controller.NewChatController(c, s, lanController, netController, name)
+	folderShare := controller.NewFolderShare(s, lanController, netController)
+	folderSync := controller.NewFolderSyncController(c, s, lanController, netController)
+
 	fyneApp := app.NewWithID(name)
-	app := rapid.New(s, c, lanController, netController, fyneApp)
+	app := rapid.New(s, c, lanController, netController, chatController, folderShare, folderSync, fyneApp)
 	app.Start()
 }
+
+// runCommand runs headless subcommands
+func runCommand(name string, args []string) {
+	var err error
+	switch name {
+	case "signal":
+		err = runSignal(args)
+	case "relay":
+		err = runRelay(args)
+	case "daemon":
+		err = runDaemon(args)
+	default:
+		fmt.Println("Unknown command:", name)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/internal/p2p/relay"
+)
+
+// userFlag collects repeated -user name=password
+type userFlag map[string]string
+
+func (u userFlag) String() string {
+	names := make([]string, 0, len(u))
+	for name := range u {
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (u userFlag) Set(value string) error {
+	name, password, ok := strings.Cut(value, "=")
+	if !ok || name == "" || password == "" {
+		return fmt.Errorf("expected name=password, got %q", value)
+	}
+	u[name] = password
+	return nil
+}
+
+// rapid relay -public-ip 1.2.3.4 -user alice=secret [-ports 50000-50100] [-rate 1000000]
+func runRelay(args []string) error {
+	users := userFlag{}
+
+	fs := flag.NewFlagSet("relay", flag.ExitOnError)
+	addr := fs.String("addr", "0.0.0.0:3478", "UDP and TCP address to listen on")
+	publicIP := fs.String("public-ip", "", "IP address that clients use to reach the relay")
+	realm := fs.String("realm", "rapid", "TURN realm")
+	ports := fs.String("ports", "", "relay port range, for example 50000-50100")
+	rate := fs.Int64("rate", 0, "bytes per second for one allocation, 0 is unlimited")
+	totalRate := fs.Int64("total-rate", 0, "bytes per second for whole relay, 0 is unlimited")
+	quota := fs.Int64("quota", 0, "max bytes relayed by one allocation, 0 is unlimited")
+	tlsAddr := fs.String("tls-addr", "0.0.0.0:5349", "TLS address, used with -cert and -key")
+	cert := fs.String("cert", "", "TLS certificate file")
+	key := fs.String("key", "", "TLS key file")
+	fs.Var(users, "user", "credentials as name=password, can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	minPort, maxPort, err := relay.ParsePortRange(*ports)
+	if err != nil {
+		return err
+	}
+
+	r, err := relay.New(configs.RelayConfig{
+		Address:         *addr,
+		PublicIP:        *publicIP,
+		Realm:           *realm,
+		Users:           users,
+		MinPort:         minPort,
+		MaxPort:         maxPort,
+		AllocationRate:  *rate,
+		TotalRate:       *totalRate,
+		AllocationQuota: *quota,
+		TLSAddress:      *tlsAddr,
+		TLSCertFile:     *cert,
+		TLSKeyFile:      *key,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := r.Start(); err != nil {
+		return err
+	}
+	defer r.Close()
+
+	fmt.Println("Add these lines to ICE servers in Options:")
+	for name := range users {
+		fmt.Print(configs.FormatICEServers(r.ICEServers(name)))
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/internal/p2p/signaling"
+)
+
+// rapid signal [-addr :8090] [-max-rooms 1024]
+func runSignal(args []string) error {
+	fs := flag.NewFlagSet("signal", flag.ExitOnError)
+	addr := fs.String("addr", ":8090", "address to listen on")
+	maxRooms := fs.Int("max-rooms", 1024, "max number of rooms at the same time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s := signaling.NewServer(configs.SignalingConfig{
+		Address:  *addr,
+		MaxRooms: *maxRooms,
+	})
+	return s.Start()
+}
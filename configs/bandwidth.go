@@ -0,0 +1,69 @@
+package configs
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// BandwidthConfig limits transfers, rates are bytes per second and 0 means unlimited
+type BandwidthConfig struct {
+	// all peers together
+	Upload   int64
+	Download int64
+	// every peer that has no own limits
+	PeerUpload   int64
+	PeerDownload int64
+	// limits of particular peers, key is IP for LAN and peer name for WebRTC
+	Peers map[string]PeerBandwidth
+}
+
+type PeerBandwidth struct {
+	Upload   int64
+	Download int64
+}
+
+// ParsePeerBandwidth parses one peer per line: "peer upload download" in KiB/s,
+// for example "192.168.1.20 512 0". Empty lines and lines starting with # are skipped.
+func ParsePeerBandwidth(text string) (map[string]PeerBandwidth, error) {
+	peers := make(map[string]PeerBandwidth)
+
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected \"peer upload download\"", i+1)
+		}
+
+		upload, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || upload < 0 {
+			return nil, fmt.Errorf("line %d: invalid upload rate %q", i+1, fields[1])
+		}
+		download, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || download < 0 {
+			return nil, fmt.Errorf("line %d: invalid download rate %q", i+1, fields[2])
+		}
+
+		peers[fields[0]] = PeerBandwidth{Upload: upload * 1024, Download: download * 1024}
+	}
+
+	return peers, nil
+}
+
+// FormatPeerBandwidth is the reverse of ParsePeerBandwidth
+func FormatPeerBandwidth(peers map[string]PeerBandwidth) string {
+	var builder strings.Builder
+
+	for _, name := range slices.Sorted(maps.Keys(peers)) {
+		peer := peers[name]
+		fmt.Fprintf(&builder, "%s %d %d\n", name, peer.Upload/1024, peer.Download/1024)
+	}
+
+	return builder.String()
+}
@@ -3,4 +3,40 @@ package configs
 type LANServerConfig struct {
 	Address      string
 	DownloadsDir string
+	// limits of browser uploads in bytes, 0 means default: size of
+	// one file and total size of files uploaded in one browser session
+	WebUploadLimit  int64
+	WebSessionQuota int64
+}
+
+type SignalingConfig struct {
+	Address string
+	// max number of rooms at the same time
+	MaxRooms int
+}
+
+type RelayConfig struct {
+	// UDP and TCP listen address, for example "0.0.0.0:3478"
+	Address string
+	// IP that clients use to reach relayed ports
+	PublicIP string
+	Realm    string
+	// username -> password
+	Users map[string]string
+
+	// port range for relayed allocations, 0 means any port
+	MinPort uint16
+	MaxPort uint16
+
+	// bytes per second for one allocation, 0 means unlimited
+	AllocationRate int64
+	// bytes per second for all allocations together, 0 means unlimited
+	TotalRate int64
+	// max bytes relayed by one allocation, 0 means unlimited
+	AllocationQuota int64
+
+	// optional, enables turns: on TLSAddress
+	TLSAddress  string
+	TLSCertFile string
+	TLSKeyFile  string
 }
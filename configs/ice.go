@@ -0,0 +1,85 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ICEServerConfig struct {
+	URLs []string
+	// required for turn: and turns: urls
+	Username   string
+	Credential string
+}
+
+type ICEConfig struct {
+	Servers []ICEServerConfig
+	// only host candidates, servers are ignored
+	LANOnly bool
+	// only relay candidates, traffic always goes through TURN
+	RelayOnly bool
+}
+
+// DefaultICEConfig returns free public STUN servers without TURN
+func DefaultICEConfig() ICEConfig {
+	return ICEConfig{
+		Servers: []ICEServerConfig{
+			{URLs: []string{"stun:stun.l.google.com:19302"}},
+			{URLs: []string{"stun:stun.l.google.com:5349"}},
+			{URLs: []string{"stun:stun1.l.google.com:3478"}},
+			{URLs: []string{"stun:stun1.l.google.com:5349"}},
+			{URLs: []string{"stun:stun2.l.google.com:19302"}},
+			{URLs: []string{"stun:stun2.l.google.com:5349"}},
+			{URLs: []string{"stun:stun3.l.google.com:3478"}},
+			{URLs: []string{"stun:stun3.l.google.com:5349"}},
+			{URLs: []string{"stun:stun4.l.google.com:19302"}},
+		},
+	}
+}
+
+// ParseICEServers parses one server per line: "url [username credential]",
+// for example "turns:turn.example.com:5349?transport=tcp alice secret".
+// Empty lines and lines starting with # are skipped.
+func ParseICEServers(text string) ([]ICEServerConfig, error) {
+	var servers []ICEServerConfig
+
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			servers = append(servers, ICEServerConfig{URLs: fields[:1]})
+		case 3:
+			servers = append(servers, ICEServerConfig{
+				URLs:       fields[:1],
+				Username:   fields[1],
+				Credential: fields[2],
+			})
+		default:
+			return nil, fmt.Errorf("line %d: expected \"url [username credential]\"", i+1)
+		}
+	}
+
+	return servers, nil
+}
+
+// FormatICEServers is the reverse of ParseICEServers
+func FormatICEServers(servers []ICEServerConfig) string {
+	var builder strings.Builder
+
+	for _, server := range servers {
+		for _, url := range server.URLs {
+			builder.WriteString(url)
+			if server.Username != "" || server.Credential != "" {
+				builder.WriteString(" " + server.Username + " " + server.Credential)
+			}
+			builder.WriteString("\n")
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,295 @@
+// Package bandwidth shapes transfer traffic with global, per-peer
+// and per-transfer token buckets.
+package bandwidth
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/pkg/ratelimit"
+)
+
+type Direction int
+
+const (
+	Upload Direction = iota
+	Download
+)
+
+// peer limiters unused for this long are dropped, their buckets
+// are full by then anyway
+var peerIdle = time.Minute
+
+// Stream is one limited flow of data. Streams with the same peer and
+// transfer share transfer override, transfer is file ID for LAN and
+// transfer ID for WebRTC.
+type Stream struct {
+	Direction Direction
+	Peer      string
+	Transfer  string
+	// shown to the user
+	Name string
+}
+
+type transferKey struct {
+	peer     string
+	transfer string
+}
+
+func (s Stream) key() transferKey {
+	return transferKey{peer: s.Peer, transfer: s.Transfer}
+}
+
+type peerLimiters struct {
+	limiters [2]*ratelimit.Limiter
+	used     time.Time
+}
+
+// Manager is safe for concurrent use, nil Manager doesn't limit anything.
+// Limits can be changed at any time and apply to running transfers.
+type Manager struct {
+	config configs.BandwidthConfig
+
+	global    [2]*ratelimit.Limiter
+	peers     map[string]*peerLimiters
+	lastPrune time.Time
+	transfers map[transferKey]*ratelimit.Limiter
+	// active streams, override of a transfer is dropped with its last stream
+	streams map[*Stream]struct{}
+
+	mu sync.Mutex
+}
+
+func New(config configs.BandwidthConfig) *Manager {
+	m := &Manager{
+		global:    [2]*ratelimit.Limiter{ratelimit.New(0), ratelimit.New(0)},
+		peers:     make(map[string]*peerLimiters),
+		lastPrune: time.Now(),
+		transfers: make(map[transferKey]*ratelimit.Limiter),
+		streams:   make(map[*Stream]struct{}),
+	}
+	m.SetConfig(config)
+	return m
+}
+
+func (m *Manager) Config() configs.BandwidthConfig {
+	if m == nil {
+		return configs.BandwidthConfig{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	config := m.config
+	config.Peers = maps.Clone(m.config.Peers)
+	return config
+}
+
+func (m *Manager) SetConfig(config configs.BandwidthConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	config.Peers = maps.Clone(config.Peers)
+	m.config = config
+
+	m.global[Upload].SetRate(config.Upload)
+	m.global[Download].SetRate(config.Download)
+	for peer, p := range m.peers {
+		upload, download := m.peerRates(peer)
+		p.limiters[Upload].SetRate(upload)
+		p.limiters[Download].SetRate(download)
+	}
+}
+
+// Track registers the stream until release is called, only tracked
+// transfers can get overrides. Reader and Writer track their streams.
+func (m *Manager) Track(s Stream) (release func()) {
+	if m == nil || s.Transfer == "" {
+		return func() {}
+	}
+
+	stream := &s
+	m.mu.Lock()
+	m.streams[stream] = struct{}{}
+	m.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			delete(m.streams, stream)
+			if !m.active(stream.key()) {
+				delete(m.transfers, stream.key())
+			}
+		})
+	}
+}
+
+// Streams returns active transfers, one stream per transfer
+func (m *Manager) Streams() []Stream {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[transferKey]bool, len(m.streams))
+	streams := make([]Stream, 0, len(m.streams))
+	for s := range m.streams {
+		if !seen[s.key()] {
+			seen[s.key()] = true
+			streams = append(streams, *s)
+		}
+	}
+	slices.SortFunc(streams, func(a, b Stream) int {
+		return cmp.Or(
+			cmp.Compare(a.Peer, b.Peer),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Transfer, b.Transfer),
+		)
+	})
+	return streams
+}
+
+// SetTransferRate overrides rate of the active transfer on top of global
+// and peer limits. Rate 0 removes override.
+func (m *Manager) SetTransferRate(peer, transfer string, rate int64) {
+	if m == nil {
+		return
+	}
+
+	key := transferKey{peer: peer, transfer: transfer}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rate <= 0 || !m.active(key) {
+		delete(m.transfers, key)
+		return
+	}
+
+	if l, ok := m.transfers[key]; ok {
+		l.SetRate(rate)
+		return
+	}
+	m.transfers[key] = ratelimit.New(rate)
+}
+
+func (m *Manager) TransferRate(peer, transfer string) int64 {
+	if m == nil {
+		return 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.transfers[transferKey{peer: peer, transfer: transfer}].Rate()
+}
+
+// Limiters returns all limiters that apply to the traffic right now,
+// empty peer or transfer are skipped
+func (m *Manager) Limiters(dir Direction, peer, transfer string) []*ratelimit.Limiter {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	limiters := []*ratelimit.Limiter{m.global[dir]}
+	if peer != "" {
+		limiters = append(limiters, m.peer(peer)[dir])
+	}
+	if l, ok := m.transfers[transferKey{peer: peer, transfer: transfer}]; ok && transfer != "" {
+		limiters = append(limiters, l)
+	}
+	return limiters
+}
+
+// Wait blocks until n bytes of the stream may be transferred
+func (m *Manager) Wait(ctx context.Context, n int, s Stream) error {
+	if m == nil {
+		return nil
+	}
+	return ratelimit.WaitAll(ctx, n, m.Limiters(s.Direction, s.Peer, s.Transfer)...)
+}
+
+// Reader limits r by limits of the stream, which is tracked until ctx is done.
+// Limiters are looked up for every chunk, so changes apply immediately.
+func (m *Manager) Reader(ctx context.Context, r io.Reader, s Stream) io.Reader {
+	if m == nil {
+		return r
+	}
+	m.trackUntil(ctx, s)
+	return ratelimit.NewWaitReader(r, func(n int) error {
+		return m.Wait(ctx, n, s)
+	})
+}
+
+// Writer is Reader for writes
+func (m *Manager) Writer(ctx context.Context, w io.Writer, s Stream) io.Writer {
+	if m == nil {
+		return w
+	}
+	m.trackUntil(ctx, s)
+	return ratelimit.NewWaitWriter(w, func(n int) error {
+		return m.Wait(ctx, n, s)
+	})
+}
+
+// trackUntil releases the stream when ctx is done, so ctx must be
+// canceled when the stream ends
+func (m *Manager) trackUntil(ctx context.Context, s Stream) {
+	if s.Transfer != "" {
+		context.AfterFunc(ctx, m.Track(s))
+	}
+}
+
+func (m *Manager) active(key transferKey) bool {
+	for s := range m.streams {
+		if s.key() == key {
+			return true
+		}
+	}
+	return false
+}
+
+// peer limiters are shared by all transfers of the peer, they are
+// dropped after peerIdle without traffic
+func (m *Manager) peer(peer string) [2]*ratelimit.Limiter {
+	now := time.Now()
+	if now.Sub(m.lastPrune) >= peerIdle {
+		m.lastPrune = now
+		maps.DeleteFunc(m.peers, func(_ string, p *peerLimiters) bool {
+			return now.Sub(p.used) >= peerIdle
+		})
+	}
+
+	if p, ok := m.peers[peer]; ok {
+		p.used = now
+		return p.limiters
+	}
+
+	upload, download := m.peerRates(peer)
+	p := &peerLimiters{
+		limiters: [2]*ratelimit.Limiter{ratelimit.New(upload), ratelimit.New(download)},
+		used:     now,
+	}
+	m.peers[peer] = p
+	return p.limiters
+}
+
+func (m *Manager) peerRates(peer string) (int64, int64) {
+	if own, ok := m.config.Peers[peer]; ok {
+		return own.Upload, own.Download
+	}
+	return m.config.PeerUpload, m.config.PeerDownload
+}
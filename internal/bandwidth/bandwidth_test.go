@@ -0,0 +1,184 @@
+package bandwidth
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/rapid/configs"
+)
+
+const rate = 1024 * 1024
+
+// expectDuration checks that transfer took want within tolerance
+func expectDuration(t *testing.T, got, want time.Duration) {
+	t.Helper()
+	if got < want*8/10 || got > want*13/10 {
+		t.Errorf("transfer took %v, want about %v", got, want)
+	}
+}
+
+func copyN(t *testing.T, r io.Reader, n int64) time.Duration {
+	t.Helper()
+
+	start := time.Now()
+	if _, err := io.CopyN(io.Discard, r, n); err != nil {
+		t.Fatal(err)
+	}
+	return time.Since(start)
+}
+
+func source() io.Reader {
+	return bytes.NewReader(make([]byte, 4*rate))
+}
+
+func TestGlobalThroughput(t *testing.T) {
+	m := New(configs.BandwidthConfig{Download: rate})
+
+	r := m.Reader(context.Background(), source(), Stream{Direction: Download, Peer: "10.0.0.1"})
+	expectDuration(t, copyN(t, r, rate/2), 500*time.Millisecond)
+
+	// upload is not limited
+	w := m.Writer(context.Background(), io.Discard, Stream{Direction: Upload, Peer: "10.0.0.1"})
+	start := time.Now()
+	if _, err := w.Write(make([]byte, rate)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("unlimited upload took %v", elapsed)
+	}
+}
+
+func TestPeerStreamsShareLimit(t *testing.T) {
+	m := New(configs.BandwidthConfig{PeerUpload: rate})
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, transfer := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := m.Writer(context.Background(), io.Discard, Stream{Direction: Upload, Peer: "10.0.0.1", Transfer: transfer})
+			_, _ = w.Write(make([]byte, rate/4))
+		}()
+	}
+	wg.Wait()
+	expectDuration(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestTransferRateAppliesToRunningStream(t *testing.T) {
+	m := New(configs.BandwidthConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream := Stream{Direction: Download, Peer: "10.0.0.1", Transfer: "file-1", Name: "movie.mkv"}
+	r := m.Reader(ctx, source(), stream)
+	if elapsed := copyN(t, r, rate/4); elapsed > 100*time.Millisecond {
+		t.Fatalf("unlimited transfer took %v", elapsed)
+	}
+
+	m.SetTransferRate(stream.Peer, stream.Transfer, rate/2)
+	expectDuration(t, copyN(t, r, rate/4), 500*time.Millisecond)
+
+	m.SetTransferRate(stream.Peer, stream.Transfer, 0)
+	if elapsed := copyN(t, r, rate/4); elapsed > 100*time.Millisecond {
+		t.Errorf("transfer took %v after override is removed", elapsed)
+	}
+}
+
+func TestTransferRateIsPerPeer(t *testing.T) {
+	m := New(configs.BandwidthConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// two peers download the same file
+	slow := m.Writer(ctx, io.Discard, Stream{Direction: Upload, Peer: "10.0.0.1", Transfer: "file-1"})
+	fast := m.Writer(ctx, io.Discard, Stream{Direction: Upload, Peer: "10.0.0.2", Transfer: "file-1"})
+	m.SetTransferRate("10.0.0.1", "file-1", rate)
+
+	start := time.Now()
+	if _, err := fast.Write(make([]byte, rate)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("other peer was limited, transfer took %v", elapsed)
+	}
+
+	start = time.Now()
+	if _, err := slow.Write(make([]byte, rate/2)); err != nil {
+		t.Fatal(err)
+	}
+	expectDuration(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestTransferRateRemovedWithLastStream(t *testing.T) {
+	m := New(configs.BandwidthConfig{})
+
+	// inactive transfers can't get overrides
+	m.SetTransferRate("10.0.0.1", "file-1", rate)
+	if got := m.TransferRate("10.0.0.1", "file-1"); got != 0 {
+		t.Fatalf("inactive transfer has rate %d", got)
+	}
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+
+	stream := Stream{Direction: Upload, Peer: "10.0.0.1", Transfer: "file-1", Name: "movie.mkv"}
+	m.Writer(first, io.Discard, stream)
+	m.Writer(second, io.Discard, stream)
+	m.SetTransferRate("10.0.0.1", "file-1", rate)
+
+	if streams := m.Streams(); len(streams) != 1 || streams[0] != stream {
+		t.Fatalf("streams = %+v, want one %+v", streams, stream)
+	}
+
+	cancelFirst()
+	waitFor(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return len(m.streams) == 1
+	})
+	if got := m.TransferRate("10.0.0.1", "file-1"); got != rate {
+		t.Fatalf("rate = %d while other stream is active", got)
+	}
+
+	cancelSecond()
+	waitFor(t, func() bool { return len(m.Streams()) == 0 })
+	if got := m.TransferRate("10.0.0.1", "file-1"); got != 0 {
+		t.Fatalf("rate = %d after last stream", got)
+	}
+}
+
+func TestIdlePeersArePruned(t *testing.T) {
+	defer func(idle time.Duration) { peerIdle = idle }(peerIdle)
+	peerIdle = 20 * time.Millisecond
+
+	m := New(configs.BandwidthConfig{})
+	m.Limiters(Upload, "10.0.0.1", "")
+	time.Sleep(2 * peerIdle)
+	m.Limiters(Upload, "10.0.0.2", "")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.peers["10.0.0.1"]; ok || len(m.peers) != 1 {
+		t.Fatalf("idle peer is kept, peers: %d", len(m.peers))
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
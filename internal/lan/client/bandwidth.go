@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+
+	"github.com/0x0FACED/rapid/internal/bandwidth"
+)
+
+// SetBandwidth задает ограничения скорости, nil снимает их
+func (c *LANClient) SetBandwidth(m *bandwidth.Manager) {
+	c.bandwidth.Store(m)
+}
+
+// limitDownload ограничивает чтение ответа сервера addr (ip:port). Пока ctx
+// не отменен, для файла fileID можно задать отдельное ограничение, name
+// показывается пользователю
+func (c *LANClient) limitDownload(ctx context.Context, r io.Reader, addr, fileID, name string) io.Reader {
+	return c.bandwidth.Load().Reader(ctx, r, bandwidth.Stream{
+		Direction: bandwidth.Download,
+		Peer:      peerHost(addr),
+		Transfer:  fileID,
+		Name:      name,
+	})
+}
+
+// limitUpload ограничивает отправку данных на сервер addr (ip:port)
+func (c *LANClient) limitUpload(ctx context.Context, r io.Reader, addr string) io.Reader {
+	return c.bandwidth.Load().Reader(ctx, r, bandwidth.Stream{
+		Direction: bandwidth.Upload,
+		Peer:      peerHost(addr),
+	})
+}
+
+// peerHost возвращает ip без порта, лимиты пиров в LAN задаются по ip
+func peerHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/pkg/cdc"
+)
+
+var ErrFileHashMismatch = errors.New("downloaded file hash mismatch")
+
+// ChunkStats показывает, сколько данных взято из хранилища чанков
+type ChunkStats struct {
+	Reused     int64
+	Downloaded int64
+}
+
+// GetChunks получает описание файла с сервера по адресу addr (ip:port) вместе со списком чанков
+func (c *LANClient) GetChunks(addr, fileID string) (model.File, error) {
+	url := fmt.Sprintf("http://%s/api/chunks/%s", addr, fileID)
+	// сервер считает чанки при первом запросе, это может быть долго
+	resp, err := c.streamClient.Get(url)
+	if err != nil {
+		return model.File{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return model.File{}, responseError(resp)
+	}
+
+	var info model.FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return model.File{}, err
+	}
+	file := info.File()
+	for _, chunk := range file.Chunks {
+		if chunk.Size <= 0 || chunk.Size > cdc.MaxSize {
+			return model.File{}, fmt.Errorf("invalid chunk size %d", chunk.Size)
+		}
+	}
+	return file, nil
+}
+
+// DownloadChunked скачивает файл в path, запрашивая у сервера только чанки,
+// которых нет в store. Скачанные чанки сохраняются в store для следующих файлов
+func (c *LANClient) DownloadChunked(addr, fileID, path string, store *cdc.Store) (ChunkStats, error) {
+	file, err := c.GetChunks(addr, fileID)
+	if err != nil {
+		return ChunkStats{}, err
+	}
+
+	hashes := make([]string, 0, len(file.Chunks))
+	sizes := make(map[string]int64, len(file.Chunks))
+	for _, chunk := range file.Chunks {
+		hashes = append(hashes, chunk.Hash)
+		sizes[chunk.Hash] = chunk.Size
+	}
+	missing := store.Missing(hashes)
+
+	var stats ChunkStats
+	if len(missing) > 0 {
+		stats.Downloaded, err = c.fetchChunks(context.Background(), addr, fileID, file.Name, missing, sizes, store)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	written, err := assemble(path, file, store)
+	if err != nil {
+		return stats, err
+	}
+	stats.Reused = written - stats.Downloaded
+	return stats, nil
+}
+
+// fetchChunks запрашивает чанки файла name и сохраняет их в store
+func (c *LANClient) fetchChunks(
+	ctx context.Context,
+	addr, fileID, name string,
+	hashes []string,
+	sizes map[string]int64,
+	store *cdc.Store,
+) (int64, error) {
+	body, err := json.Marshal(hashes)
+	if err != nil {
+		return 0, err
+	}
+
+	url := fmt.Sprintf("http://%s/api/chunks/%s", addr, fileID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.acceptEncoding(req)
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, responseError(resp)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var downloaded int64
+	decoded, err := decodeBody(resp, c.limitDownload(ctx, resp.Body, addr, fileID, name))
+	if err != nil {
+		return 0, err
+	}
+	defer decoded.Close()
+
+	r := bufio.NewReader(decoded)
+	data := make([]byte, cdc.MaxSize)
+	for _, hash := range hashes {
+		chunk := data[:sizes[hash]]
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return downloaded, err
+		}
+		if err := store.Put(hash, chunk); err != nil {
+			return downloaded, err
+		}
+		downloaded += int64(len(chunk))
+	}
+	return downloaded, nil
+}
+
+// assemble собирает файл из чанков и проверяет его хеш
+func assemble(path string, file model.File, store *cdc.Store) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".rapid-chunks-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	out := bufio.NewWriter(io.MultiWriter(tmp, h))
+
+	var written int64
+	for _, chunk := range file.Chunks {
+		n, err := store.WriteTo(chunk.Hash, out)
+		written += n
+		if err != nil {
+			tmp.Close()
+			return written, err
+		}
+	}
+
+	err = out.Flush()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return written, err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != file.Hash {
+		return written, ErrFileHashMismatch
+	}
+
+	return written, os.Rename(tmp.Name(), path)
+}
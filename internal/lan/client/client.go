@@ -1,16 +1,22 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/0x0FACED/rapid/internal/bandwidth"
 	"github.com/0x0FACED/rapid/internal/lan/mdnss"
 	"github.com/0x0FACED/rapid/internal/model"
 )
@@ -18,17 +24,26 @@ import (
 // LANClient позволяет находить серверы и загружать файлы
 type LANClient struct {
 	httpClient *http.Client
-	mdnss      *mdnss.MDNSScanner
+	// без таймаута, для передачи больших файлов
+	streamClient *http.Client
+	mdnss        *mdnss.MDNSScanner
+	// nil - без ограничений, см. bandwidth.go
+	bandwidth atomic.Pointer[bandwidth.Manager]
+	// см. compression.go
+	compression atomic.Bool
 
 	mu sync.Mutex
 }
 
 // New создает новый клиент
 func New(mdnss *mdnss.MDNSScanner) *LANClient {
-	return &LANClient{
-		httpClient: &http.Client{Timeout: 5 * time.Second},
-		mdnss:      mdnss,
+	c := &LANClient{
+		httpClient:   &http.Client{Timeout: 5 * time.Second},
+		streamClient: &http.Client{},
+		mdnss:        mdnss,
 	}
+	c.compression.Store(true)
+	return c
 }
 
 // DiscoverPeers ищет сервера в сети
@@ -47,15 +62,16 @@ func (c *LANClient) GetFiles(addr string, port string) ([]model.File, error) {
 	resp, err := c.httpClient.Get(url)
 	if err != nil {
 		log.Println("Err getting files with ip:", addr, err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var files []model.File
-	if err := json.NewDecoder(resp.Body).Decode(&files); err != nil {
+	var infos []model.FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
 		return nil, err
 	}
 
-	return files, nil
+	return model.Files(infos), nil
 
 }
 
@@ -66,23 +82,82 @@ func (c *LANClient) DownloadFile(addr, port, fileID, filename string) error {
 	defer c.mu.Unlock()
 
 	url := fmt.Sprintf("http://%s:%s/api/download/%s", addr, port, fileID)
-	resp, err := c.httpClient.Get(url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	c.acceptEncoding(req)
+
+	// при ограничении скорости загрузка может быть дольше любого таймаута
+	resp, err := c.streamClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	// отмена снимает отдельное ограничение загрузки
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	body, err := decodeBody(resp, c.limitDownload(ctx, resp.Body, addr, fileID, filepath.Base(filename)))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
 	out, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	// хеш считается по распакованным данным
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), body)
 	if err != nil {
 		return err
 	}
 
+	if want := resp.Header.Get(model.FileHashHeader); want != "" && hex.EncodeToString(h.Sum(nil)) != want {
+		// поврежденный файл не оставляем
+		_ = out.Close()
+		_ = os.Remove(filename)
+		return ErrFileHashMismatch
+	}
+
+	return nil
+}
+
+// SendMessage отправляет сообщение серверу по адресу addr (ip:port)
+func (c *LANClient) SendMessage(addr string, msg model.Message) error {
+	return c.post(addr, "/api/message", msg)
+}
+
+// SendClipboard отправляет содержимое буфера обмена серверу по адресу addr (ip:port)
+func (c *LANClient) SendClipboard(addr string, update model.ClipboardUpdate) error {
+	return c.post(addr, "/api/clipboard", update)
+}
+
+func (c *LANClient) post(addr, path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("http://%s%s", addr, path)
+	resp, err := c.httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("request rejected: %s", resp.Status)
+	}
 	return nil
 }
 
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/0x0FACED/rapid/pkg/compression"
+)
+
+// SetCompression включает сжатие при скачивании, сервер сам решает,
+// сжимать ли файл, уже сжатые форматы передаются как есть
+func (c *LANClient) SetCompression(enabled bool) {
+	c.compression.Store(enabled)
+}
+
+func (c *LANClient) Compression() bool {
+	return c.compression.Load()
+}
+
+// acceptEncoding предлагает серверу сжатие, если оно включено. Заданный вручную
+// Accept-Encoding отключает прозрачную распаковку gzip в http.Transport
+func (c *LANClient) acceptEncoding(req *http.Request) {
+	if c.compression.Load() {
+		req.Header.Set("Accept-Encoding", compression.AcceptEncoding)
+	}
+}
+
+// decodeBody распаковывает тело ответа по Content-Encoding, r - тело
+// после ограничений скорости, чтобы они учитывали байты в сети
+func decodeBody(resp *http.Response, r io.Reader) (io.ReadCloser, error) {
+	enc, err := compression.Parse(resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+	return compression.NewReader(r, enc)
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/0x0FACED/rapid/pkg/delta"
+)
+
+// DownloadDelta обновляет файл path до версии с сервера по адресу addr (ip:port),
+// скачиваются только изменившиеся блоки. Если файла нет, он скачивается целиком
+func (c *LANClient) DownloadDelta(addr, fileID, path string) (delta.Stats, error) {
+	basis, sig, err := openBasis(path)
+	if err != nil {
+		return delta.Stats{}, err
+	}
+	// на успешном пути файл закрывается до переименования,
+	// в Windows открытый файл нельзя заменить
+	defer func() {
+		if basis != nil {
+			basis.Close()
+		}
+	}()
+
+	body, w := io.Pipe()
+	go func() {
+		w.CloseWithError(sig.MarshalTo(w))
+	}()
+
+	url := fmt.Sprintf("http://%s/api/delta/%s", addr, fileID)
+	resp, err := c.streamClient.Post(url, "application/octet-stream", body)
+	if err != nil {
+		return delta.Stats{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return delta.Stats{}, responseError(resp)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".rapid-delta-*")
+	if err != nil {
+		return delta.Stats{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	var readerAt io.ReaderAt = bytes.NewReader(nil)
+	if basis != nil {
+		readerAt = basis
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := bufio.NewWriter(tmp)
+	in := c.limitDownload(ctx, resp.Body, addr, fileID, filepath.Base(path))
+	stats, err := delta.Apply(out, sig, readerAt, in)
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	if basis != nil {
+		err = basis.Close()
+		basis = nil
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, os.Rename(tmp.Name(), path)
+}
+
+// openBasis открывает старую версию файла и вычисляет ее сигнатуру,
+// для отсутствующего файла сигнатура пустая
+func openBasis(path string) (*os.File, *delta.Signature, error) {
+	basis, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		sig, err := delta.NewSignature(bytes.NewReader(nil), delta.MinBlockSize)
+		return nil, sig, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := basis.Stat()
+	if err != nil {
+		basis.Close()
+		return nil, nil, err
+	}
+
+	sig, err := delta.NewSignature(bufio.NewReader(basis), delta.BlockSizeFor(info.Size()))
+	if err != nil {
+		basis.Close()
+		return nil, nil, err
+	}
+	return basis, sig, nil
+}
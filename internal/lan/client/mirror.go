@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/0x0FACED/rapid/internal/mirror"
+)
+
+// MirrorPeer синхронизирует папку с сервером по адресу addr (ip:port)
+type MirrorPeer struct {
+	addr string
+	// токен синхронизации, который показывает принимающая сторона
+	token      string
+	httpClient *http.Client
+	client     *LANClient
+}
+
+// MirrorPeer возвращает mirror.Peer для сервера с токеном синхронизации
+func (c *LANClient) MirrorPeer(addr, token string) *MirrorPeer {
+	return &MirrorPeer{
+		addr:       addr,
+		token:      token,
+		httpClient: c.streamClient,
+		client:     c,
+	}
+}
+
+// Manifest получает состояние папки на сервере
+func (p *MirrorPeer) Manifest(ctx context.Context, folder string) (*mirror.Manifest, error) {
+	query := url.Values{"folder": {folder}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url("/api/sync/manifest", query), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(mirror.TokenHeader, p.token)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	manifest := mirror.NewManifest()
+	if err := json.NewDecoder(resp.Body).Decode(manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Push отправляет локальный файл local в папку на сервере
+func (p *MirrorPeer) Push(ctx context.Context, folder string, entry mirror.Entry, local string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	query := url.Values{
+		"folder": {folder},
+		"path":   {entry.Path},
+		"size":   {strconv.FormatInt(entry.Size, 10)},
+		"hash":   {entry.Hash},
+		"mtime":  {strconv.FormatInt(entry.ModTime.Unix(), 10)},
+	}
+	body := p.client.limitUpload(ctx, f, p.addr)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url("/api/push", query), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = entry.Size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	return p.do(req)
+}
+
+// Delete удаляет файл из папки на сервере
+func (p *MirrorPeer) Delete(ctx context.Context, folder, path string) error {
+	query := url.Values{"folder": {folder}, "path": {path}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, p.url("/api/push", query), nil)
+	if err != nil {
+		return err
+	}
+	return p.do(req)
+}
+
+func (p *MirrorPeer) url(path string, query url.Values) string {
+	return fmt.Sprintf("http://%s%s?%s", p.addr, path, query.Encode())
+}
+
+func (p *MirrorPeer) do(req *http.Request) error {
+	req.Header.Set(mirror.TokenHeader, p.token)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("request rejected: %s: %s", resp.Status, body)
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/internal/search"
+)
+
+// Search ищет файлы на сервере по запросу (синтаксис в search.Query).
+// Старые серверы без /api/search отдают весь список, он фильтруется здесь.
+func (c *LANClient) Search(addr, port, query string) ([]model.File, error) {
+	q, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("http://%s:%s/api/search?q=%s", addr, port, url.QueryEscape(query))
+	resp, err := c.httpClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return c.searchFiles(addr, port, q)
+	default:
+		return nil, responseError(resp)
+	}
+
+	var infos []model.FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		return nil, err
+	}
+	return model.Files(infos), nil
+}
+
+// searchFiles фильтрует полный список файлов сервера
+func (c *LANClient) searchFiles(addr, port string, q search.Query) ([]model.File, error) {
+	files, err := c.GetFiles(addr, port)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.File, 0)
+	for _, file := range files {
+		if q.Match(file) {
+			result = append(result, file)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/pkg/cdc"
+)
+
+const (
+	// чанков в одном запросе к источнику, около 1 MiB
+	swarmBatchSize = 16
+	// как часто проверяется, что источник еще в сети
+	swarmAliveInterval = time.Second
+)
+
+var (
+	ErrNoSources     = errors.New("no sources with the same content")
+	ErrSourcesFailed = errors.New("all sources failed")
+	ErrSourceGone    = errors.New("source disappeared")
+)
+
+// SwarmSource - сервер (ip:port) и ID файла на нем
+type SwarmSource struct {
+	Addr   string
+	FileID string
+}
+
+// SwarmStats показывает, сколько данных получено от каждого источника
+type SwarmStats struct {
+	Reused     int64
+	Downloaded map[string]int64
+}
+
+// SwarmDownload скачивает файл в path одновременно со всех источников с одинаковым
+// содержимым. Разные источники отдают разные чанки, каждый чанк проверяется по хешу.
+// Источник, для которого alive вернул false или запрос завершился ошибкой,
+// исключается, а его чанки достаются остальным
+func (c *LANClient) SwarmDownload(
+	ctx context.Context,
+	sources []SwarmSource,
+	path string,
+	store *cdc.Store,
+	alive func(addr string) bool,
+) (SwarmStats, error) {
+	stats := SwarmStats{Downloaded: make(map[string]int64)}
+
+	file, sources, err := c.swarmManifest(sources)
+	if err != nil {
+		return stats, err
+	}
+
+	hashes := make([]string, 0, len(file.Chunks))
+	sizes := make(map[string]int64, len(file.Chunks))
+	for _, chunk := range file.Chunks {
+		hashes = append(hashes, chunk.Hash)
+		sizes[chunk.Hash] = chunk.Size
+	}
+
+	missing := store.Missing(hashes)
+	// емкости хватает на все пачки, поэтому возврат пачки в очередь не блокируется
+	batches := make(chan []string, len(missing)/swarmBatchSize+1)
+	var pending atomic.Int64
+	for batch := range slices.Chunk(missing, swarmBatchSize) {
+		pending.Add(1)
+		batches <- batch
+	}
+
+	done := make(chan struct{})
+	if pending.Load() == 0 {
+		close(done)
+	}
+	batchDone := func() {
+		if pending.Add(-1) == 0 {
+			close(done)
+		}
+	}
+
+	// при любом выходе воркеры останавливаются и дожидаются,
+	// чтобы ни один не пережил вызов
+	ctx, cancel := context.WithCancel(ctx)
+	var workers sync.WaitGroup
+	defer func() {
+		cancel()
+		workers.Wait()
+	}()
+
+	var mu sync.Mutex
+	for _, source := range sources {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			err := c.swarmWorker(ctx, source, file.Name, batches, batchDone, sizes, store, alive, func(n int64) {
+				mu.Lock()
+				stats.Downloaded[source.Addr] += n
+				mu.Unlock()
+			})
+			if err != nil {
+				log.Printf("Swarm source %s excluded: %v", source.Addr, err)
+			}
+		}()
+	}
+
+	failed := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(failed)
+	}()
+
+	select {
+	case <-done:
+		// все пачки скачаны, больше никто не пишет в очередь
+		close(batches)
+	case <-failed:
+		return stats, ErrSourcesFailed
+	case <-ctx.Done():
+		return stats, ctx.Err()
+	}
+
+	written, err := assemble(path, file, store)
+	if err != nil {
+		return stats, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	stats.Reused = written
+	for _, n := range stats.Downloaded {
+		stats.Reused -= n
+	}
+	return stats, nil
+}
+
+// swarmManifest получает список чанков и оставляет источники с тем же содержимым
+func (c *LANClient) swarmManifest(sources []SwarmSource) (model.File, []SwarmSource, error) {
+	var reference model.File
+	var matched []SwarmSource
+
+	for _, source := range sources {
+		file, err := c.GetChunks(source.Addr, source.FileID)
+		if err != nil {
+			log.Printf("Swarm source %s excluded: %v", source.Addr, err)
+			continue
+		}
+
+		if len(matched) == 0 {
+			reference = file
+		} else if file.Hash != reference.Hash {
+			log.Printf("Swarm source %s excluded: different content", source.Addr)
+			continue
+		}
+		matched = append(matched, source)
+	}
+
+	if len(matched) == 0 {
+		return model.File{}, nil, ErrNoSources
+	}
+	return reference, matched, nil
+}
+
+// swarmWorker скачивает пачки чанков из одного источника, пока они есть.
+// При ошибке пачка возвращается в очередь для других источников
+func (c *LANClient) swarmWorker(
+	ctx context.Context,
+	source SwarmSource,
+	name string,
+	batches chan []string,
+	batchDone func(),
+	sizes map[string]int64,
+	store *cdc.Store,
+	alive func(addr string) bool,
+	onDownloaded func(int64),
+) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// запрос к пропавшему источнику прерывается, а не висит до таймаута TCP
+	go func() {
+		ticker := time.NewTicker(swarmAliveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !alive(source.Addr) {
+					cancel(ErrSourceGone)
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		var batch []string
+		select {
+		case b, ok := <-batches:
+			if !ok {
+				return nil
+			}
+			batch = b
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+
+		if err := context.Cause(ctx); err != nil {
+			batches <- batch
+			return err
+		}
+
+		missing := store.Missing(batch)
+		if len(missing) == 0 {
+			batchDone()
+			continue
+		}
+
+		n, err := c.fetchChunks(ctx, source.Addr, source.FileID, name, missing, sizes, store)
+		onDownloaded(n)
+		if err != nil {
+			batches <- batch
+			if cause := context.Cause(ctx); cause != nil {
+				return cause
+			}
+			return err
+		}
+		batchDone()
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/0x0FACED/rapid/pkg/thumbnail"
+)
+
+// maxThumbnailSize ограничивает ответ сервера
+const maxThumbnailSize = 1024 * 1024
+
+// GetThumbnail получает превью файла с сервера по адресу addr (ip:port),
+// size - длина большей стороны картинки в пикселях
+func (c *LANClient) GetThumbnail(addr, fileID string, size int) (thumbnail.Thumbnail, error) {
+	url := fmt.Sprintf("http://%s/api/thumbnail/%s?size=%d", addr, fileID, size)
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return thumbnail.Thumbnail{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		// у файла нет превью
+		return thumbnail.Thumbnail{Kind: thumbnail.KindNone}, nil
+	default:
+		return thumbnail.Thumbnail{}, responseError(resp)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	thumb := thumbnail.Thumbnail{ContentType: contentType}
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		thumb.Kind = thumbnail.KindImage
+	case strings.HasPrefix(mediaType, "text/"):
+		thumb.Kind = thumbnail.KindText
+	default:
+		return thumbnail.Thumbnail{Kind: thumbnail.KindNone}, nil
+	}
+
+	thumb.Data, err = io.ReadAll(io.LimitReader(resp.Body, maxThumbnailSize))
+	if err != nil {
+		return thumbnail.Thumbnail{}, err
+	}
+	return thumb, nil
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/0x0FACED/rapid/internal/bandwidth"
+	"github.com/0x0FACED/rapid/internal/model"
+)
+
+// Bandwidth returns limits of the server traffic, they can be changed at runtime
+func (s *LANServer) Bandwidth() *bandwidth.Manager {
+	return s.bandwidth
+}
+
+// limitedResponse shapes response body, headers are not counted
+type limitedResponse struct {
+	http.ResponseWriter
+	body io.Writer
+}
+
+func (w *limitedResponse) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
+
+// limitUpload wraps response with upload limits of the requesting peer,
+// the file can get its own limit while it's sent
+func (s *LANServer) limitUpload(w http.ResponseWriter, r *http.Request, file model.File) http.ResponseWriter {
+	body := s.bandwidth.Writer(r.Context(), w, bandwidth.Stream{
+		Direction: bandwidth.Upload,
+		Peer:      remoteHost(r),
+		Transfer:  file.ID,
+		Name:      file.Name,
+	})
+	return &limitedResponse{ResponseWriter: w, body: body}
+}
+
+// limitDownload wraps request body with download limits of the sending peer
+func (s *LANServer) limitDownload(r *http.Request) io.Reader {
+	return s.bandwidth.Reader(r.Context(), r.Body, bandwidth.Stream{
+		Direction: bandwidth.Download,
+		Peer:      remoteHost(r),
+	})
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/pkg/cdc"
+)
+
+// maxChunkRequestSize limits list of requested hashes
+const maxChunkRequestSize = 16 * 1024 * 1024
+
+// chunkManifest is cached until the file changes
+type chunkManifest struct {
+	size    int64
+	modTime time.Time
+	hash    string
+	chunks  []model.Chunk
+	// hash -> offset in the file
+	offsets map[string]int64
+}
+
+// chunks returns chunk manifest of the shared file, it's
+// computed on first request and after every change
+func (s *LANServer) chunks(file model.File) (*chunkManifest, error) {
+	info, err := os.Stat(file.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	cached, ok := s.chunkCache[file.ID]
+	s.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached, nil
+	}
+
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	manifest := &chunkManifest{
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		offsets: make(map[string]int64),
+	}
+	h := sha256.New()
+	err = cdc.Split(io.TeeReader(bufio.NewReader(f), h), func(offset int64, data []byte) error {
+		hash := cdc.Hash(data)
+		manifest.chunks = append(manifest.chunks, model.Chunk{Hash: hash, Size: int64(len(data))})
+		if _, ok := manifest.offsets[hash]; !ok {
+			manifest.offsets[hash] = offset
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifest.hash = hex.EncodeToString(h.Sum(nil))
+
+	s.mu.Lock()
+	s.chunkCache[file.ID] = manifest
+	s.mu.Unlock()
+	return manifest, nil
+}
+
+// handleChunks returns chunk manifest (GET) or streams requested
+// chunks one after another in request order (POST)
+func (s *LANServer) handleChunks(w http.ResponseWriter, r *http.Request) {
+	id := filepath.Base(r.URL.Path)
+	s.mu.Lock()
+	file, exists := s.fileList[id]
+	s.mu.Unlock()
+
+	if !exists {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	manifest, err := s.chunks(file)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		file.Size = manifest.size
+		file.Hash = manifest.hash
+		file.Chunks = manifest.chunks
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(file.Info())
+	case http.MethodPost:
+		var hashes []string
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxChunkRequestSize)).Decode(&hashes); err != nil {
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		for _, hash := range hashes {
+			if _, ok := manifest.offsets[hash]; !ok {
+				// file changed after client got the manifest
+				http.Error(w, "Unknown chunk "+hash, http.StatusConflict)
+				return
+			}
+		}
+
+		f, err := os.Open(file.Path)
+		if err != nil {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		body := compressResponse(s.limitUpload(w, r, file), r, file)
+		defer body.Close()
+		sizes := make(map[string]int64, len(manifest.chunks))
+		for _, chunk := range manifest.chunks {
+			sizes[chunk.Hash] = chunk.Size
+		}
+		for _, hash := range hashes {
+			chunk := io.NewSectionReader(f, manifest.offsets[hash], sizes[hash])
+			if _, err := io.Copy(body, chunk); err != nil {
+				fmt.Println("Failed to send chunk:", err)
+				return
+			}
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/pkg/compression"
+)
+
+// encodedResponse compresses body of full responses. Partial responses are
+// sent as is, their Content-Range counts bytes of the file, not of the compressed body
+type encodedResponse struct {
+	http.ResponseWriter
+	enc         compression.Encoding
+	encoder     io.WriteCloser
+	wroteHeader bool
+}
+
+// compressResponse negotiates encoding of the file with the client,
+// Close must be called after the body is written
+func compressResponse(w http.ResponseWriter, r *http.Request, file model.File) *encodedResponse {
+	resp := &encodedResponse{ResponseWriter: w}
+	if r.Method == http.MethodHead || r.Header.Get("Range") != "" ||
+		!compression.CompressibleFile(file.Name, file.Path) {
+		return resp
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	resp.enc = compression.Negotiate(r.Header.Get("Accept-Encoding"))
+	return resp
+}
+
+func (w *encodedResponse) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if w.enc != compression.Identity && code == http.StatusOK {
+		encoder, err := compression.NewWriter(w.ResponseWriter, w.enc)
+		if err == nil {
+			w.encoder = encoder
+			w.Header().Del("Content-Length")
+			w.Header().Set("Content-Encoding", string(w.enc))
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *encodedResponse) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Close flushes compressed stream
+func (w *encodedResponse) Close() error {
+	if w.encoder == nil {
+		return nil
+	}
+	return w.encoder.Close()
+}
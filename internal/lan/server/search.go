@@ -0,0 +1,35 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/internal/search"
+)
+
+// handleSearch returns shared files matching q, see search.Query for syntax
+func (s *LANServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := search.Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	files := make([]model.File, 0)
+	for _, file := range s.fileList {
+		if query.Match(file) {
+			files = append(files, file)
+		}
+	}
+	s.mu.Unlock()
+
+	// local paths are not sent
+	json.NewEncoder(w).Encode(model.Infos(files))
+}
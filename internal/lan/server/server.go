@@ -1,22 +1,50 @@
 package server
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/internal/bandwidth"
+	"github.com/0x0FACED/rapid/internal/mirror"
 	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/pkg/delta"
 	"github.com/google/uuid"
 )
 
+var ErrFileNotShared = errors.New("file is not shared")
+
 type LANServer struct {
 	httpServer *http.Server
 	fileList   map[string]model.File
-	mu         sync.Mutex
+	// file id -> chunks, see chunks.go
+	chunkCache map[string]*chunkManifest
+	// file id -> size -> preview, see thumbnails.go
+	thumbCache map[string]map[int]*thumbnailEntry
+	onMessage  func(model.Message)
+	// nil when clipboard sync is off
+	onClipboard func(model.ClipboardUpdate, string)
+	// nil when incoming folder sync is off
+	mirror *mirror.Store
+	// peers have to send it to mirror folders to us
+	mirrorToken string
+	// unlimited until configured, see bandwidth.go
+	bandwidth *bandwidth.Manager
+	// browser interface, see web.go
+	web *webAccess
+	mu  sync.Mutex
 
 	config configs.LANServerConfig
 }
@@ -29,8 +57,13 @@ func New(cfg configs.LANServerConfig) *LANServer {
 			Addr:    cfg.Address,
 			Handler: mux,
 		},
-		fileList: make(map[string]model.File),
-		config:   cfg,
+		fileList:   make(map[string]model.File),
+		chunkCache: make(map[string]*chunkManifest),
+		thumbCache: make(map[string]map[int]*thumbnailEntry),
+		onMessage:  func(model.Message) {},
+		bandwidth:  bandwidth.New(configs.BandwidthConfig{}),
+		web:        newWebAccess(cfg),
+		config:     cfg,
 	}
 	server.RegisterHandlers(mux)
 	return server
@@ -39,8 +72,17 @@ func New(cfg configs.LANServerConfig) *LANServer {
 func (s *LANServer) RegisterHandlers(mux *http.ServeMux) {
 	mux.HandleFunc("/api/share", s.handleShare)
 	mux.HandleFunc("/api/files", s.handleFiles)
+	mux.HandleFunc("/api/search", s.handleSearch)
 	mux.HandleFunc("/api/download/", s.handleDownload)
+	mux.HandleFunc("/api/delta/", s.handleDelta)
+	mux.HandleFunc("/api/chunks/", s.handleChunks)
+	mux.HandleFunc("/api/thumbnail/", s.handleThumbnail)
 	mux.HandleFunc("/api/ping", s.handlePing)
+	mux.HandleFunc("/api/message", s.handleMessage)
+	mux.HandleFunc("/api/clipboard", s.handleClipboard)
+	mux.HandleFunc("/api/sync/manifest", s.handleSyncManifest)
+	mux.HandleFunc("/api/push", s.handlePush)
+	s.registerWebHandlers(mux)
 }
 
 func (s *LANServer) Start() error {
@@ -48,8 +90,54 @@ func (s *LANServer) Start() error {
 	return s.httpServer.ListenAndServe()
 }
 
+func (s *LANServer) DownloadsDir() string {
+	return s.config.DownloadsDir
+}
+
+// SetOnMessage sets callback for messages sent by LAN peers
+func (s *LANServer) SetOnMessage(onMessage func(model.Message)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onMessage = onMessage
+}
+
+// SetOnClipboard sets callback for clipboard updates with IP of the sender,
+// nil rejects them
+func (s *LANServer) SetOnClipboard(onClipboard func(update model.ClipboardUpdate, host string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onClipboard = onClipboard
+}
+
+// SetMirrorStore enables folders mirrored to us by peers that send the token,
+// nil disables it
+func (s *LANServer) SetMirrorStore(store *mirror.Store, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mirror = store
+	s.mirrorToken = token
+}
+
+// mirrorStore returns store for requests with valid sync token,
+// otherwise it writes error response and returns nil
+func (s *LANServer) mirrorStore(w http.ResponseWriter, r *http.Request) *mirror.Store {
+	s.mu.Lock()
+	store, token := s.mirror, s.mirrorToken
+	s.mu.Unlock()
+
+	if store == nil {
+		http.Error(w, "Folder sync is disabled", http.StatusForbidden)
+		return nil
+	}
+	if !mirror.ValidToken(r.Header.Get(mirror.TokenHeader), token) {
+		http.Error(w, "Invalid sync token", http.StatusUnauthorized)
+		return nil
+	}
+	return store
+}
+
 func (s *LANServer) ShareLocal(path string) (model.File, error) {
-	fileStat, err := os.Stat(path)
+	file, err := readFile(uuid.NewString(), filepath.Base(path), path)
 	if err != nil {
 		return model.File{}, err
 	}
@@ -57,18 +145,109 @@ func (s *LANServer) ShareLocal(path string) (model.File, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	id := uuid.NewString()
-	file := model.File{
-		ID:   id,
-		Name: fileStat.Name(),
-		Path: path,
-		Size: fileStat.Size(),
+	s.fileList[file.ID] = file
+	return file, nil
+}
+
+// Unshare stops sharing the file
+func (s *LANServer) Unshare(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.fileList[id]; !ok {
+		return ErrFileNotShared
 	}
-	s.fileList[id] = file
+	delete(s.fileList, id)
+	delete(s.chunkCache, id)
+	delete(s.thumbCache, id)
+	return nil
+}
+
+// Update reads size and hash of the shared file again, ID stays the same
+func (s *LANServer) Update(id string) (model.File, error) {
+	s.mu.Lock()
+	file, ok := s.fileList[id]
+	s.mu.Unlock()
+	if !ok {
+		return model.File{}, ErrFileNotShared
+	}
+
+	updated, err := readFile(file.ID, file.Name, file.Path)
+	if err != nil {
+		return model.File{}, err
+	}
+	hash, err := hashFile(file.Path)
+	if err != nil {
+		return model.File{}, err
+	}
+	updated.Hash = hash
+	updated.SharedAt = file.SharedAt
+	file = updated
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// unshared while hashing
+	if _, ok := s.fileList[id]; !ok {
+		return model.File{}, ErrFileNotShared
+	}
+	s.fileList[id] = file
 	return file, nil
 }
 
+// readFile collects metadata of the local file, hash is computed separately
+func readFile(id, name, path string) (model.File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return model.File{}, err
+	}
+
+	return model.File{
+		ID:       id,
+		Name:     name,
+		Path:     path,
+		Size:     info.Size(),
+		MIMEType: detectMIME(path),
+		ModTime:  info.ModTime(),
+		Mode:     info.Mode().Perm(),
+		SharedAt: time.Now(),
+	}, nil
+}
+
+// detectMIME uses extension first and content of the file if it is unknown
+func detectMIME(path string) string {
+	if mimeType := mime.TypeByExtension(filepath.Ext(path)); mimeType != "" {
+		return mimeType
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	if n == 0 {
+		return ""
+	}
+	return http.DetectContentType(head[:n])
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func (s *LANServer) handlePing(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -97,7 +276,7 @@ func (s *LANServer) handleShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fileStat, err := os.Stat(input.Path)
+	file, err := readFile(uuid.NewString(), input.Name, input.Path)
 	if err != nil {
 		http.Error(w, "File not found", http.StatusBadRequest)
 		return
@@ -106,13 +285,8 @@ func (s *LANServer) handleShare(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	id := uuid.NewString()
-	s.fileList[id] = model.File{
-		ID:   id,
-		Name: input.Name,
-		Path: input.Path,
-		Size: fileStat.Size(),
-	}
+	id := file.ID
+	s.fileList[id] = file
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{
@@ -135,7 +309,8 @@ func (s *LANServer) handleFiles(w http.ResponseWriter, r *http.Request) {
 		files = append(files, file)
 	}
 
-	json.NewEncoder(w).Encode(files)
+	// local paths are not sent
+	json.NewEncoder(w).Encode(model.Infos(files))
 }
 
 func (s *LANServer) handleDownload(w http.ResponseWriter, r *http.Request) {
@@ -149,5 +324,195 @@ func (s *LANServer) handleDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.ServeFile(w, r, file.Path)
+	if file.Hash != "" {
+		w.Header().Set(model.FileHashHeader, file.Hash)
+	}
+
+	resp := compressResponse(s.limitUpload(w, r, file), r, file)
+	defer resp.Close()
+	http.ServeFile(resp, r, file.Path)
+}
+
+// maxSignatureSize limits signature sent by delta clients
+const maxSignatureSize = 64 * 1024 * 1024
+
+// handleDelta reads signature of the client's copy and replies with
+// delta that rebuilds the shared file from it
+func (s *LANServer) handleDelta(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := filepath.Base(r.URL.Path)
+	s.mu.Lock()
+	file, exists := s.fileList[id]
+	s.mu.Unlock()
+
+	if !exists {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	sig, err := delta.ReadSignature(http.MaxBytesReader(w, r.Body, maxSignatureSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(file.Path)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if _, err := delta.Write(s.limitUpload(w, r, file), sig, bufio.NewReader(f)); err != nil {
+		fmt.Println("Failed to write delta:", err)
+	}
+}
+
+func (s *LANServer) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// json escaping can make body larger than the text
+	r.Body = http.MaxBytesReader(w, r.Body, 2*model.MaxMessageSize+1024)
+
+	var msg model.Message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	if err := msg.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	onMessage := s.onMessage
+	s.mu.Unlock()
+	onMessage(msg)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *LANServer) handleClipboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	onClipboard := s.onClipboard
+	s.mu.Unlock()
+	if onClipboard == nil {
+		http.Error(w, "Clipboard sync is disabled", http.StatusForbidden)
+		return
+	}
+
+	// data is base64 in json
+	r.Body = http.MaxBytesReader(w, r.Body, 2*model.MaxClipboardSize+1024)
+
+	var update model.ClipboardUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	if err := update.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	onClipboard(update, remoteHost(r))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *LANServer) handleSyncManifest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	store := s.mirrorStore(w, r)
+	if store == nil {
+		return
+	}
+
+	manifest, err := store.Manifest(r.URL.Query().Get("folder"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// handlePush stores (POST) or deletes (DELETE) file of the mirrored folder
+func (s *LANServer) handlePush(w http.ResponseWriter, r *http.Request) {
+	// browsers upload files to us even without folder sync
+	session := s.webSession(r)
+	web := session != ""
+
+	var store *mirror.Store
+	if !web {
+		if store = s.mirrorStore(w, r); store == nil {
+			return
+		}
+	}
+
+	query := r.URL.Query()
+	folder, path := query.Get("folder"), query.Get("path")
+
+	switch r.Method {
+	case http.MethodPost:
+		size, err := strconv.ParseInt(query.Get("size"), 10, 64)
+		if err != nil || size < 0 {
+			http.Error(w, "Invalid size", http.StatusBadRequest)
+			return
+		}
+		mtime, err := strconv.ParseInt(query.Get("mtime"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid mtime", http.StatusBadRequest)
+			return
+		}
+
+		entry := mirror.Entry{
+			Path:    path,
+			Size:    size,
+			ModTime: time.Unix(mtime, 0),
+			Hash:    query.Get("hash"),
+		}
+		if web {
+			err = s.writeWebUpload(w, r, session, entry)
+		} else {
+			err = store.Write(folder, entry, s.limitDownload(r))
+		}
+		if errors.Is(err, ErrWebUploadLimit) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		if web {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := store.Delete(folder, path); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
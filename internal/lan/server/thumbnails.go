@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/pkg/thumbnail"
+)
+
+// thumbnailEntry is cached until the file changes
+type thumbnailEntry struct {
+	size    int64
+	modTime time.Time
+	thumb   thumbnail.Thumbnail
+}
+
+// thumbnail returns preview of the shared file, it's generated
+// on first request and after every change. Size is snapped to
+// thumbnail.Sizes, so few previews are cached per file
+func (s *LANServer) thumbnail(file model.File, size int) (thumbnail.Thumbnail, error) {
+	size = thumbnail.Snap(size)
+
+	info, err := os.Stat(file.Path)
+	if err != nil {
+		return thumbnail.Thumbnail{}, err
+	}
+
+	s.mu.Lock()
+	cached, ok := s.thumbCache[file.ID][size]
+	s.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.thumb, nil
+	}
+
+	thumb, err := thumbnail.Generate(file.Name, file.Path, size)
+	if err != nil {
+		return thumbnail.Thumbnail{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// unshared while generating
+	if _, ok := s.fileList[file.ID]; !ok {
+		return thumb, nil
+	}
+	if s.thumbCache[file.ID] == nil {
+		s.thumbCache[file.ID] = make(map[int]*thumbnailEntry)
+	}
+	s.thumbCache[file.ID][size] = &thumbnailEntry{size: info.Size(), modTime: info.ModTime(), thumb: thumb}
+	return thumb, nil
+}
+
+// handleThumbnail returns jpeg preview of images, first lines of text
+// files and 204 for other types. Optional size is the longest side in pixels.
+func (s *LANServer) handleThumbnail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := filepath.Base(r.URL.Path)
+	s.mu.Lock()
+	file, exists := s.fileList[id]
+	s.mu.Unlock()
+
+	if !exists {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	size := thumbnail.DefaultSize
+	if value := r.URL.Query().Get("size"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > thumbnail.MaxSize {
+			http.Error(w, "Invalid size", http.StatusBadRequest)
+			return
+		}
+		size = parsed
+	}
+
+	thumb, err := s.thumbnail(file, size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if thumb.Kind == thumbnail.KindNone {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", thumb.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(thumb.Data)))
+	w.Write(thumb.Data)
+}
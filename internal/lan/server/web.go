@@ -0,0 +1,345 @@
+package server
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/internal/mirror"
+)
+
+// Browser interface for devices without rapid. The page is public, listing
+// and downloads use the usual API that is open to every LAN peer anyway,
+// uploads need a session that is given for the PIN or the token from the
+// QR code. Uploads of a session are limited in size, see LANServerConfig.
+
+//go:embed web
+var webFiles embed.FS
+
+const (
+	webCookie     = "rapid_web"
+	webSessionTTL = 12 * time.Hour
+	// browser uploads are saved to this folder of downloads
+	webUploadFolder = "browser"
+	pinDigits       = 6
+	// wrong PINs in a row before PIN logins are paused
+	maxPINFailures = 5
+	pinLockout     = time.Minute
+
+	defaultWebUploadLimit  = 4 << 30
+	defaultWebSessionQuota = 16 << 30
+)
+
+var ErrWebUploadLimit = errors.New("upload is over the limit")
+
+type webSession struct {
+	expires time.Time
+	// bytes of uploads, including running ones
+	uploaded int64
+}
+
+type webAccess struct {
+	token    string
+	pin      string
+	sessions map[string]*webSession
+	failures int
+	locked   time.Time
+	uploads  *mirror.Store
+
+	uploadLimit  int64
+	sessionQuota int64
+	// free name is chosen and taken at once
+	commitMu sync.Mutex
+}
+
+func newWebAccess(cfg configs.LANServerConfig) *webAccess {
+	w := &webAccess{
+		uploads:      mirror.NewStore(cfg.DownloadsDir),
+		uploadLimit:  cmp.Or(cfg.WebUploadLimit, defaultWebUploadLimit),
+		sessionQuota: cmp.Or(cfg.WebSessionQuota, defaultWebSessionQuota),
+	}
+	w.reset()
+	return w
+}
+
+func (w *webAccess) reset() {
+	w.token = randomHex(16)
+	w.pin = randomPIN()
+	w.sessions = make(map[string]*webSession)
+	w.failures = 0
+	w.locked = time.Time{}
+}
+
+// WebAccess returns token for QR code links and PIN for manual login
+func (s *LANServer) WebAccess() (token, pin string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.web.token, s.web.pin
+}
+
+// ResetWebAccess makes new token and PIN, browsers have to log in again
+func (s *LANServer) ResetWebAccess() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.web.reset()
+}
+
+// WebURLs returns addresses of the browser interface on local networks
+func (s *LANServer) WebURLs() []string {
+	host, port, err := net.SplitHostPort(s.config.Address)
+	if err != nil {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		return []string{fmt.Sprintf("http://%s/", net.JoinHostPort(host, port))}
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	// private addresses go first, they are what phones on the same Wi-Fi use
+	var private, other []string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		u := fmt.Sprintf("http://%s/", net.JoinHostPort(ipNet.IP.String(), port))
+		if ipNet.IP.IsPrivate() {
+			private = append(private, u)
+		} else {
+			other = append(other, u)
+		}
+	}
+	return append(private, other...)
+}
+
+func (s *LANServer) registerWebHandlers(mux *http.ServeMux) {
+	static, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	mux.Handle("/", http.FileServerFS(static))
+	mux.HandleFunc("/web/login", s.handleWebLogin)
+	mux.HandleFunc("/web/session", s.handleWebSession)
+}
+
+type webLogin struct {
+	PIN   string `json:"pin,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+func (s *LANServer) handleWebLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var login webLogin
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&login); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	session, err := s.web.login(login)
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     webCookie,
+		Value:    session,
+		Path:     "/",
+		MaxAge:   int(webSessionTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleWebSession tells the page whether it has to ask for PIN
+func (s *LANServer) handleWebSession(w http.ResponseWriter, r *http.Request) {
+	if !s.webAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// login must be called with server lock held
+func (w *webAccess) login(login webLogin) (string, error) {
+	switch {
+	case login.Token != "":
+		if !equalSecret(login.Token, w.token) {
+			return "", fmt.Errorf("invalid token")
+		}
+	case login.PIN != "":
+		if time.Now().Before(w.locked) {
+			return "", fmt.Errorf("too many attempts, try again later")
+		}
+		if !equalSecret(login.PIN, w.pin) {
+			w.failures++
+			if w.failures >= maxPINFailures {
+				w.failures = 0
+				w.locked = time.Now().Add(pinLockout)
+			}
+			return "", fmt.Errorf("invalid PIN")
+		}
+		w.failures = 0
+	default:
+		return "", fmt.Errorf("PIN is required")
+	}
+
+	now := time.Now()
+	for id, session := range w.sessions {
+		if now.After(session.expires) {
+			delete(w.sessions, id)
+		}
+	}
+
+	session := randomHex(16)
+	w.sessions[session] = &webSession{expires: now.Add(webSessionTTL)}
+	return session, nil
+}
+
+// webAuthorized reports requests of logged in browsers
+func (s *LANServer) webAuthorized(r *http.Request) bool {
+	return s.webSession(r) != ""
+}
+
+// webSession returns session of logged in browser, empty for others
+func (s *LANServer) webSession(r *http.Request) string {
+	cookie, err := r.Cookie(webCookie)
+	if err != nil {
+		return ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.web.sessions[cookie.Value]
+	if !ok || time.Now().After(session.expires) {
+		return ""
+	}
+	return cookie.Value
+}
+
+// reserveWebUpload counts upload of size bytes into quota of the session
+func (s *LANServer) reserveWebUpload(id string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.web.sessions[id]
+	switch {
+	case !ok:
+		return fmt.Errorf("session expired")
+	case size > s.web.uploadLimit:
+		return fmt.Errorf("%w: file is larger than %d MiB", ErrWebUploadLimit, s.web.uploadLimit>>20)
+	case session.uploaded+size > s.web.sessionQuota:
+		return fmt.Errorf("%w: session can upload %d MiB more", ErrWebUploadLimit, (s.web.sessionQuota-session.uploaded)>>20)
+	}
+	session.uploaded += size
+	return nil
+}
+
+// releaseWebUpload returns size of failed upload to quota of the session
+func (s *LANServer) releaseWebUpload(id string, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.web.sessions[id]; ok {
+		session.uploaded -= size
+	}
+}
+
+// writeWebUpload saves file pushed by browser under a free name, existing
+// files are never replaced. Browsers on plain http have no WebCrypto,
+// so uploads without hash are checked by size only.
+func (s *LANServer) writeWebUpload(w http.ResponseWriter, r *http.Request, session string, entry mirror.Entry) (err error) {
+	if _, err := mirror.CleanPath(entry.Path); err != nil {
+		return err
+	}
+	if err := s.reserveWebUpload(session, entry.Size); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			s.releaseWebUpload(session, entry.Size)
+		}
+	}()
+
+	// body can't be longer than declared size that fits the quota
+	r.Body = http.MaxBytesReader(w, r.Body, entry.Size)
+
+	store := s.web.uploads
+	tmp, err := store.TempFile(webUploadFolder)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, s.limitDownload(r))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && entry.Hash == "" {
+		entry.Hash, err = mirror.HashFile(tmp.Name())
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Errorf("%w: body is larger than declared size", ErrWebUploadLimit)
+		}
+		return err
+	}
+
+	s.web.commitMu.Lock()
+	defer s.web.commitMu.Unlock()
+
+	entry.Path, err = store.FreePath(webUploadFolder, entry.Path)
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return store.Commit(webUploadFolder, entry, tmp.Name())
+}
+
+func equalSecret(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func randomPIN() string {
+	limit := big.NewInt(1)
+	for range pinDigits {
+		limit.Mul(limit, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%0*d", pinDigits, n)
+}
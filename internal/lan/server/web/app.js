@@ -0,0 +1,171 @@
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+let files = [];
+
+async function login(body) {
+	const resp = await fetch("web/login", {
+		method: "POST",
+		headers: { "Content-Type": "application/json" },
+		body: JSON.stringify(body),
+	});
+	if (!resp.ok) {
+		throw new Error((await resp.text()).trim());
+	}
+}
+
+async function start() {
+	// QR code links carry the token, it's not kept in history
+	const params = new URLSearchParams(location.search);
+	const token = params.get("token");
+	if (token) {
+		history.replaceState(null, "", location.pathname);
+		try {
+			await login({ token });
+		} catch (err) {
+			$("login-error").textContent = err.message;
+		}
+	}
+
+	const session = await fetch("web/session");
+	if (session.ok) {
+		showApp();
+	} else {
+		$("login").hidden = false;
+	}
+}
+
+$("login").addEventListener("submit", async (event) => {
+	event.preventDefault();
+	try {
+		await login({ pin: $("pin").value.trim() });
+		$("login").hidden = true;
+		showApp();
+	} catch (err) {
+		$("login-error").textContent = err.message;
+	}
+});
+
+function showApp() {
+	$("app").hidden = false;
+	loadFiles();
+}
+
+async function loadFiles() {
+	const resp = await fetch("api/files");
+	files = resp.ok ? await resp.json() : [];
+	files.sort((a, b) => a.name.localeCompare(b.name));
+	render();
+}
+
+function render() {
+	const query = $("filter").value.trim().toLowerCase();
+	const body = $("files");
+	body.replaceChildren();
+
+	const visible = files.filter((f) => f.name.toLowerCase().includes(query));
+	for (const file of visible) {
+		const row = document.createElement("tr");
+
+		const preview = document.createElement("td");
+		if ((file.mime_type || "").startsWith("image/")) {
+			const img = document.createElement("img");
+			img.loading = "lazy";
+			img.alt = "";
+			img.src = "api/thumbnail/" + encodeURIComponent(file.uuid) + "?size=96";
+			preview.append(img);
+		}
+
+		const name = document.createElement("td");
+		const link = document.createElement("a");
+		link.href = "api/download/" + encodeURIComponent(file.uuid);
+		link.download = file.name;
+		link.textContent = file.name;
+		name.append(link);
+
+		const size = document.createElement("td");
+		size.className = "size";
+		size.textContent = formatSize(file.size);
+
+		const date = document.createElement("td");
+		date.className = "date";
+		// zero time of files without metadata
+		const modTime = new Date(file.mod_time);
+		date.textContent = modTime.getFullYear() > 1 ? modTime.toLocaleString() : "";
+
+		row.append(preview, name, size, date);
+		body.append(row);
+	}
+	$("empty").hidden = visible.length > 0;
+}
+
+function formatSize(size) {
+	const units = ["B", "KiB", "MiB", "GiB", "TiB"];
+	let i = 0;
+	while (size >= 1024 && i < units.length - 1) {
+		size /= 1024;
+		i++;
+	}
+	return i === 0 ? size + " B" : size.toFixed(1) + " " + units[i];
+}
+
+function upload(file) {
+	const item = document.createElement("li");
+	item.textContent = file.name + ": 0%";
+	$("uploads").append(item);
+
+	const params = new URLSearchParams({
+		path: file.name,
+		size: file.size,
+		mtime: Math.floor(file.lastModified / 1000),
+	});
+
+	// XHR reports upload progress, fetch doesn't
+	const xhr = new XMLHttpRequest();
+	xhr.open("POST", "api/push?" + params);
+	xhr.upload.onprogress = (event) => {
+		if (event.lengthComputable) {
+			item.textContent = file.name + ": " + Math.round((event.loaded / event.total) * 100) + "%";
+		}
+	};
+	xhr.onload = () => {
+		if (xhr.status >= 200 && xhr.status < 300) {
+			item.textContent = file.name + ": done";
+		} else {
+			item.textContent = file.name + ": " + xhr.responseText.trim();
+			item.className = "error";
+		}
+	};
+	xhr.onerror = () => {
+		item.textContent = file.name + ": connection failed";
+		item.className = "error";
+	};
+	xhr.send(file);
+}
+
+$("upload-input").addEventListener("change", (event) => {
+	for (const file of event.target.files) {
+		upload(file);
+	}
+	event.target.value = "";
+});
+
+const dropZone = $("upload");
+dropZone.addEventListener("dragover", (event) => {
+	event.preventDefault();
+	dropZone.classList.add("dragging");
+});
+dropZone.addEventListener("dragleave", () => dropZone.classList.remove("dragging"));
+dropZone.addEventListener("drop", (event) => {
+	event.preventDefault();
+	dropZone.classList.remove("dragging");
+	for (const file of event.dataTransfer.files) {
+		upload(file);
+	}
+});
+
+$("filter").addEventListener("input", render);
+$("refresh").addEventListener("click", loadFiles);
+
+start();
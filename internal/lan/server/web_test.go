@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/0x0FACED/rapid/configs"
+)
+
+// webServer returns server with logged in browser session
+func webServer(t *testing.T, cfg configs.LANServerConfig) (*LANServer, *http.Cookie) {
+	t.Helper()
+
+	cfg.DownloadsDir = t.TempDir()
+	s := New(cfg)
+	token, _ := s.WebAccess()
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/web/login", strings.NewReader(`{"token":"`+token+`"}`)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("login: %d %s", rec.Code, rec.Body)
+	}
+	return s, rec.Result().Cookies()[0]
+}
+
+// upload pushes body with declared size and returns status
+func upload(s *LANServer, cookie *http.Cookie, name, body string, size int) int {
+	query := url.Values{"path": {name}, "size": {strconv.Itoa(size)}, "mtime": {"0"}}
+	r := httptest.NewRequest(http.MethodPost, "/api/push?"+query.Encode(), strings.NewReader(body))
+	r.AddCookie(cookie)
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, r)
+	return rec.Code
+}
+
+func TestWebUploadKeepsExistingFiles(t *testing.T) {
+	s, cookie := webServer(t, configs.LANServerConfig{})
+
+	for _, body := range []string{"first", "second", "third"} {
+		if code := upload(s, cookie, "photo.jpg", body, len(body)); code != http.StatusNoContent {
+			t.Fatalf("upload %s: status %d", body, code)
+		}
+	}
+
+	dir := filepath.Join(s.DownloadsDir(), webUploadFolder)
+	for name, want := range map[string]string{"photo.jpg": "first", "photo (1).jpg": "second", "photo (2).jpg": "third"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, want)
+		}
+	}
+}
+
+func TestWebUploadLimits(t *testing.T) {
+	s, cookie := webServer(t, configs.LANServerConfig{WebUploadLimit: 10, WebSessionQuota: 16})
+
+	tests := []struct {
+		name string
+		body string
+		size int
+		want int
+	}{
+		{"big.txt", "eleven byte", 11, http.StatusRequestEntityTooLarge},
+		// body is longer than declared size
+		{"liar.txt", "eleven byte", 4, http.StatusRequestEntityTooLarge},
+		{"a.txt", "ten bytes!", 10, http.StatusNoContent},
+		// failed uploads don't use quota, so 6 bytes are left
+		{"b.txt", "seven b", 7, http.StatusRequestEntityTooLarge},
+		{"c.txt", "six by", 6, http.StatusNoContent},
+		{"d.txt", "x", 1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		if code := upload(s, cookie, tt.name, tt.body, tt.size); code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
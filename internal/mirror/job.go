@@ -0,0 +1,153 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrInvalidJob = errors.New("invalid sync job")
+
+// Peer is remote side of the sync, it's implemented by LAN and WebRTC transports
+type Peer interface {
+	// Manifest returns state of the remote folder
+	Manifest(ctx context.Context, folder string) (*Manifest, error)
+	// Push sends local file to the remote folder
+	Push(ctx context.Context, folder string, entry Entry, local string) error
+	Delete(ctx context.Context, folder, path string) error
+}
+
+// Job mirrors local directory to folder of the peer, it's one-way:
+// changes made on the remote side are overwritten
+type Job struct {
+	Name   string `json:"name"`
+	Root   string `json:"root"`   // local directory
+	Folder string `json:"folder"` // name of the remote folder
+	Peer   string `json:"peer"`   // LAN address or WebRTC peer name
+	// sync token shown by the receiving LAN device
+	Token string `json:"token,omitempty"`
+	// delete remote files that don't exist locally
+	Delete bool `json:"delete"`
+	// 0 means manual runs only
+	Interval Duration `json:"interval"`
+
+	// last local manifest, used to skip hashing of unchanged files
+	last *Manifest
+	mu   sync.Mutex
+}
+
+func (j *Job) Validate() error {
+	if j.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidJob)
+	}
+	if j.Root == "" {
+		return fmt.Errorf("%w: root is required", ErrInvalidJob)
+	}
+	if err := ValidateFolder(j.Folder); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidJob, err)
+	}
+	if j.Interval < 0 {
+		return fmt.Errorf("%w: negative interval", ErrInvalidJob)
+	}
+	return nil
+}
+
+// Plan compares local directory with the remote folder
+func (j *Job) Plan(ctx context.Context, peer Peer) (Plan, error) {
+	j.mu.Lock()
+	local, err := BuildManifest(j.Root, j.last)
+	if err == nil {
+		j.last = local
+	}
+	j.mu.Unlock()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	remote, err := peer.Manifest(ctx, j.Folder)
+	if err != nil {
+		return Plan{}, fmt.Errorf("failed to get manifest of %s: %w", j.Folder, err)
+	}
+	return Diff(local, remote, j.Delete), nil
+}
+
+// Apply transfers new and changed files and deletes removed ones,
+// it continues after failed files and returns all errors
+func (j *Job) Apply(ctx context.Context, peer Peer, plan Plan) error {
+	var errs []error
+	for _, change := range plan.Changes {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		var err error
+		switch change.Op {
+		case OpCreate, OpUpdate:
+			local := filepath.Join(j.Root, filepath.FromSlash(change.Entry.Path))
+			err = peer.Push(ctx, j.Folder, change.Entry, local)
+		case OpDelete:
+			err = peer.Delete(ctx, j.Folder, change.Entry.Path)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", change.Entry.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run plans the job and applies it unless dryRun is set
+func (j *Job) Run(ctx context.Context, peer Peer, dryRun bool) (Plan, error) {
+	plan, err := j.Plan(ctx, peer)
+	if err != nil || dryRun {
+		return plan, err
+	}
+	return plan, j.Apply(ctx, peer, plan)
+}
+
+// Schedule runs the job every Interval until ctx is done, peer is
+// resolved before every run because its address may change
+func (j *Job) Schedule(ctx context.Context, resolve func(*Job) (Peer, error)) {
+	if j.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(j.Interval))
+	defer ticker.Stop()
+
+	for {
+		peer, err := resolve(j)
+		if err != nil {
+			log.Printf("Sync job %s: %v", j.Name, err)
+		} else if plan, err := j.Run(ctx, peer, false); err != nil {
+			log.Printf("Sync job %s failed: %v", j.Name, err)
+		} else if !plan.Empty() {
+			log.Printf("Sync job %s: %d changes synced", j.Name, len(plan.Changes))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Duration is time.Duration written as "10m" in job files
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
@@ -0,0 +1,126 @@
+package mirror
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrInvalidPath = errors.New("invalid path")
+
+// Entry describes one file of the mirrored folder
+type Entry struct {
+	Path    string    `json:"path"` // slash separated, relative to the folder
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"` // hex sha256
+}
+
+// Manifest is the state of the folder, entries are keyed by path
+type Manifest struct {
+	Entries map[string]Entry `json:"entries"`
+}
+
+func NewManifest() *Manifest {
+	return &Manifest{Entries: make(map[string]Entry)}
+}
+
+// BuildManifest walks root recursively, hidden files and directories
+// are skipped. Hashes from previous are reused for files whose size
+// and modification time are unchanged
+func BuildManifest(root string, previous *Manifest) (*Manifest, error) {
+	manifest := NewManifest()
+
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+
+		entry := Entry{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime().UTC().Truncate(time.Second),
+		}
+
+		if old, ok := previous.get(entry.Path); ok && old.Size == entry.Size && old.ModTime.Equal(entry.ModTime) {
+			entry.Hash = old.Hash
+		} else {
+			entry.Hash, err = HashFile(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		manifest.Entries[entry.Path] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (m *Manifest) get(path string) (Entry, bool) {
+	if m == nil {
+		return Entry{}, false
+	}
+	entry, ok := m.Entries[path]
+	return entry, ok
+}
+
+func HashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CleanPath checks that p is relative and stays inside the folder
+func CleanPath(p string) (string, error) {
+	if p == "" || strings.Contains(p, "\\") || path.IsAbs(p) {
+		return "", ErrInvalidPath
+	}
+
+	clean := path.Clean(p)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", ErrInvalidPath
+	}
+	for _, part := range strings.Split(clean, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", ErrInvalidPath
+		}
+	}
+	return clean, nil
+}
@@ -0,0 +1,95 @@
+package mirror
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Op int
+
+const (
+	OpCreate Op = iota + 1
+	OpUpdate
+	OpDelete
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpCreate:
+		return "+"
+	case OpUpdate:
+		return "~"
+	case OpDelete:
+		return "-"
+	}
+	return "?"
+}
+
+type Change struct {
+	Op    Op
+	Entry Entry
+}
+
+// Plan is list of changes that make remote folder equal to local one
+type Plan struct {
+	Changes []Change
+}
+
+// Diff compares local and remote manifests, files that exist only
+// on the remote side are deleted when deleteExtra is set
+func Diff(local, remote *Manifest, deleteExtra bool) Plan {
+	var plan Plan
+
+	for p, entry := range local.Entries {
+		old, ok := remote.get(p)
+		switch {
+		case !ok:
+			plan.Changes = append(plan.Changes, Change{Op: OpCreate, Entry: entry})
+		case old.Hash != entry.Hash || old.Size != entry.Size:
+			plan.Changes = append(plan.Changes, Change{Op: OpUpdate, Entry: entry})
+		}
+	}
+
+	if deleteExtra && remote != nil {
+		for p, entry := range remote.Entries {
+			if _, ok := local.Entries[p]; !ok {
+				plan.Changes = append(plan.Changes, Change{Op: OpDelete, Entry: entry})
+			}
+		}
+	}
+
+	sort.Slice(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].Entry.Path < plan.Changes[j].Entry.Path
+	})
+	return plan
+}
+
+func (p Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Bytes returns size of files that will be transferred
+func (p Plan) Bytes() int64 {
+	var total int64
+	for _, change := range p.Changes {
+		if change.Op != OpDelete {
+			total += change.Entry.Size
+		}
+	}
+	return total
+}
+
+// String returns diff for dry run, one change per line
+func (p Plan) String() string {
+	if p.Empty() {
+		return "Nothing to sync\n"
+	}
+
+	var sb strings.Builder
+	for _, change := range p.Changes {
+		fmt.Fprintf(&sb, "%s %s (%d bytes)\n", change.Op, change.Entry.Path, change.Entry.Size)
+	}
+	fmt.Fprintf(&sb, "%d changes, %d bytes to transfer\n", len(p.Changes), p.Bytes())
+	return sb.String()
+}
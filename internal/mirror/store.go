@@ -0,0 +1,184 @@
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrInvalidFolder = errors.New("invalid folder name")
+	ErrHashMismatch  = errors.New("hash mismatch")
+)
+
+// DefaultDir is subdirectory of downloads with folders mirrored to us
+const DefaultDir = "sync"
+
+// tempPrefix makes incomplete files hidden for manifests
+const tempPrefix = ".rapid-sync-"
+
+// FreePath gives up after this many taken names
+const maxFreePathTries = 1000
+
+// Store keeps folders mirrored to us, each folder is
+// a subdirectory of root
+type Store struct {
+	root string
+}
+
+func NewStore(root string) *Store {
+	return &Store{root: root}
+}
+
+// ValidateFolder checks that name is a single visible path element
+func ValidateFolder(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return ErrInvalidFolder
+	}
+	return nil
+}
+
+func (s *Store) folderDir(folder string) (string, error) {
+	if err := ValidateFolder(folder); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, folder), nil
+}
+
+func (s *Store) filePath(folder, p string) (string, error) {
+	dir, err := s.folderDir(folder)
+	if err != nil {
+		return "", err
+	}
+	clean, err := CleanPath(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// Manifest returns state of the folder, missing folder is empty
+func (s *Store) Manifest(folder string) (*Manifest, error) {
+	dir, err := s.folderDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return NewManifest(), nil
+	}
+	return BuildManifest(dir, nil)
+}
+
+// TempFile creates hidden file in the folder for incoming entry,
+// it's moved into place by Commit
+func (s *Store) TempFile(folder string) (*os.File, error) {
+	dir, err := s.folderDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, tempPrefix+"*")
+}
+
+// Write stores entry read from r
+func (s *Store) Write(folder string, entry Entry, r io.Reader) error {
+	if _, err := s.filePath(folder, entry.Path); err != nil {
+		return err
+	}
+
+	tmp, err := s.TempFile(folder)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tmp, io.LimitReader(r, entry.Size+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return s.Commit(folder, entry, tmp.Name())
+}
+
+// Commit checks size and hash of the temp file and moves it to entry path,
+// temp file is removed on error
+func (s *Store) Commit(folder string, entry Entry, tmp string) (err error) {
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	dst, err := s.filePath(folder, entry.Path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(tmp)
+	if err != nil {
+		return err
+	}
+	if info.Size() != entry.Size {
+		return fmt.Errorf("size mismatch: expected %d, got %d", entry.Size, info.Size())
+	}
+	hash, err := HashFile(tmp)
+	if err != nil {
+		return err
+	}
+	if hash != entry.Hash {
+		return ErrHashMismatch
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, entry.ModTime, entry.ModTime)
+}
+
+// FreePath returns p if there is no such file in the folder, otherwise
+// p with a number before the extension, like "photo (1).jpg"
+func (s *Store) FreePath(folder, p string) (string, error) {
+	clean, err := CleanPath(p)
+	if err != nil {
+		return "", err
+	}
+
+	ext := path.Ext(clean)
+	base := strings.TrimSuffix(clean, ext)
+	candidate := clean
+	for i := 1; i <= maxFreePathTries; i++ {
+		name, err := s.filePath(folder, candidate)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Lstat(name); errors.Is(err, os.ErrNotExist) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	return "", fmt.Errorf("no free name for %s", clean)
+}
+
+func (s *Store) Delete(folder, p string) error {
+	name, err := s.filePath(folder, p)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package mirror
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// TokenHeader carries sync token of the receiving LAN device,
+// it's required for manifests and pushes
+const TokenHeader = "X-Rapid-Sync-Token"
+
+// NewToken returns random token that peers use to mirror folders to us
+func NewToken() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidToken compares tokens in constant time, empty token is never valid
+func ValidToken(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -0,0 +1,56 @@
+// model/clipboard.go
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clipboard formats
+const (
+	ClipboardText  = "text"
+	ClipboardImage = "image"
+)
+
+// MaxClipboardSize limits synced clipboard contents, larger
+// contents are not synced (screenshots are the usual case)
+const MaxClipboardSize = 128 * 1024
+
+// ClipboardUpdate is clipboard contents sent to a trusted peer
+type ClipboardUpdate struct {
+	ID     string    `json:"id"`     // uuid
+	From   string    `json:"from"`   // instance name of the sender
+	Format string    `json:"format"` // text or image (png)
+	Data   []byte    `json:"data"`
+	Sent   time.Time `json:"sent"`
+}
+
+func NewClipboardUpdate(from, format string, data []byte) ClipboardUpdate {
+	return ClipboardUpdate{
+		ID:     uuid.NewString(),
+		From:   from,
+		Format: format,
+		Data:   data,
+		Sent:   time.Now(),
+	}
+}
+
+func (u ClipboardUpdate) Validate() error {
+	if u.ID == "" {
+		return fmt.Errorf("clipboard update ID is required")
+	}
+	switch u.Format {
+	case ClipboardText, ClipboardImage:
+	default:
+		return fmt.Errorf("unknown clipboard format: %s", u.Format)
+	}
+	if len(u.Data) == 0 {
+		return fmt.Errorf("clipboard data is required")
+	}
+	if len(u.Data) > MaxClipboardSize {
+		return fmt.Errorf("clipboard data is too large: %d bytes", len(u.Data))
+	}
+	return nil
+}
@@ -3,14 +3,96 @@ package model
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"time"
 )
 
+// File is the local record of a shared or received file, peers get
+// FileInfo instead, so the local Path never leaves the device
 type File struct {
-	ID   string `json:"uuid"` // uuid
-	Name string `json:"name"` // filename
-	Path string `json:"path"` // path to file + filename
-	Size int64  `json:"size"` // size in bytes
+	ID       string      // uuid
+	Name     string      // filename
+	Path     string      // path to file + filename, empty for files of peers
+	Size     int64       // size in bytes
+	MIMEType string      // detected when shared, empty if unknown
+	ModTime  time.Time   // zero if unknown
+	Mode     os.FileMode // unix permission bits, 0 if unknown
+	Hash     string      // hex sha256, empty if not computed
+	SharedAt time.Time   // when the file was shared
+	// content-defined chunks in file order, filled only by chunk manifest requests
+	Chunks []Chunk
+}
+
+// FileInfo is the wire form of File sent to peers
+type FileInfo struct {
+	ID       string    `json:"uuid"`
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	MIMEType string    `json:"mime_type,omitempty"`
+	ModTime  time.Time `json:"mod_time"`
+	Mode     uint32    `json:"mode,omitempty"`
+	Hash     string    `json:"hash,omitempty"`
+	SharedAt time.Time `json:"shared_at"`
+	Chunks   []Chunk   `json:"chunks,omitempty"`
+}
+
+// Info returns public part of the file
+func (f File) Info() FileInfo {
+	return FileInfo{
+		ID:       f.ID,
+		Name:     f.Name,
+		Size:     f.Size,
+		MIMEType: f.MIMEType,
+		ModTime:  f.ModTime,
+		Mode:     uint32(f.Mode.Perm()),
+		Hash:     f.Hash,
+		SharedAt: f.SharedAt,
+		Chunks:   f.Chunks,
+	}
+}
+
+// File returns record of the peer's file, it has no Path
+func (i FileInfo) File() File {
+	return File{
+		ID:       i.ID,
+		Name:     i.Name,
+		Size:     i.Size,
+		MIMEType: i.MIMEType,
+		ModTime:  i.ModTime,
+		Mode:     os.FileMode(i.Mode).Perm(),
+		Hash:     i.Hash,
+		SharedAt: i.SharedAt,
+		Chunks:   i.Chunks,
+	}
+}
+
+// Infos converts files for sending to peers
+func Infos(files []File) []FileInfo {
+	infos := make([]FileInfo, 0, len(files))
+	for _, file := range files {
+		infos = append(infos, file.Info())
+	}
+	return infos
+}
+
+// Files converts files received from a peer
+func Files(infos []FileInfo) []File {
+	files := make([]File, 0, len(infos))
+	for _, info := range infos {
+		files = append(files, info.File())
+	}
+	return files
+}
+
+// FileHashHeader carries File.Hash in download responses, it is sha256
+// of the whole decoded file regardless of content encoding and ranges
+const FileHashHeader = "X-File-Sha256"
+
+// Chunk is a part of the file addressed by its content
+type Chunk struct {
+	Hash string `json:"hash"` // hex sha256
+	Size int64  `json:"size"`
 }
 
 // To see not 123213131321 bytes
@@ -0,0 +1,83 @@
+// model/message.go
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message kinds
+const (
+	MessageText      = "text"
+	MessageLink      = "link"
+	MessageClipboard = "clipboard"
+)
+
+// MaxMessageSize limits text of one message in bytes
+const MaxMessageSize = 64 * 1024
+
+// Message is short text sent to a peer over LAN or WebRTC
+type Message struct {
+	ID   string    `json:"id"`   // uuid
+	From string    `json:"from"` // instance name of the sender
+	Kind string    `json:"kind"` // text, link or clipboard
+	Text string    `json:"text"`
+	Sent time.Time `json:"sent"`
+}
+
+// NewMessage creates message, plain text that is a URL becomes a link
+func NewMessage(from, kind, text string) Message {
+	if kind == MessageText && isLink(text) {
+		kind = MessageLink
+	}
+
+	return Message{
+		ID:   uuid.NewString(),
+		From: from,
+		Kind: kind,
+		Text: text,
+		Sent: time.Now(),
+	}
+}
+
+func (m Message) Validate() error {
+	if m.ID == "" {
+		return fmt.Errorf("message ID is required")
+	}
+	switch m.Kind {
+	case MessageText, MessageLink, MessageClipboard:
+	default:
+		return fmt.Errorf("unknown message kind: %s", m.Kind)
+	}
+	if m.Text == "" {
+		return fmt.Errorf("message text is required")
+	}
+	if len(m.Text) > MaxMessageSize {
+		return fmt.Errorf("message is too long: %d bytes", len(m.Text))
+	}
+	return nil
+}
+
+func (m Message) String() string {
+	return fmt.Sprintf(
+		"Message[ID: %s, From: %s, Kind: %s, Size: %d]",
+		m.ID,
+		m.From,
+		m.Kind,
+		len(m.Text),
+	)
+}
+
+func isLink(text string) bool {
+	text = strings.TrimSpace(text)
+	if strings.ContainsAny(text, " \n\t") {
+		return false
+	}
+
+	u, err := url.Parse(text)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,63 @@
+package relay
+
+import (
+	"net"
+	"sync/atomic"
+
+	"github.com/0x0FACED/rapid/pkg/ratelimit"
+	"github.com/pion/turn/v4"
+)
+
+// limitedGenerator wraps every relayed socket with rate limit and quota
+type limitedGenerator struct {
+	turn.RelayAddressGenerator
+	relay *Relay
+}
+
+func (g *limitedGenerator) AllocatePacketConn(network string, requestedPort int) (net.PacketConn, net.Addr, error) {
+	conn, addr, err := g.RelayAddressGenerator.AllocatePacketConn(network, requestedPort)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &limitedPacketConn{
+		PacketConn: conn,
+		limiter:    ratelimit.New(g.relay.config.AllocationRate),
+		total:      g.relay.total,
+		quota:      g.relay.config.AllocationQuota,
+	}, addr, nil
+}
+
+// limitedPacketConn drops datagrams over the limits instead of blocking,
+// same as a congested link would do, so ICE and SCTP adapt to it
+type limitedPacketConn struct {
+	net.PacketConn
+
+	limiter *ratelimit.Limiter
+	total   *ratelimit.Limiter
+	quota   int64
+	used    atomic.Int64
+}
+
+func (c *limitedPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil || c.allow(n) {
+			return n, addr, err
+		}
+	}
+}
+
+func (c *limitedPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if !c.allow(len(p)) {
+		return len(p), nil
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func (c *limitedPacketConn) allow(n int) bool {
+	if c.quota > 0 && c.used.Add(int64(n)) > c.quota {
+		return false
+	}
+	return c.limiter.Allow(n) && c.total.Allow(n)
+}
@@ -0,0 +1,211 @@
+package relay
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/pkg/ratelimit"
+	"github.com/pion/turn/v4"
+)
+
+const defaultRealm = "rapid"
+
+// Relay is TURN/STUN server for peers that can't reach each other directly
+type Relay struct {
+	server *turn.Server
+	// shared by all allocations
+	total *ratelimit.Limiter
+
+	config configs.RelayConfig
+}
+
+func New(cfg configs.RelayConfig) (*Relay, error) {
+	if cfg.Realm == "" {
+		cfg.Realm = defaultRealm
+	}
+	if len(cfg.Users) == 0 {
+		return nil, errors.New("at least one relay user is required")
+	}
+	if net.ParseIP(cfg.PublicIP) == nil {
+		return nil, fmt.Errorf("invalid public IP: %q", cfg.PublicIP)
+	}
+	if cfg.MinPort > cfg.MaxPort {
+		return nil, fmt.Errorf("invalid port range: %d-%d", cfg.MinPort, cfg.MaxPort)
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, errors.New("both TLS certificate and key are required")
+	}
+
+	return &Relay{
+		total:  ratelimit.New(cfg.TotalRate),
+		config: cfg,
+	}, nil
+}
+
+// Start opens listeners and starts serving, it doesn't block
+func (r *Relay) Start() error {
+	udpConn, err := net.ListenPacket("udp4", r.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen udp: %w", err)
+	}
+
+	tcpListener, err := net.Listen("tcp4", r.config.Address)
+	if err != nil {
+		udpConn.Close()
+		return fmt.Errorf("failed to listen tcp: %w", err)
+	}
+
+	listeners := []turn.ListenerConfig{{
+		Listener:              tcpListener,
+		RelayAddressGenerator: r.generator(),
+	}}
+
+	if r.config.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(r.config.TLSCertFile, r.config.TLSKeyFile)
+		if err != nil {
+			udpConn.Close()
+			tcpListener.Close()
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+
+		tlsListener, err := tls.Listen("tcp4", r.config.TLSAddress, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+		if err != nil {
+			udpConn.Close()
+			tcpListener.Close()
+			return fmt.Errorf("failed to listen tls: %w", err)
+		}
+
+		listeners = append(listeners, turn.ListenerConfig{
+			Listener:              tlsListener,
+			RelayAddressGenerator: r.generator(),
+		})
+	}
+
+	server, err := turn.NewServer(turn.ServerConfig{
+		Realm:       r.config.Realm,
+		AuthHandler: r.authenticate,
+		PacketConnConfigs: []turn.PacketConnConfig{{
+			PacketConn:            udpConn,
+			RelayAddressGenerator: r.generator(),
+		}},
+		ListenerConfigs: listeners,
+	})
+	if err != nil {
+		udpConn.Close()
+		for _, l := range listeners {
+			l.Listener.Close()
+		}
+		return err
+	}
+
+	r.server = server
+	fmt.Println("Starting TURN relay on", r.config.Address)
+	return nil
+}
+
+func (r *Relay) Close() error {
+	if r.server == nil {
+		return nil
+	}
+	return r.server.Close()
+}
+
+func (r *Relay) AllocationCount() int {
+	if r.server == nil {
+		return 0
+	}
+	return r.server.AllocationCount()
+}
+
+// ICEServers returns client settings for the user, ready for ICE config
+func (r *Relay) ICEServers(username string) []configs.ICEServerConfig {
+	password, ok := r.config.Users[username]
+	if !ok {
+		return nil
+	}
+
+	_, port, _ := net.SplitHostPort(r.config.Address)
+	hostPort := net.JoinHostPort(r.config.PublicIP, port)
+
+	urls := []string{
+		"turn:" + hostPort + "?transport=udp",
+		"turn:" + hostPort + "?transport=tcp",
+	}
+	if r.config.TLSCertFile != "" {
+		_, tlsPort, _ := net.SplitHostPort(r.config.TLSAddress)
+		urls = append(urls, "turns:"+net.JoinHostPort(r.config.PublicIP, tlsPort)+"?transport=tcp")
+	}
+
+	servers := []configs.ICEServerConfig{
+		{URLs: []string{"stun:" + hostPort}},
+	}
+	for _, url := range urls {
+		servers = append(servers, configs.ICEServerConfig{
+			URLs:       []string{url},
+			Username:   username,
+			Credential: password,
+		})
+	}
+	return servers
+}
+
+func (r *Relay) authenticate(username, realm string, _ net.Addr) ([]byte, bool) {
+	password, ok := r.config.Users[username]
+	if !ok {
+		return nil, false
+	}
+	return turn.GenerateAuthKey(username, realm, password), true
+}
+
+func (r *Relay) generator() turn.RelayAddressGenerator {
+	var base turn.RelayAddressGenerator
+	if r.config.MinPort == 0 && r.config.MaxPort == 0 {
+		base = &turn.RelayAddressGeneratorStatic{
+			RelayAddress: net.ParseIP(r.config.PublicIP),
+			Address:      "0.0.0.0",
+		}
+	} else {
+		base = &turn.RelayAddressGeneratorPortRange{
+			RelayAddress: net.ParseIP(r.config.PublicIP),
+			Address:      "0.0.0.0",
+			MinPort:      r.config.MinPort,
+			MaxPort:      r.config.MaxPort,
+		}
+	}
+
+	return &limitedGenerator{
+		RelayAddressGenerator: base,
+		relay:                 r,
+	}
+}
+
+// ParsePortRange parses "50000-50100", empty string means any port
+func ParsePortRange(s string) (uint16, uint16, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+
+	minStr, maxStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid port range %q", s)
+	}
+
+	minPort, err := strconv.ParseUint(minStr, 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %q", s)
+	}
+	maxPort, err := strconv.ParseUint(maxStr, 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %q", s)
+	}
+
+	return uint16(minPort), uint16(maxPort), nil
+}
@@ -0,0 +1,191 @@
+package relay
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/pion/webrtc/v4"
+)
+
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	return port
+}
+
+func startRelay(t *testing.T, cfg configs.RelayConfig) *Relay {
+	t.Helper()
+
+	cfg.Address = net.JoinHostPort("127.0.0.1", freePort(t))
+	cfg.PublicIP = "127.0.0.1"
+	if cfg.Users == nil {
+		cfg.Users = map[string]string{"alice": "secret"}
+	}
+
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+// newRelayedPeer makes peer connection that may use relayed candidates only
+func newRelayedPeer(t *testing.T, servers []configs.ICEServerConfig) *webrtc.PeerConnection {
+	t.Helper()
+
+	var iceServers []webrtc.ICEServer
+	for _, s := range servers {
+		iceServers = append(iceServers, webrtc.ICEServer{
+			URLs:       s.URLs,
+			Username:   s.Username,
+			Credential: s.Credential,
+		})
+	}
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{
+		ICEServers:         iceServers,
+		ICETransportPolicy: webrtc.ICETransportPolicyRelay,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc
+}
+
+// connect exchanges complete descriptions without trickle
+func connect(t *testing.T, offerer, answerer *webrtc.PeerConnection) {
+	t.Helper()
+
+	offer, err := offerer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(offerer)
+	if err := offerer.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+
+	if err := answerer.SetRemoteDescription(*offerer.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := answerer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered = webrtc.GatheringCompletePromise(answerer)
+	if err := answerer.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+
+	if err := offerer.SetRemoteDescription(*answerer.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPeersConnectThroughRelay(t *testing.T) {
+	r := startRelay(t, configs.RelayConfig{})
+
+	// turn over udp only, stun and tcp urls are skipped
+	servers := r.ICEServers("alice")[1:2]
+
+	offerer := newRelayedPeer(t, servers)
+	answerer := newRelayedPeer(t, servers)
+
+	received := make(chan string, 1)
+	answerer.OnDataChannel(func(dc *webrtc.DataChannel) {
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+			received <- string(msg.Data)
+		})
+	})
+
+	dc, err := offerer.CreateDataChannel("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc.OnOpen(func() {
+		_ = dc.SendText("hello through relay")
+	})
+
+	connect(t, offerer, answerer)
+
+	select {
+	case msg := <-received:
+		if msg != "hello through relay" {
+			t.Fatalf("received %q", msg)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("peers didn't connect through relay")
+	}
+
+	pair, err := offerer.SCTP().Transport().ICETransport().GetSelectedCandidatePair()
+	if err != nil || pair == nil {
+		t.Fatalf("no selected candidate pair: %v", err)
+	}
+	if pair.Local.Typ != webrtc.ICECandidateTypeRelay {
+		t.Errorf("local candidate type = %s, want relay", pair.Local.Typ)
+	}
+	if n := r.AllocationCount(); n < 2 {
+		t.Errorf("relay has %d allocations, want at least 2", n)
+	}
+}
+
+func TestRelayRejectsWrongPassword(t *testing.T) {
+	r := startRelay(t, configs.RelayConfig{})
+
+	servers := r.ICEServers("alice")[1:2]
+	servers[0].Credential = "wrong"
+
+	pc := newRelayedPeer(t, servers)
+	if _, err := pc.CreateDataChannel("test", nil); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+
+	if n := r.AllocationCount(); n != 0 {
+		t.Errorf("relay has %d allocations for wrong credentials", n)
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		in       string
+		min, max uint16
+		err      bool
+	}{
+		{"", 0, 0, false},
+		{"50000-50100", 50000, 50100, false},
+		{"50000", 0, 0, true},
+		{"a-b", 0, 0, true},
+		{"1-70000", 0, 0, true},
+	}
+	for _, tt := range tests {
+		minPort, maxPort, err := ParsePortRange(tt.in)
+		if (err != nil) != tt.err || minPort != tt.min || maxPort != tt.max {
+			t.Errorf("ParsePortRange(%q) = %d, %d, %v", tt.in, minPort, maxPort, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// Client is a peer connection to the signaling server
+type Client struct {
+	conn *websocket.Conn
+	role string
+
+	mu sync.Mutex
+}
+
+// Dial joins the room and waits for role assignment
+func Dial(ctx context.Context, serverURL, code string) (*Client, error) {
+	if err := validateCode(code); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signaling url: %w", err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/ws"
+	}
+	q := u.Query()
+	q.Set("room", code)
+	u.RawQuery = q.Encode()
+
+	origin := "http://" + u.Host
+	cfg, err := websocket.NewConfig(u.String(), origin)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := cfg.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to signaling server: %w", err)
+	}
+	conn.MaxPayloadBytes = maxMessageSize
+
+	c := &Client{conn: conn}
+
+	msg, err := c.Receive()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	switch msg.Type {
+	case TypeJoined:
+		c.role = msg.Role
+	case TypeError:
+		conn.Close()
+		return nil, errors.New(msg.Error)
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("unexpected signaling message: %s", msg.Type)
+	}
+
+	return c, nil
+}
+
+// Role is RoleOfferer for the first peer in the room
+func (c *Client) Role() string {
+	return c.role
+}
+
+func (c *Client) Send(msgType string, payload any) error {
+	msg := Message{Type: msgType}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = data
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return websocket.JSON.Send(c.conn, msg)
+}
+
+// Receive blocks until next message, must be called from one goroutine
+func (c *Client) Receive() (Message, error) {
+	var msg Message
+	err := websocket.JSON.Receive(c.conn, &msg)
+	return msg, err
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
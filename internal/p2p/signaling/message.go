@@ -0,0 +1,69 @@
+package signaling
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Message types
+const (
+	// server -> client, sent after join with assigned role
+	TypeJoined = "joined"
+	// server -> offerer, second peer is in the room
+	TypePeerJoined = "peer_joined"
+	// server -> client, other peer disconnected
+	TypePeerLeft = "peer_left"
+	// server -> client, request rejected
+	TypeError = "error"
+
+	// relayed between peers as is
+	TypeOffer           = "offer"
+	TypeAnswer          = "answer"
+	TypeCandidate       = "candidate"
+	TypeEndOfCandidates = "end_of_candidates"
+	TypeBye             = "bye"
+)
+
+// Peer roles in the room
+const (
+	RoleOfferer  = "offerer"
+	RoleAnswerer = "answerer"
+)
+
+const (
+	maxCodeLength  = 32
+	maxMessageSize = 64 * 1024
+)
+
+var (
+	ErrRoomFull     = errors.New("room is full")
+	ErrInvalidCode  = errors.New("invalid room code")
+	ErrTooManyRooms = errors.New("too many rooms")
+)
+
+type Message struct {
+	Type    string          `json:"type"`
+	Role    string          `json:"role,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+func isRelayed(msgType string) bool {
+	switch msgType {
+	case TypeOffer, TypeAnswer, TypeCandidate, TypeEndOfCandidates, TypeBye:
+		return true
+	}
+	return false
+}
+
+func validateCode(code string) error {
+	if code == "" || len(code) > maxCodeLength {
+		return ErrInvalidCode
+	}
+	for _, r := range code {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return ErrInvalidCode
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package signaling
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/0x0FACED/rapid/configs"
+	"golang.org/x/net/websocket"
+)
+
+const defaultMaxRooms = 1024
+
+// Server pairs two peers by room code and relays SDP and ICE candidates
+// between them. It never looks inside relayed payloads.
+type Server struct {
+	httpServer *http.Server
+	rooms      map[string]*room
+	mu         sync.Mutex
+
+	config configs.SignalingConfig
+}
+
+type room struct {
+	peers [2]*peer
+}
+
+type peer struct {
+	conn *websocket.Conn
+	role string
+	mu   sync.Mutex
+}
+
+func (p *peer) send(msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return websocket.JSON.Send(p.conn, msg)
+}
+
+func NewServer(cfg configs.SignalingConfig) *Server {
+	if cfg.MaxRooms <= 0 {
+		cfg.MaxRooms = defaultMaxRooms
+	}
+
+	mux := http.NewServeMux()
+
+	server := &Server{
+		httpServer: &http.Server{
+			Addr:    cfg.Address,
+			Handler: mux,
+		},
+		rooms:  make(map[string]*room),
+		config: cfg,
+	}
+	server.RegisterHandlers(mux)
+	return server
+}
+
+func (s *Server) RegisterHandlers(mux *http.ServeMux) {
+	mux.Handle("/ws", websocket.Server{
+		Handler: s.handleWS,
+		// native clients and browsers from any origin are allowed
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+	})
+}
+
+func (s *Server) Start() error {
+	fmt.Println("Starting signaling server on", s.config.Address)
+	return s.httpServer.ListenAndServe()
+}
+
+func (s *Server) Close() error {
+	return s.httpServer.Close()
+}
+
+func (s *Server) handleWS(conn *websocket.Conn) {
+	defer conn.Close()
+	conn.MaxPayloadBytes = maxMessageSize
+
+	p := &peer{conn: conn}
+	code := conn.Request().URL.Query().Get("room")
+
+	other, err := s.join(code, p)
+	if err != nil {
+		_ = p.send(Message{Type: TypeError, Error: err.Error()})
+		return
+	}
+	defer s.leave(code, p)
+
+	if err := p.send(Message{Type: TypeJoined, Role: p.role}); err != nil {
+		return
+	}
+	if other != nil {
+		_ = other.send(Message{Type: TypePeerJoined})
+	}
+
+	for {
+		var msg Message
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return
+		}
+
+		if !isRelayed(msg.Type) {
+			continue
+		}
+
+		other := s.other(code, p)
+		if other == nil {
+			continue
+		}
+		if err := other.send(msg); err != nil {
+			log.Println("Failed to relay signaling message:", err)
+		}
+	}
+}
+
+// join returns peer that is already in the room
+func (s *Server) join(code string, p *peer) (*peer, error) {
+	if err := validateCode(code); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.rooms[code]
+	if !ok {
+		if len(s.rooms) >= s.config.MaxRooms {
+			return nil, ErrTooManyRooms
+		}
+		r = &room{}
+		s.rooms[code] = r
+	}
+
+	switch {
+	case r.peers[0] == nil && r.peers[1] == nil:
+		p.role = RoleOfferer
+		r.peers[0] = p
+		return nil, nil
+	case r.peers[0] == nil:
+		p.role = RoleOfferer
+		r.peers[0] = p
+		return r.peers[1], nil
+	case r.peers[1] == nil:
+		p.role = RoleAnswerer
+		r.peers[1] = p
+		return r.peers[0], nil
+	}
+
+	return nil, ErrRoomFull
+}
+
+func (s *Server) leave(code string, p *peer) {
+	s.mu.Lock()
+	r, ok := s.rooms[code]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+
+	var other *peer
+	for i := range r.peers {
+		if r.peers[i] == p {
+			r.peers[i] = nil
+		} else if r.peers[i] != nil {
+			other = r.peers[i]
+		}
+	}
+	if other == nil {
+		delete(s.rooms, code)
+	}
+	s.mu.Unlock()
+
+	if other != nil {
+		_ = other.send(Message{Type: TypePeerLeft})
+	}
+}
+
+func (s *Server) other(code string, p *peer) *peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.rooms[code]
+	if !ok {
+		return nil
+	}
+	for _, candidate := range r.peers {
+		if candidate != nil && candidate != p {
+			return candidate
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/rapid/configs"
+)
+
+func newTestServer(t *testing.T, maxRooms int) string {
+	t.Helper()
+
+	s := NewServer(configs.SignalingConfig{MaxRooms: maxRooms})
+	mux := http.NewServeMux()
+	s.RegisterHandlers(mux)
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func dial(t *testing.T, url, code string) *Client {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := Dial(ctx, url, code)
+	if err != nil {
+		t.Fatalf("Dial(%q): %v", code, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func receive(t *testing.T, c *Client) Message {
+	t.Helper()
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg, err := c.Receive()
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	return msg
+}
+
+func TestRelayBetweenPeers(t *testing.T) {
+	url := newTestServer(t, 0)
+
+	offerer := dial(t, url, "room-1")
+	if offerer.Role() != RoleOfferer {
+		t.Fatalf("first peer role = %q, want %q", offerer.Role(), RoleOfferer)
+	}
+
+	answerer := dial(t, url, "room-1")
+	if answerer.Role() != RoleAnswerer {
+		t.Fatalf("second peer role = %q, want %q", answerer.Role(), RoleAnswerer)
+	}
+
+	if msg := receive(t, offerer); msg.Type != TypePeerJoined {
+		t.Fatalf("offerer got %q, want %q", msg.Type, TypePeerJoined)
+	}
+
+	if err := offerer.Send(TypeOffer, "sdp-offer"); err != nil {
+		t.Fatal(err)
+	}
+	msg := receive(t, answerer)
+	var payload string
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != TypeOffer || payload != "sdp-offer" {
+		t.Fatalf("answerer got %q %q, want offer", msg.Type, payload)
+	}
+
+	if err := answerer.Send(TypeAnswer, "sdp-answer"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, offerer); msg.Type != TypeAnswer {
+		t.Fatalf("offerer got %q, want %q", msg.Type, TypeAnswer)
+	}
+}
+
+func TestServerMessagesAreNotRelayed(t *testing.T) {
+	url := newTestServer(t, 0)
+
+	offerer := dial(t, url, "room-1")
+	answerer := dial(t, url, "room-1")
+	receive(t, offerer)
+
+	// peers can't fake server messages
+	if err := answerer.Send(TypePeerLeft, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := answerer.Send(TypeCandidate, "candidate"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, offerer); msg.Type != TypeCandidate {
+		t.Fatalf("offerer got %q, want %q", msg.Type, TypeCandidate)
+	}
+}
+
+func TestPeerLeft(t *testing.T) {
+	url := newTestServer(t, 0)
+
+	offerer := dial(t, url, "room-1")
+	answerer := dial(t, url, "room-1")
+	receive(t, offerer)
+
+	offerer.Close()
+	if msg := receive(t, answerer); msg.Type != TypePeerLeft {
+		t.Fatalf("answerer got %q, want %q", msg.Type, TypePeerLeft)
+	}
+
+	// free place is taken by the next peer
+	next := dial(t, url, "room-1")
+	if next.Role() != RoleOfferer {
+		t.Fatalf("next peer role = %q, want %q", next.Role(), RoleOfferer)
+	}
+	if msg := receive(t, answerer); msg.Type != TypePeerJoined {
+		t.Fatalf("answerer got %q, want %q", msg.Type, TypePeerJoined)
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	url := newTestServer(t, 1)
+
+	dial(t, url, "room-1")
+	dial(t, url, "room-1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		code string
+		want error
+	}{
+		{"room-1", ErrRoomFull},
+		{"room-2", ErrTooManyRooms},
+		{"bad code", ErrInvalidCode},
+	}
+	for _, tt := range tests {
+		_, err := Dial(ctx, url, tt.code)
+		if err == nil || err.Error() != tt.want.Error() {
+			t.Errorf("Dial(%q) error = %v, want %v", tt.code, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package wire
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// Envelope wraps every exchanged description:
+//
+//	magic      "RPD"
+//	version    byte
+//	type       byte (1 offer, 2 answer)
+//	created    uint32 unix seconds
+//	ttl        uint32 seconds, 0 means no expiry
+//	session id str8, may be empty
+//	payload    compact description, see sdp.go
+const (
+	envelopeMagic   = "RPD"
+	envelopeVersion = 1
+
+	maxSessionIDSize = 32
+	// allowed difference between clocks of the peers
+	maxClockSkew = 5 * time.Minute
+)
+
+var (
+	ErrBadMagic         = errors.New("not a rapid connection code")
+	ErrUnexpectedType   = errors.New("unexpected description type")
+	ErrExpired          = errors.New("connection code expired")
+	ErrNotYetValid      = errors.New("connection code is created in the future")
+	ErrSessionMismatch  = errors.New("connection code belongs to another session")
+	ErrReplayed         = errors.New("connection code was already used")
+	ErrInvalidEnvelope  = errors.New("invalid envelope")
+	ErrSessionIDTooLong = errors.New("session id is too long")
+)
+
+type Envelope struct {
+	Version   byte
+	Type      webrtc.SDPType
+	Created   time.Time
+	TTL       time.Duration
+	SessionID []byte
+	Payload   []byte
+}
+
+// Expires returns zero time when envelope has no expiry
+func (e *Envelope) Expires() time.Time {
+	if e.TTL == 0 {
+		return time.Time{}
+	}
+	return e.Created.Add(e.TTL)
+}
+
+func (e *Envelope) MarshalBinary() ([]byte, error) {
+	typ := indexOf(sdpTypes, e.Type)
+	if typ < 1 {
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedType, e.Type)
+	}
+	if len(e.SessionID) > maxSessionIDSize {
+		return nil, ErrSessionIDTooLong
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(envelopeMagic)
+	buf.WriteByte(envelopeVersion)
+	buf.WriteByte(byte(typ))
+	_ = binary.Write(&buf, binary.BigEndian, uint32(e.Created.Unix()))
+	_ = binary.Write(&buf, binary.BigEndian, uint32(e.TTL/time.Second))
+	buf.WriteByte(byte(len(e.SessionID)))
+	buf.Write(e.SessionID)
+	buf.Write(e.Payload)
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary checks format of the envelope, lifetime is checked by Validate
+func (e *Envelope) UnmarshalBinary(data []byte) error {
+	rest, ok := bytes.CutPrefix(data, []byte(envelopeMagic))
+	if !ok {
+		return ErrBadMagic
+	}
+
+	r := bytes.NewReader(rest)
+	var header struct {
+		Version byte
+		Type    byte
+		Created uint32
+		TTL     uint32
+		IDSize  byte
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
+	}
+	if header.Version != envelopeVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, header.Version)
+	}
+	if header.Type == 0 || int(header.Type) >= len(sdpTypes) {
+		return fmt.Errorf("%w: %d", ErrUnexpectedType, header.Type)
+	}
+	if header.IDSize > maxSessionIDSize {
+		return ErrSessionIDTooLong
+	}
+
+	sessionID := make([]byte, header.IDSize)
+	if err := readFull(r, sessionID); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
+	}
+
+	payload := make([]byte, r.Len())
+	_, _ = r.Read(payload)
+
+	*e = Envelope{
+		Version:   header.Version,
+		Type:      sdpTypes[header.Type],
+		Created:   time.Unix(int64(header.Created), 0),
+		TTL:       time.Duration(header.TTL) * time.Second,
+		SessionID: sessionID,
+		Payload:   payload,
+	}
+	return nil
+}
+
+// Validate checks type and lifetime of the envelope
+func (e *Envelope) Validate(expected webrtc.SDPType, now time.Time) error {
+	if e.Type != expected {
+		return fmt.Errorf("%w: expected %s, got %s", ErrUnexpectedType, expected, e.Type)
+	}
+	if e.Created.After(now.Add(maxClockSkew)) {
+		return ErrNotYetValid
+	}
+	if expires := e.Expires(); !expires.IsZero() && now.After(expires.Add(maxClockSkew)) {
+		return fmt.Errorf("%w at %s", ErrExpired, expires.Format(time.DateTime))
+	}
+	return nil
+}
+
+// NewSessionID returns random id that binds answer to its offer
+func NewSessionID() ([]byte, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// Seal wraps description into envelope and returns it as URL-safe text
+func Seal(desc webrtc.SessionDescription, sessionID []byte, ttl time.Duration) (string, error) {
+	payload, err := MarshalDescription(desc)
+	if err != nil {
+		return "", err
+	}
+
+	env := Envelope{
+		Type:      desc.Type,
+		Created:   time.Now(),
+		TTL:       ttl,
+		SessionID: sessionID,
+		Payload:   payload,
+	}
+	data, err := env.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Open parses text created by Seal, checks envelope and returns description
+func Open(text string, expected webrtc.SDPType, now time.Time) (webrtc.SessionDescription, *Envelope, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return webrtc.SessionDescription{}, nil, fmt.Errorf("%w: %w", ErrBadMagic, err)
+	}
+
+	env := &Envelope{}
+	if err := env.UnmarshalBinary(data); err != nil {
+		return webrtc.SessionDescription{}, nil, err
+	}
+	if err := env.Validate(expected, now); err != nil {
+		return webrtc.SessionDescription{}, nil, err
+	}
+
+	desc, err := UnmarshalDescription(env.Payload)
+	if err != nil {
+		return webrtc.SessionDescription{}, nil, err
+	}
+	if desc.Type != env.Type {
+		return webrtc.SessionDescription{}, nil, fmt.Errorf("%w: envelope %s contains %s", ErrUnexpectedType, env.Type, desc.Type)
+	}
+	return desc, env, nil
+}
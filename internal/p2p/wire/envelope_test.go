@@ -0,0 +1,99 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestSealOpen(t *testing.T) {
+	id := []byte("12345678")
+	text, err := Seal(testOffer(), id, 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desc, env, err := Open(" "+text+"\n", webrtc.SDPTypeOffer, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(env.SessionID, id) {
+		t.Errorf("session id = %q, want %q", env.SessionID, id)
+	}
+	if env.TTL != 10*time.Minute || env.Version != envelopeVersion {
+		t.Errorf("envelope = %+v", env)
+	}
+
+	want, err := UnmarshalDescription(env.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc != want {
+		t.Error("opened description differs from payload")
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	now := time.Now()
+	text, err := Seal(testOffer(), nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		expected webrtc.SDPType
+		now      time.Time
+		want     error
+	}{
+		{"not base64", "!!!", webrtc.SDPTypeOffer, now, ErrBadMagic},
+		{"no magic", base64.RawURLEncoding.EncodeToString([]byte("hello")), webrtc.SDPTypeOffer, now, ErrBadMagic},
+		{"wrong type", text, webrtc.SDPTypeAnswer, now, ErrUnexpectedType},
+		{"expired", text, webrtc.SDPTypeOffer, now.Add(time.Minute + maxClockSkew + time.Second), ErrExpired},
+		{"from future", text, webrtc.SDPTypeOffer, now.Add(-maxClockSkew - time.Minute), ErrNotYetValid},
+	}
+	for _, tt := range tests {
+		if _, _, err := Open(tt.text, tt.expected, tt.now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestEnvelopeRejectsLongSessionID(t *testing.T) {
+	if _, err := Seal(testOffer(), make([]byte, maxSessionIDSize+1), 0); !errors.Is(err, ErrSessionIDTooLong) {
+		t.Fatalf("error = %v, want ErrSessionIDTooLong", err)
+	}
+}
+
+func FuzzOpen(f *testing.F) {
+	for _, ttl := range []time.Duration{0, time.Minute} {
+		text, err := Seal(testOffer(), []byte("12345678"), ttl)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(text)
+	}
+	f.Add("")
+	f.Add(base64.RawURLEncoding.EncodeToString([]byte(envelopeMagic)))
+
+	now := time.Now()
+	f.Fuzz(func(t *testing.T, text string) {
+		for _, expected := range []webrtc.SDPType{webrtc.SDPTypeOffer, webrtc.SDPTypeAnswer} {
+			desc, env, err := Open(text, expected, now)
+			if err != nil {
+				continue
+			}
+			if desc.Type != expected || env.Type != expected {
+				t.Fatalf("opened %s as %s", desc.Type, expected)
+			}
+			if len(env.SessionID) > maxSessionIDSize {
+				t.Fatalf("session id of %d bytes", len(env.SessionID))
+			}
+		}
+	})
+}
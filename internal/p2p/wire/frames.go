@@ -0,0 +1,101 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxFrameSize keeps QR codes at qrcode.Medium readable by phone cameras
+const MaxFrameSize = 300
+
+// MaxFrames limits frames of one payload, descriptions take a few of them,
+// so larger totals are rejected before anything is allocated
+const MaxFrames = 64
+
+var (
+	ErrInvalidFrame  = errors.New("invalid frame")
+	ErrFrameMismatch = errors.New("frame belongs to another payload")
+)
+
+// SplitFrames splits payload into "index/total:part" frames for animated QR.
+// Payload that fits into one frame is returned as is.
+func SplitFrames(payload string, size int) []string {
+	if len(payload) <= size {
+		return []string{payload}
+	}
+
+	total := (len(payload) + size - 1) / size
+	frames := make([]string, 0, total)
+	for i := range total {
+		part := payload[i*size : min((i+1)*size, len(payload))]
+		frames = append(frames, fmt.Sprintf("%d/%d:%s", i+1, total, part))
+	}
+	return frames
+}
+
+// FrameCollector joins frames read in any order
+type FrameCollector struct {
+	parts    []string
+	received int
+}
+
+// Add stores frame and reports whether payload is complete
+func (c *FrameCollector) Add(frame string) (bool, error) {
+	frame = strings.TrimSpace(frame)
+
+	header, part, ok := strings.Cut(frame, ":")
+	if !ok || !strings.Contains(header, "/") {
+		// single frame payload
+		c.parts = []string{frame}
+		c.received = 1
+		return true, nil
+	}
+
+	rawIndex, rawTotal, _ := strings.Cut(header, "/")
+	index, err := strconv.Atoi(rawIndex)
+	if err != nil {
+		return false, fmt.Errorf("%w: %q", ErrInvalidFrame, header)
+	}
+	total, err := strconv.Atoi(rawTotal)
+	if err != nil || total < 1 || total > MaxFrames || index < 1 || index > total {
+		return false, fmt.Errorf("%w: %q", ErrInvalidFrame, header)
+	}
+
+	if len(c.parts) != total || c.Complete() {
+		c.Reset()
+		c.parts = make([]string, total)
+	}
+	if c.parts[index-1] == "" {
+		c.parts[index-1] = part
+		c.received++
+	} else if c.parts[index-1] != part {
+		c.Reset()
+		return false, ErrFrameMismatch
+	}
+
+	return c.Complete(), nil
+}
+
+func (c *FrameCollector) Complete() bool {
+	return len(c.parts) > 0 && c.received == len(c.parts)
+}
+
+// Progress returns number of received and total frames
+func (c *FrameCollector) Progress() (int, int) {
+	return c.received, len(c.parts)
+}
+
+// Payload returns joined payload, it's empty until all frames are added
+func (c *FrameCollector) Payload() string {
+	if !c.Complete() {
+		return ""
+	}
+	return strings.Join(c.parts, "")
+}
+
+func (c *FrameCollector) Reset() {
+	c.parts = nil
+	c.received = 0
+}
@@ -0,0 +1,93 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFramesRoundTrip(t *testing.T) {
+	payload := strings.Repeat("0123456789", 100)
+	frames := SplitFrames(payload, MaxFrameSize)
+	if len(frames) != 4 {
+		t.Fatalf("got %d frames, want 4", len(frames))
+	}
+
+	var c FrameCollector
+	// frames are read in any order
+	for i := len(frames) - 1; i >= 0; i-- {
+		done, err := c.Add(frames[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done != (i == 0) {
+			t.Fatalf("complete after frame %d = %v", i, done)
+		}
+	}
+	if c.Payload() != payload {
+		t.Fatal("payload differs after round trip")
+	}
+}
+
+func TestFrameCollectorSingleFrame(t *testing.T) {
+	var c FrameCollector
+	done, err := c.Add(" payload\n")
+	if err != nil || !done || c.Payload() != "payload" {
+		t.Fatalf("Add = %v, %v, payload %q", done, err, c.Payload())
+	}
+}
+
+func TestFrameCollectorInvalidFrames(t *testing.T) {
+	tests := []string{
+		"0/2:x",
+		"3/2:x",
+		"a/2:x",
+		"1/0:x",
+		"1/-1:x",
+		fmt.Sprintf("1/%d:x", MaxFrames+1),
+		"1/999999999999:x",
+	}
+	for _, frame := range tests {
+		var c FrameCollector
+		if _, err := c.Add(frame); !errors.Is(err, ErrInvalidFrame) {
+			t.Errorf("Add(%q) error = %v, want ErrInvalidFrame", frame, err)
+		}
+	}
+}
+
+func TestFrameCollectorMismatch(t *testing.T) {
+	var c FrameCollector
+	if _, err := c.Add("1/2:abc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Add("1/2:xyz"); !errors.Is(err, ErrFrameMismatch) {
+		t.Fatalf("error = %v, want ErrFrameMismatch", err)
+	}
+	if received, total := c.Progress(); received != 0 || total != 0 {
+		t.Fatalf("progress after mismatch = %d/%d", received, total)
+	}
+}
+
+func FuzzFrameCollector(f *testing.F) {
+	f.Add("1/2:abc", "2/2:def")
+	f.Add("payload", "1/1:x")
+	f.Add("2/3:x", "1/999999999999:x")
+
+	f.Fuzz(func(t *testing.T, first, second string) {
+		var c FrameCollector
+		for _, frame := range []string{first, second} {
+			done, err := c.Add(frame)
+			received, total := c.Progress()
+			if total > MaxFrames || received > total {
+				t.Fatalf("progress %d/%d after %q", received, total, frame)
+			}
+			if err == nil && done != c.Complete() {
+				t.Fatalf("Add reported %v, Complete is %v", done, c.Complete())
+			}
+		}
+		if !c.Complete() && c.Payload() != "" {
+			t.Fatal("payload of incomplete collector")
+		}
+	})
+}
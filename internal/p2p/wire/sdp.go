@@ -0,0 +1,529 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// Compact description keeps only what data channel connection needs:
+//
+//	version    byte
+//	type       byte (1 offer, 2 answer)
+//	setup      byte (DTLS role)
+//	mid        str8
+//	ufrag      str8
+//	pwd        str8
+//	hash       byte, digest of the hash length
+//	sctp port  uint16
+//	max size   uint32
+//	candidates byte count, then candidate records
+//
+// str8 is a string prefixed with its length byte, numbers are big endian.
+const descriptionVersion = 1
+
+const (
+	defaultSCTPPort       = 5000
+	defaultMaxMessageSize = 262144
+)
+
+var (
+	ErrInvalidDescription = errors.New("invalid session description")
+	ErrUnsupportedVersion = errors.New("unsupported description version")
+)
+
+var sdpTypes = []webrtc.SDPType{0, webrtc.SDPTypeOffer, webrtc.SDPTypeAnswer}
+
+var setupRoles = []string{"actpass", "active", "passive"}
+
+// index is the hash id in compact form
+var fingerprintHashes = []struct {
+	name string
+	size int
+}{
+	{},
+	{"sha-1", 20},
+	{"sha-224", 28},
+	{"sha-256", 32},
+	{"sha-384", 48},
+	{"sha-512", 64},
+}
+
+var candidateTypes = []string{"host", "srflx", "prflx", "relay"}
+
+var tcpTypes = []string{"", "active", "passive", "so"}
+
+// candidate address kinds
+const (
+	addrIPv4 = iota
+	addrIPv6
+	addrHostname
+)
+
+type description struct {
+	sdpType     webrtc.SDPType
+	setup       string
+	mid         string
+	ufrag       string
+	pwd         string
+	hash        string
+	fingerprint []byte
+	sctpPort    uint16
+	maxSize     uint32
+	candidates  []candidate
+}
+
+type candidate struct {
+	foundation uint32
+	protocol   string
+	priority   uint32
+	address    string
+	port       uint16
+	typ        string
+	tcpType    string
+}
+
+// MarshalDescription keeps ICE credentials, DTLS fingerprint, candidates
+// and SCTP parameters of the description, everything else is dropped
+func MarshalDescription(desc webrtc.SessionDescription) ([]byte, error) {
+	d, err := parseSDP(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(descriptionVersion)
+	buf.WriteByte(byte(indexOf(sdpTypes, d.sdpType)))
+	buf.WriteByte(byte(indexOf(setupRoles, d.setup)))
+	for _, s := range []string{d.mid, d.ufrag, d.pwd} {
+		if err := writeString(&buf, s); err != nil {
+			return nil, err
+		}
+	}
+
+	hashID := -1
+	for i, h := range fingerprintHashes {
+		if i > 0 && h.name == d.hash && h.size == len(d.fingerprint) {
+			hashID = i
+		}
+	}
+	if hashID < 0 {
+		return nil, fmt.Errorf("%w: unsupported fingerprint %s", ErrInvalidDescription, d.hash)
+	}
+	buf.WriteByte(byte(hashID))
+	buf.Write(d.fingerprint)
+
+	_ = binary.Write(&buf, binary.BigEndian, d.sctpPort)
+	_ = binary.Write(&buf, binary.BigEndian, d.maxSize)
+
+	if len(d.candidates) > 255 {
+		return nil, fmt.Errorf("%w: too many candidates", ErrInvalidDescription)
+	}
+	buf.WriteByte(byte(len(d.candidates)))
+	for _, c := range d.candidates {
+		if err := writeCandidate(&buf, c); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalDescription rebuilds valid SDP from compact form
+func UnmarshalDescription(data []byte) (webrtc.SessionDescription, error) {
+	r := bytes.NewReader(data)
+	d, err := readDescription(r)
+	if err != nil {
+		return webrtc.SessionDescription{}, err
+	}
+	if r.Len() != 0 {
+		return webrtc.SessionDescription{}, fmt.Errorf("%w: trailing data", ErrInvalidDescription)
+	}
+
+	return webrtc.SessionDescription{
+		Type: d.sdpType,
+		SDP:  d.build(),
+	}, nil
+}
+
+func parseSDP(desc webrtc.SessionDescription) (*description, error) {
+	if desc.Type != webrtc.SDPTypeOffer && desc.Type != webrtc.SDPTypeAnswer {
+		return nil, fmt.Errorf("%w: unsupported type %s", ErrInvalidDescription, desc.Type)
+	}
+
+	d := &description{
+		sdpType:  desc.Type,
+		setup:    "actpass",
+		sctpPort: defaultSCTPPort,
+		maxSize:  defaultMaxMessageSize,
+	}
+
+	for _, line := range strings.Split(desc.SDP, "\n") {
+		line = strings.TrimSpace(line)
+		attr, ok := strings.CutPrefix(line, "a=")
+		if !ok {
+			continue
+		}
+		key, value, _ := strings.Cut(attr, ":")
+
+		switch key {
+		case "setup":
+			if indexOf(setupRoles, value) < 0 {
+				return nil, fmt.Errorf("%w: unsupported setup %s", ErrInvalidDescription, value)
+			}
+			d.setup = value
+		case "mid":
+			if d.mid == "" {
+				d.mid = value
+			}
+		case "ice-ufrag":
+			d.ufrag = value
+		case "ice-pwd":
+			d.pwd = value
+		case "fingerprint":
+			if d.fingerprint != nil {
+				continue
+			}
+			hash, digest, ok := strings.Cut(value, " ")
+			if !ok {
+				return nil, fmt.Errorf("%w: malformed fingerprint", ErrInvalidDescription)
+			}
+			raw, err := hex.DecodeString(strings.ReplaceAll(digest, ":", ""))
+			if err != nil {
+				return nil, fmt.Errorf("%w: malformed fingerprint", ErrInvalidDescription)
+			}
+			d.hash, d.fingerprint = strings.ToLower(hash), raw
+		case "sctp-port":
+			port, err := strconv.ParseUint(value, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid sctp port", ErrInvalidDescription)
+			}
+			d.sctpPort = uint16(port)
+		case "max-message-size":
+			size, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid max message size", ErrInvalidDescription)
+			}
+			d.maxSize = uint32(size)
+		case "candidate":
+			c, err := parseCandidate(value)
+			if err != nil {
+				return nil, err
+			}
+			// only first component is used with bundle
+			if c != nil {
+				d.candidates = append(d.candidates, *c)
+			}
+		}
+	}
+
+	if d.ufrag == "" || d.pwd == "" {
+		return nil, fmt.Errorf("%w: no ICE credentials", ErrInvalidDescription)
+	}
+	if d.fingerprint == nil {
+		return nil, fmt.Errorf("%w: no DTLS fingerprint", ErrInvalidDescription)
+	}
+	if d.mid == "" {
+		d.mid = "0"
+	}
+	return d, nil
+}
+
+// parseCandidate parses "foundation component protocol priority address port typ type ..."
+func parseCandidate(value string) (*candidate, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 8 || fields[6] != "typ" {
+		return nil, fmt.Errorf("%w: malformed candidate %q", ErrInvalidDescription, value)
+	}
+	if fields[1] != "1" {
+		return nil, nil
+	}
+
+	priority, err := strconv.ParseUint(fields[3], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid candidate priority", ErrInvalidDescription)
+	}
+	port, err := strconv.ParseUint(fields[5], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid candidate port", ErrInvalidDescription)
+	}
+
+	foundation, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		// any value works, equal foundations must stay equal
+		foundation = uint64(crc32.ChecksumIEEE([]byte(fields[0])))
+	}
+
+	c := &candidate{
+		foundation: uint32(foundation),
+		protocol:   strings.ToLower(fields[2]),
+		priority:   uint32(priority),
+		address:    fields[4],
+		port:       uint16(port),
+		typ:        fields[7],
+	}
+	if c.protocol != "udp" && c.protocol != "tcp" {
+		return nil, fmt.Errorf("%w: unsupported candidate protocol %s", ErrInvalidDescription, c.protocol)
+	}
+	if indexOf(candidateTypes, c.typ) < 0 {
+		return nil, fmt.Errorf("%w: unsupported candidate type %s", ErrInvalidDescription, c.typ)
+	}
+
+	for i := 8; i+1 < len(fields); i += 2 {
+		if fields[i] == "tcptype" {
+			if indexOf(tcpTypes, fields[i+1]) < 1 {
+				return nil, fmt.Errorf("%w: unsupported tcp type %s", ErrInvalidDescription, fields[i+1])
+			}
+			c.tcpType = fields[i+1]
+		}
+	}
+	return c, nil
+}
+
+// candidate record:
+//
+//	flags      byte: bits 0-1 type, bit 2 tcp, bits 3-4 tcp type, bits 5-6 address kind
+//	address    4 or 16 bytes, str8 for mDNS hostnames
+//	port       uint16
+//	priority   uint32
+//	foundation uint32
+func writeCandidate(buf *bytes.Buffer, c candidate) error {
+	flags := byte(indexOf(candidateTypes, c.typ))
+	if c.protocol == "tcp" {
+		flags |= 1 << 2
+		flags |= byte(indexOf(tcpTypes, c.tcpType)) << 3
+	}
+
+	ip := net.ParseIP(c.address)
+	switch {
+	case ip == nil:
+		flags |= addrHostname << 5
+		buf.WriteByte(flags)
+		if err := writeString(buf, c.address); err != nil {
+			return err
+		}
+	case ip.To4() != nil:
+		flags |= addrIPv4 << 5
+		buf.WriteByte(flags)
+		buf.Write(ip.To4())
+	default:
+		flags |= addrIPv6 << 5
+		buf.WriteByte(flags)
+		buf.Write(ip.To16())
+	}
+
+	_ = binary.Write(buf, binary.BigEndian, c.port)
+	_ = binary.Write(buf, binary.BigEndian, c.priority)
+	_ = binary.Write(buf, binary.BigEndian, c.foundation)
+	return nil
+}
+
+func readDescription(r *bytes.Reader) (*description, error) {
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDescription, err)
+	}
+	if version != descriptionVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	var header [2]byte
+	if err := readFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] == 0 || int(header[0]) >= len(sdpTypes) || int(header[1]) >= len(setupRoles) {
+		return nil, fmt.Errorf("%w: invalid header", ErrInvalidDescription)
+	}
+
+	d := &description{
+		sdpType: sdpTypes[header[0]],
+		setup:   setupRoles[header[1]],
+	}
+	for _, s := range []*string{&d.mid, &d.ufrag, &d.pwd} {
+		if *s, err = readString(r); err != nil {
+			return nil, err
+		}
+	}
+
+	hashID, err := r.ReadByte()
+	if err != nil || hashID == 0 || int(hashID) >= len(fingerprintHashes) {
+		return nil, fmt.Errorf("%w: invalid fingerprint", ErrInvalidDescription)
+	}
+	d.hash = fingerprintHashes[hashID].name
+	d.fingerprint = make([]byte, fingerprintHashes[hashID].size)
+	if err := readFull(r, d.fingerprint); err != nil {
+		return nil, err
+	}
+
+	var params struct {
+		SCTPPort uint16
+		MaxSize  uint32
+		Count    uint8
+	}
+	if err := binary.Read(r, binary.BigEndian, &params); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDescription, err)
+	}
+	d.sctpPort, d.maxSize = params.SCTPPort, params.MaxSize
+
+	for range params.Count {
+		c, err := readCandidate(r)
+		if err != nil {
+			return nil, err
+		}
+		d.candidates = append(d.candidates, c)
+	}
+	return d, nil
+}
+
+func readCandidate(r *bytes.Reader) (candidate, error) {
+	var c candidate
+
+	flags, err := r.ReadByte()
+	if err != nil {
+		return c, fmt.Errorf("%w: %w", ErrInvalidDescription, err)
+	}
+
+	c.typ = candidateTypes[flags&0b11]
+	c.protocol = "udp"
+	if flags&(1<<2) != 0 {
+		c.protocol = "tcp"
+		c.tcpType = tcpTypes[(flags>>3)&0b11]
+		if c.tcpType == "" {
+			return c, fmt.Errorf("%w: tcp candidate without type", ErrInvalidDescription)
+		}
+	}
+
+	switch (flags >> 5) & 0b11 {
+	case addrIPv4:
+		ip := make(net.IP, net.IPv4len)
+		if err := readFull(r, ip); err != nil {
+			return c, err
+		}
+		c.address = ip.String()
+	case addrIPv6:
+		ip := make(net.IP, net.IPv6len)
+		if err := readFull(r, ip); err != nil {
+			return c, err
+		}
+		c.address = ip.String()
+	case addrHostname:
+		if c.address, err = readString(r); err != nil {
+			return c, err
+		}
+	default:
+		return c, fmt.Errorf("%w: invalid address kind", ErrInvalidDescription)
+	}
+
+	var fields struct {
+		Port       uint16
+		Priority   uint32
+		Foundation uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &fields); err != nil {
+		return c, fmt.Errorf("%w: %w", ErrInvalidDescription, err)
+	}
+	c.port, c.priority, c.foundation = fields.Port, fields.Priority, fields.Foundation
+	return c, nil
+}
+
+// build returns SDP with single data channel media section
+func (d *description) build() string {
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, format+"\r\n", args...)
+	}
+
+	digest := make([]string, len(d.fingerprint))
+	for i, v := range d.fingerprint {
+		digest[i] = fmt.Sprintf("%02X", v)
+	}
+
+	// session id must be stable for the same description
+	sessionID := binary.BigEndian.Uint64(d.fingerprint[:8]) >> 1
+
+	line("v=0")
+	line("o=- %d 0 IN IP4 0.0.0.0", sessionID)
+	line("s=-")
+	line("t=0 0")
+	line("a=fingerprint:%s %s", d.hash, strings.Join(digest, ":"))
+	line("a=group:BUNDLE %s", d.mid)
+	line("m=application 9 UDP/DTLS/SCTP webrtc-datachannel")
+	line("c=IN IP4 0.0.0.0")
+	line("a=setup:%s", d.setup)
+	line("a=mid:%s", d.mid)
+	line("a=sendrecv")
+	line("a=sctp-port:%d", d.sctpPort)
+	line("a=max-message-size:%d", d.maxSize)
+	line("a=ice-ufrag:%s", d.ufrag)
+	line("a=ice-pwd:%s", d.pwd)
+	for _, c := range d.candidates {
+		line("a=candidate:%s", c.String())
+	}
+	line("a=end-of-candidates")
+
+	return b.String()
+}
+
+func (c candidate) String() string {
+	s := fmt.Sprintf(
+		"%d 1 %s %d %s %d typ %s",
+		c.foundation, c.protocol, c.priority, c.address, c.port, c.typ,
+	)
+	// related address is not needed for connectivity
+	if c.typ != "host" {
+		s += " raddr 0.0.0.0 rport 0"
+	}
+	if c.tcpType != "" {
+		s += " tcptype " + c.tcpType
+	}
+	return s
+}
+
+func writeString(buf *bytes.Buffer, s string) error {
+	if len(s) > 255 {
+		return fmt.Errorf("%w: value too long", ErrInvalidDescription)
+	}
+	buf.WriteByte(byte(len(s)))
+	buf.WriteString(s)
+	return nil
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	n, err := r.ReadByte()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidDescription, err)
+	}
+	data := make([]byte, n)
+	if err := readFull(r, data); err != nil {
+		return "", err
+	}
+	// values go to SDP lines as is, so they can't be empty or split lines
+	if n == 0 || bytes.ContainsFunc(data, func(r rune) bool { return r <= ' ' || r > '~' }) {
+		return "", fmt.Errorf("%w: invalid value %q", ErrInvalidDescription, data)
+	}
+	return string(data), nil
+}
+
+func readFull(r *bytes.Reader, data []byte) error {
+	if n, _ := r.Read(data); n != len(data) {
+		return fmt.Errorf("%w: unexpected end of data", ErrInvalidDescription)
+	}
+	return nil
+}
+
+func indexOf[T comparable](values []T, value T) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,161 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// offer as pion creates it, with candidates of every kind
+const testOfferSDP = "v=0\r\n" +
+	"o=- 6520437542386233716 1729353600 IN IP4 0.0.0.0\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"a=msid-semantic:WMS*\r\n" +
+	"a=fingerprint:sha-256 5B:8D:0E:42:7A:6C:11:93:AC:21:4E:F0:77:9D:C8:02:3B:51:64:E9:0A:D7:88:1F:45:C3:9E:6B:20:FD:14:A7\r\n" +
+	"a=ice-lite\r\n" +
+	"a=group:BUNDLE 0\r\n" +
+	"m=application 9 UDP/DTLS/SCTP webrtc-datachannel\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=setup:actpass\r\n" +
+	"a=mid:0\r\n" +
+	"a=sendrecv\r\n" +
+	"a=sctp-port:5000\r\n" +
+	"a=max-message-size:1073741823\r\n" +
+	"a=ice-ufrag:QwErTyUiOpAsDfGh\r\n" +
+	"a=ice-pwd:zXcVbNmLkJhGfDsAqWeRtYuIoPaSdFgH\r\n" +
+	"a=candidate:1966762134 1 udp 2130706431 192.168.1.20 50312 typ host\r\n" +
+	"a=candidate:1966762134 2 udp 2130706431 192.168.1.20 50312 typ host\r\n" +
+	"a=candidate:3821739301 1 udp 2130706431 fd00::1c2 50313 typ host\r\n" +
+	"a=candidate:233762139 1 udp 2130706431 2f1e3c9a-4b6d-4e8f-9a0b-1c2d3e4f5a6b.local 50314 typ host\r\n" +
+	"a=candidate:1052353102 1 tcp 1671430143 192.168.1.20 9 typ host tcptype active\r\n" +
+	"a=candidate:2840221876 1 udp 1694498815 203.0.113.7 61444 typ srflx raddr 0.0.0.0 rport 50312\r\n" +
+	"a=candidate:aBcD1234 1 udp 16777215 198.51.100.2 49170 typ relay raddr 203.0.113.7 rport 61444\r\n" +
+	"a=end-of-candidates\r\n"
+
+func testOffer() webrtc.SessionDescription {
+	return webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: testOfferSDP}
+}
+
+func TestDescriptionRoundTrip(t *testing.T) {
+	data, err := MarshalDescription(testOffer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(testOfferSDP)/3 {
+		t.Errorf("compact form is %d bytes, SDP is %d", len(data), len(testOfferSDP))
+	}
+
+	desc, err := UnmarshalDescription(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Type != webrtc.SDPTypeOffer {
+		t.Fatalf("type = %s", desc.Type)
+	}
+
+	for _, want := range []string{
+		"a=fingerprint:sha-256 5B:8D:0E:42:7A:6C:11:93:AC:21:4E:F0:77:9D:C8:02:3B:51:64:E9:0A:D7:88:1F:45:C3:9E:6B:20:FD:14:A7",
+		"a=setup:actpass",
+		"a=ice-ufrag:QwErTyUiOpAsDfGh",
+		"a=ice-pwd:zXcVbNmLkJhGfDsAqWeRtYuIoPaSdFgH",
+		"a=max-message-size:1073741823",
+		"a=candidate:1966762134 1 udp 2130706431 192.168.1.20 50312 typ host",
+		"a=candidate:3821739301 1 udp 2130706431 fd00::1c2 50313 typ host",
+		"a=candidate:233762139 1 udp 2130706431 2f1e3c9a-4b6d-4e8f-9a0b-1c2d3e4f5a6b.local 50314 typ host",
+		"a=candidate:1052353102 1 tcp 1671430143 192.168.1.20 9 typ host tcptype active",
+		"typ srflx",
+		"typ relay",
+	} {
+		if !strings.Contains(desc.SDP, want) {
+			t.Errorf("rebuilt SDP has no %q", want)
+		}
+	}
+	if strings.Contains(desc.SDP, " 2 udp ") {
+		t.Error("second component candidate is kept")
+	}
+
+	// rebuilt description is encoded the same way
+	again, err := MarshalDescription(desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Error("compact form changed after round trip")
+	}
+
+	// and pion accepts it
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if err := pc.SetRemoteDescription(desc); err != nil {
+		t.Fatalf("pion rejected rebuilt SDP: %v", err)
+	}
+}
+
+func TestMarshalDescriptionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		sdp  string
+	}{
+		{"no credentials", strings.ReplaceAll(testOfferSDP, "a=ice-pwd:", "a=x-pwd:")},
+		{"no fingerprint", strings.ReplaceAll(testOfferSDP, "a=fingerprint:", "a=x-fingerprint:")},
+		{"unsupported hash", strings.ReplaceAll(testOfferSDP, "sha-256", "md5")},
+		{"bad candidate", strings.ReplaceAll(testOfferSDP, "typ host\r\n", "typ lan\r\n")},
+	}
+	for _, tt := range tests {
+		desc := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: tt.sdp}
+		if _, err := MarshalDescription(desc); !errors.Is(err, ErrInvalidDescription) {
+			t.Errorf("%s: error = %v, want ErrInvalidDescription", tt.name, err)
+		}
+	}
+}
+
+func TestUnmarshalDescriptionTruncated(t *testing.T) {
+	data, err := MarshalDescription(testOffer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		if _, err := UnmarshalDescription(data[:i]); err == nil {
+			t.Fatalf("truncated to %d bytes: no error", i)
+		}
+	}
+	if _, err := UnmarshalDescription(append(data, 0)); !errors.Is(err, ErrInvalidDescription) {
+		t.Fatalf("trailing data: error = %v", err)
+	}
+}
+
+func FuzzUnmarshalDescription(f *testing.F) {
+	data, err := MarshalDescription(testOffer())
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	f.Add([]byte{descriptionVersion})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		desc, err := UnmarshalDescription(data)
+		if err != nil {
+			return
+		}
+
+		// everything that is decoded must survive the next round trip
+		again, err := MarshalDescription(desc)
+		if err != nil {
+			t.Fatalf("decoded description is not encoded again: %v", err)
+		}
+		desc2, err := UnmarshalDescription(again)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if desc2.SDP != desc.SDP {
+			t.Fatalf("SDP changed after round trip:\n%s\n%s", desc.SDP, desc2.SDP)
+		}
+	})
+}
@@ -15,21 +15,36 @@ type Rapid struct {
 	lan    *server.LANServer
 	client *client.LANClient
 
-	lanController *controller.LANController
-	netController *controller.NetController
+	lanController  *controller.LANController
+	netController  *controller.NetController
+	chatController *controller.ChatController
+	folderShare    *controller.FolderShare
+	folderSync     *controller.FolderSyncController
 
 	fyneApp fyne.App
 
 	mu sync.Mutex
 }
 
-func New(s *server.LANServer, c *client.LANClient, l *controller.LANController, n *controller.NetController, a fyne.App) *Rapid {
+func New(
+	s *server.LANServer,
+	c *client.LANClient,
+	l *controller.LANController,
+	n *controller.NetController,
+	ch *controller.ChatController,
+	f *controller.FolderShare,
+	fs *controller.FolderSyncController,
+	a fyne.App,
+) *Rapid {
 	return &Rapid{
-		lan:           s,
-		client:        c,
-		lanController: l,
-		netController: n,
-		fyneApp:       a,
+		lan:            s,
+		client:         c,
+		lanController:  l,
+		netController:  n,
+		chatController: ch,
+		folderShare:    f,
+		folderSync:     fs,
+		fyneApp:        a,
 	}
 }
 
@@ -49,8 +64,10 @@ func (a *Rapid) createMainWindow() fyne.Window {
 
 	tabs := container.NewAppTabs(
 		container.NewTabItem("LAN", a.lanController.CreateLANContent(mainWindow)),
+		container.NewTabItem("Search", a.lanController.Search().CreateSearchContent()),
 		container.NewTabItem("WebRTC", a.netController.CreateNetContent(mainWindow)),
-		container.NewTabItem("Options", createOptionsContent()),
+		container.NewTabItem("Chat", a.chatController.CreateChatContent(mainWindow)),
+		container.NewTabItem("Options", a.createOptionsContent(mainWindow)),
 	)
 	mainWindow.Resize(fyne.NewSize(800, 600))
 
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/internal/bandwidth"
+)
+
+// CreateBandwidthOptions returns speed limits for the Options tab,
+// changes apply to running transfers too
+func CreateBandwidthOptions(window fyne.Window, bw *bandwidth.Manager) fyne.CanvasObject {
+	cfg := bw.Config()
+
+	uploadEntry := newRateEntry(cfg.Upload)
+	downloadEntry := newRateEntry(cfg.Download)
+	peerUploadEntry := newRateEntry(cfg.PeerUpload)
+	peerDownloadEntry := newRateEntry(cfg.PeerDownload)
+
+	peersEntry := widget.NewMultiLineEntry()
+	peersEntry.SetPlaceHolder("192.168.1.20 512 0\nalice 1024 1024")
+	peersEntry.SetText(configs.FormatPeerBandwidth(cfg.Peers))
+	peersEntry.SetMinRowsVisible(4)
+
+	applyBtn := widget.NewButton("Apply", func() {
+		var rates [4]int64
+		entries := []*widget.Entry{uploadEntry, downloadEntry, peerUploadEntry, peerDownloadEntry}
+		for i, entry := range entries {
+			rate, err := parseRate(entry.Text)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			rates[i] = rate
+		}
+
+		peers, err := configs.ParsePeerBandwidth(peersEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		bw.SetConfig(configs.BandwidthConfig{
+			Upload:       rates[0],
+			Download:     rates[1],
+			PeerUpload:   rates[2],
+			PeerDownload: rates[3],
+			Peers:        peers,
+		})
+		dialog.ShowInformation("Success", "Bandwidth limits are applied", window)
+	})
+
+	transfers := container.NewVBox()
+	refreshTransfers := func() {
+		transfers.RemoveAll()
+		streams := bw.Streams()
+		if len(streams) == 0 {
+			transfers.Add(widget.NewLabel("No active transfers"))
+		}
+		for _, stream := range streams {
+			transfers.Add(newTransferRateRow(window, bw, stream))
+		}
+	}
+	refreshTransfers()
+
+	return container.NewVBox(
+		widget.NewLabel("Limits in KiB/s, 0 means unlimited"),
+		widget.NewForm(
+			widget.NewFormItem("Total upload", uploadEntry),
+			widget.NewFormItem("Total download", downloadEntry),
+			widget.NewFormItem("Peer upload", peerUploadEntry),
+			widget.NewFormItem("Peer download", peerDownloadEntry),
+		),
+		widget.NewLabel("Own limits of peers, one per line: peer upload download\nPeer is IP for LAN and peer name for WebRTC"),
+		peersEntry,
+		applyBtn,
+		widget.NewSeparator(),
+		widget.NewLabel("Own limits of active LAN and WebRTC transfers, they are removed when the transfer ends"),
+		transfers,
+		widget.NewButton("Refresh transfers", refreshTransfers),
+	)
+}
+
+// newTransferRateRow slows down one transfer on top of other limits
+func newTransferRateRow(window fyne.Window, bw *bandwidth.Manager, stream bandwidth.Stream) fyne.CanvasObject {
+	dir := "upload to"
+	if stream.Direction == bandwidth.Download {
+		dir = "download from"
+	}
+	label := widget.NewLabel(fmt.Sprintf("%s, %s %s", stream.Name, dir, stream.Peer))
+	label.Truncation = fyne.TextTruncateEllipsis
+
+	rateEntry := newRateEntry(bw.TransferRate(stream.Peer, stream.Transfer))
+	setBtn := widget.NewButton("Set", func() {
+		rate, err := parseRate(rateEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		bw.SetTransferRate(stream.Peer, stream.Transfer, rate)
+		if rate > 0 && bw.TransferRate(stream.Peer, stream.Transfer) == 0 {
+			dialog.ShowInformation("Transfer is finished", stream.Name+" is not transferred anymore", window)
+		}
+	})
+
+	controls := container.NewHBox(container.NewGridWrap(fyne.NewSize(100, rateEntry.MinSize().Height), rateEntry), setBtn)
+	return container.NewBorder(nil, nil, nil, controls, label)
+}
+
+func newRateEntry(rate int64) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetText(strconv.FormatInt(rate/1024, 10))
+	return entry
+}
+
+// parseRate converts KiB/s to bytes per second
+func parseRate(text string) (int64, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+
+	rate, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || rate < 0 {
+		return 0, fmt.Errorf("invalid rate %q", text)
+	}
+	return rate * 1024, nil
+}
@@ -0,0 +1,400 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/lan/client"
+	"github.com/0x0FACED/rapid/internal/lan/server"
+	"github.com/0x0FACED/rapid/internal/model"
+)
+
+var ErrNoChatPeer = errors.New("select a peer first")
+
+// ChatController sends short texts, links and clipboard contents
+// to LAN servers and connected WebRTC peers
+type ChatController struct {
+	instName string
+	client   *client.LANClient
+	lan      *LANController
+	net      *NetController
+	state    *ChatState
+	sync     *ClipboardSync
+
+	// peers that sent us something, they may be not discovered yet
+	known       map[string]ChatPeer
+	currentPeer string
+
+	window      *fyne.Window
+	peersList   *widget.List
+	historyList *widget.List
+	chatLabel   *widget.Label
+	trustCheck  *widget.Check
+
+	mu sync.Mutex
+}
+
+func NewChatController(
+	c *client.LANClient,
+	s *server.LANServer,
+	lan *LANController,
+	net *NetController,
+	instName string,
+) *ChatController {
+	cc := &ChatController{
+		instName: instName,
+		client:   c,
+		lan:      lan,
+		net:      net,
+		state:    NewChatState(),
+		known:    make(map[string]ChatPeer),
+	}
+
+	cc.sync = NewClipboardSync(cc, s)
+
+	s.SetOnMessage(cc.onLANMessage)
+	net.SetOnMessage(cc.onWebRTCMessage)
+	net.SetOnClipboard(cc.sync.onWebRTCClipboard)
+
+	return cc
+}
+
+// ClipboardSync returns clipboard sync mode of the chat peers
+func (cc *ChatController) ClipboardSync() *ClipboardSync {
+	return cc.sync
+}
+
+// lanPeer returns LAN peer with the instance name, address is
+// empty when the peer is not discovered yet
+func (cc *ChatController) lanPeer(name string) ChatPeer {
+	peer := ChatPeer{Name: name, Transport: TransportLAN}
+	for _, server := range cc.lan.Servers() {
+		if server.InstanceName == name {
+			peer.Address = server.Address()
+		}
+	}
+	return peer
+}
+
+// lanPeerAt returns LAN peer with the instance name that is discovered
+// at the host, ok is false for unknown peers and other hosts
+func (cc *ChatController) lanPeerAt(name, host string) (ChatPeer, bool) {
+	for _, server := range cc.lan.Servers() {
+		if server.InstanceName == name && server.IPv4 == host {
+			return ChatPeer{Name: name, Transport: TransportLAN, Address: server.Address()}, true
+		}
+	}
+	return ChatPeer{}, false
+}
+
+func webRTCPeer(session *PeerSession) ChatPeer {
+	return ChatPeer{Name: session.Name, Transport: TransportWebRTC, Address: session.Name}
+}
+
+func (cc *ChatController) onLANMessage(msg model.Message) {
+	cc.receive(cc.lanPeer(msg.From), msg)
+}
+
+func (cc *ChatController) onWebRTCMessage(session *PeerSession, msg model.Message) {
+	cc.receive(webRTCPeer(session), msg)
+}
+
+func (cc *ChatController) receive(peer ChatPeer, msg model.Message) {
+	cc.mu.Lock()
+	if old, ok := cc.known[peer.Key()]; !ok || peer.Address != "" || old.Address == "" {
+		cc.known[peer.Key()] = peer
+	}
+	current := cc.currentPeer == peer.Key()
+	cc.mu.Unlock()
+
+	cc.state.Add(peer.Key(), msg, false)
+	if current {
+		cc.state.MarkRead(peer.Key())
+	}
+	cc.refreshUI()
+}
+
+// peers returns discovered LAN servers, connected WebRTC peers
+// and everyone who has sent us a message
+func (cc *ChatController) peers() []ChatPeer {
+	peers := make(map[string]ChatPeer)
+
+	cc.mu.Lock()
+	for key, peer := range cc.known {
+		peers[key] = peer
+	}
+	cc.mu.Unlock()
+
+	for _, server := range cc.lan.Servers() {
+		if server.InstanceName == cc.instName {
+			continue
+		}
+		peer := ChatPeer{Name: server.InstanceName, Transport: TransportLAN, Address: server.Address()}
+		peers[peer.Key()] = peer
+	}
+
+	for _, session := range cc.net.Sessions().GetAll() {
+		if session.Connected() {
+			peer := webRTCPeer(session)
+			peers[peer.Key()] = peer
+		}
+	}
+
+	result := make([]ChatPeer, 0, len(peers))
+	for _, peer := range peers {
+		result = append(result, peer)
+	}
+	sortChatPeers(result)
+	return result
+}
+
+func (cc *ChatController) findPeer(key string) (ChatPeer, bool) {
+	for _, peer := range cc.peers() {
+		if peer.Key() == key {
+			return peer, true
+		}
+	}
+	return ChatPeer{}, false
+}
+
+// Send sends text of the kind to the current peer
+func (cc *ChatController) Send(kind, text string) error {
+	cc.mu.Lock()
+	key := cc.currentPeer
+	cc.mu.Unlock()
+
+	peer, ok := cc.findPeer(key)
+	if !ok {
+		return ErrNoChatPeer
+	}
+
+	msg := model.NewMessage(cc.instName, kind, text)
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+
+	var err error
+	switch peer.Transport {
+	case TransportLAN:
+		if peer.Address == "" {
+			return fmt.Errorf("address of %s is unknown", peer.Name)
+		}
+		err = cc.client.SendMessage(peer.Address, msg)
+	case TransportWebRTC:
+		err = cc.net.Sessions().SendMessage(peer.Address, msg)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send message to %s: %w", peer.Name, err)
+	}
+
+	cc.state.Add(peer.Key(), msg, true)
+	cc.refreshUI()
+	return nil
+}
+
+func (cc *ChatController) selectPeer(peer ChatPeer) {
+	cc.mu.Lock()
+	cc.currentPeer = peer.Key()
+	cc.mu.Unlock()
+
+	cc.state.MarkRead(peer.Key())
+	cc.chatLabel.SetText(fmt.Sprintf("Chat with %s (%s)", peer.Name, peer.Transport))
+	cc.trustCheck.SetChecked(cc.sync.Trusted(peer))
+	cc.trustCheck.Enable()
+	cc.refreshUI()
+}
+
+func (cc *ChatController) history() []ChatEntry {
+	cc.mu.Lock()
+	key := cc.currentPeer
+	cc.mu.Unlock()
+
+	return cc.state.Get(key)
+}
+
+func (cc *ChatController) refreshUI() {
+	if cc.peersList != nil {
+		cc.peersList.Refresh()
+	}
+
+	if cc.historyList != nil {
+		cc.historyList.Refresh()
+		cc.historyList.ScrollToBottom()
+	}
+}
+
+func (cc *ChatController) CreateChatContent(w fyne.Window) fyne.CanvasObject {
+	cc.window = &w
+
+	cc.initPeersList()
+	cc.initHistoryList(w)
+	cc.chatLabel = widget.NewLabelWithStyle("Select a peer", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	cc.initTrustCheck()
+
+	// LAN servers come and go without notifying us
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			cc.peersList.Refresh()
+		}
+	}()
+
+	return container.NewHSplit(
+		cc.createPeersSection(),
+		cc.createConversationSection(w),
+	)
+}
+
+func (cc *ChatController) initTrustCheck() {
+	cc.trustCheck = widget.NewCheck("Sync clipboard", func(checked bool) {
+		cc.mu.Lock()
+		key := cc.currentPeer
+		cc.mu.Unlock()
+
+		if peer, ok := cc.findPeer(key); ok {
+			cc.sync.SetTrusted(peer, checked)
+		}
+	})
+	cc.trustCheck.Disable()
+}
+
+func (cc *ChatController) initPeersList() {
+	cc.peersList = widget.NewList(
+		func() int { return len(cc.peers()) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(
+				nil,
+				nil,
+				widget.NewLabel(""),
+				widget.NewLabel(""),
+				nil,
+			)
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			peers := cc.peers()
+			if i >= len(peers) {
+				return
+			}
+			peer := peers[i]
+			container := o.(*fyne.Container)
+			labels := container.Objects
+
+			name := peer.Name
+			if unread := cc.state.Unread(peer.Key()); unread > 0 {
+				name = fmt.Sprintf("%s (%d)", name, unread)
+			}
+			labels[0].(*widget.Label).SetText(name)
+			labels[1].(*widget.Label).SetText(peer.Transport)
+		},
+	)
+
+	cc.peersList.OnSelected = func(id widget.ListItemID) {
+		peers := cc.peers()
+		if id >= len(peers) {
+			return
+		}
+		cc.selectPeer(peers[id])
+		cc.peersList.Unselect(id)
+	}
+	cc.peersList.HideSeparators = true
+}
+
+func (cc *ChatController) initHistoryList(w fyne.Window) {
+	cc.historyList = widget.NewList(
+		func() int { return len(cc.history()) },
+		func() fyne.CanvasObject {
+			text := widget.NewLabel("")
+			text.Wrapping = fyne.TextWrapWord
+
+			return container.NewBorder(
+				widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Italic: true}),
+				nil,
+				nil,
+				widget.NewButton("Copy", nil),
+				text,
+			)
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			history := cc.history()
+			if i >= len(history) {
+				return
+			}
+			entry := history[i]
+			msg := entry.Message
+
+			from := msg.From
+			if entry.Outgoing {
+				from = "You"
+			}
+
+			container := o.(*fyne.Container)
+			objects := container.Objects
+			objects[0].(*widget.Label).SetText(msg.Text)
+			objects[1].(*widget.Label).SetText(fmt.Sprintf("%s, %s, %s", from, msg.Kind, msg.Sent.Format(time.TimeOnly)))
+			objects[2].(*widget.Button).OnTapped = func() {
+				w.Clipboard().SetContent(msg.Text)
+			}
+		},
+	)
+	cc.historyList.HideSeparators = true
+}
+
+func (cc *ChatController) createPeersSection() fyne.CanvasObject {
+	label := widget.NewLabelWithStyle("Peers", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+
+	separator := NewCustomSeparator(
+		color.RGBA{R: 200, G: 200, B: 200, A: 255},
+		2,
+		true,
+	)
+
+	cont := container.NewBorder(label, nil, nil, nil, separator)
+	return container.NewBorder(cont, nil, nil, nil, cc.peersList)
+}
+
+func (cc *ChatController) createConversationSection(w fyne.Window) fyne.CanvasObject {
+	input := widget.NewMultiLineEntry()
+	input.SetPlaceHolder("Type text or paste a link...")
+	input.SetMinRowsVisible(2)
+
+	sendBtn := widget.NewButton("Send", func() {
+		text := strings.TrimSpace(input.Text)
+		if text == "" {
+			return
+		}
+
+		if err := cc.Send(model.MessageText, text); err != nil {
+			log.Println(err)
+			dialog.ShowError(err, w)
+			return
+		}
+		input.SetText("")
+	})
+
+	clipboardBtn := widget.NewButton("Send clipboard", func() {
+		text := w.Clipboard().Content()
+		if text == "" {
+			dialog.ShowInformation("Error", "Clipboard is empty", w)
+			return
+		}
+
+		if err := cc.Send(model.MessageClipboard, text); err != nil {
+			log.Println(err)
+			dialog.ShowError(err, w)
+		}
+	})
+
+	bottom := container.NewBorder(nil, nil, nil, container.NewVBox(sendBtn, clipboardBtn), input)
+	top := container.NewBorder(nil, nil, nil, cc.trustCheck, cc.chatLabel)
+	return container.NewBorder(top, bottom, nil, nil, cc.historyList)
+}
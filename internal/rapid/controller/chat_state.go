@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/0x0FACED/rapid/internal/model"
+)
+
+// maxChatHistory limits stored messages per peer
+const maxChatHistory = 200
+
+// Transports of chat peers
+const (
+	TransportLAN    = "LAN"
+	TransportWebRTC = "WebRTC"
+)
+
+type ChatPeer struct {
+	Name      string
+	Transport string
+	// ip:port for LAN peers, session name for WebRTC peers
+	Address string
+}
+
+func (p ChatPeer) Key() string {
+	return p.Transport + ":" + p.Name
+}
+
+type ChatEntry struct {
+	Message  model.Message
+	Outgoing bool
+}
+
+// ChatState keeps conversation history of every peer
+type ChatState struct {
+	History map[string][]ChatEntry
+	unread  map[string]int
+	mu      sync.RWMutex
+}
+
+func NewChatState() *ChatState {
+	return &ChatState{
+		History: make(map[string][]ChatEntry),
+		unread:  make(map[string]int),
+	}
+}
+
+func (c *ChatState) Add(peerKey string, msg model.Message, outgoing bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	history := append(c.History[peerKey], ChatEntry{Message: msg, Outgoing: outgoing})
+	if len(history) > maxChatHistory {
+		history = history[len(history)-maxChatHistory:]
+	}
+	c.History[peerKey] = history
+
+	if !outgoing {
+		c.unread[peerKey]++
+	}
+}
+
+func (c *ChatState) Get(peerKey string) []ChatEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	history := c.History[peerKey]
+	result := make([]ChatEntry, len(history))
+	copy(result, history)
+	return result
+}
+
+func (c *ChatState) Unread(peerKey string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.unread[peerKey]
+}
+
+func (c *ChatState) MarkRead(peerKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.unread, peerKey)
+}
+
+// Keys returns keys of peers with history
+func (c *ChatState) Keys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0, len(c.History))
+	for key := range c.History {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortChatPeers(peers []ChatPeer) {
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Key() < peers[j].Key()
+	})
+}
@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/lan/server"
+	"github.com/0x0FACED/rapid/internal/model"
+	"golang.design/x/clipboard"
+)
+
+var ErrClipboardUnavailable = errors.New("clipboard is not available")
+
+var clipboardFormats = map[string]clipboard.Format{
+	model.ClipboardText:  clipboard.FmtText,
+	model.ClipboardImage: clipboard.FmtImage,
+}
+
+// ClipboardSync watches local clipboard and sends its changes to
+// trusted peers, updates from trusted peers are written to the clipboard
+type ClipboardSync struct {
+	chat   *ChatController
+	server *server.LANServer
+
+	enabled bool
+	cancel  context.CancelFunc
+	// key of ChatPeer -> its address when it was trusted, LAN peer
+	// that shows up at another address is not trusted
+	trusted map[string]string
+	// hash of the last sent or applied content of each format,
+	// the watcher sees applied content as a local change
+	last map[string][sha256.Size]byte
+
+	mu sync.Mutex
+}
+
+func NewClipboardSync(chat *ChatController, s *server.LANServer) *ClipboardSync {
+	return &ClipboardSync{
+		chat:    chat,
+		server:  s,
+		trusted: make(map[string]string),
+		last:    make(map[string][sha256.Size]byte),
+	}
+}
+
+// Enabled reports whether sync mode is on
+func (cs *ClipboardSync) Enabled() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.enabled
+}
+
+// Start watches local clipboard until Stop is called
+func (cs *ClipboardSync) Start() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.enabled {
+		return nil
+	}
+	if err := clipboard.Init(); err != nil {
+		return fmt.Errorf("%w: %w", ErrClipboardUnavailable, err)
+	}
+
+	// current contents are not a change
+	for format, clipFormat := range clipboardFormats {
+		if data := clipboard.Read(clipFormat); len(data) > 0 {
+			cs.last[format] = sha256.Sum256(data)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for format, clipFormat := range clipboardFormats {
+		go cs.watch(ctx, format, clipboard.Watch(ctx, clipFormat))
+	}
+
+	cs.cancel = cancel
+	cs.enabled = true
+	cs.server.SetOnClipboard(cs.onLANClipboard)
+	return nil
+}
+
+func (cs *ClipboardSync) Stop() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if !cs.enabled {
+		return
+	}
+	cs.cancel()
+	cs.cancel = nil
+	cs.enabled = false
+	// server rejects updates while sync is off
+	cs.server.SetOnClipboard(nil)
+}
+
+// SetTrusted allows syncing with the peer
+func (cs *ClipboardSync) SetTrusted(peer ChatPeer, trusted bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if trusted {
+		cs.trusted[peer.Key()] = peer.Address
+	} else {
+		delete(cs.trusted, peer.Key())
+	}
+}
+
+func (cs *ClipboardSync) Trusted(peer ChatPeer) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	address, ok := cs.trusted[peer.Key()]
+	return ok && address == peer.Address
+}
+
+func (cs *ClipboardSync) watch(ctx context.Context, format string, changes <-chan []byte) {
+	for {
+		select {
+		case data, ok := <-changes:
+			if !ok {
+				return
+			}
+			cs.onLocalChange(format, data)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (cs *ClipboardSync) onLocalChange(format string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if len(data) > model.MaxClipboardSize {
+		log.Printf("Clipboard %s is too large to sync: %d bytes", format, len(data))
+		return
+	}
+	if !cs.remember(format, data) {
+		return
+	}
+
+	update := model.NewClipboardUpdate(cs.chat.instName, format, data)
+	for _, peer := range cs.chat.peers() {
+		if !cs.Trusted(peer) {
+			continue
+		}
+		if err := cs.send(peer, update); err != nil {
+			log.Println("Failed to sync clipboard with", peer.Name, err)
+		}
+	}
+}
+
+// remember stores hash of the content and reports whether it's new
+func (cs *ClipboardSync) remember(format string, data []byte) bool {
+	hash := sha256.Sum256(data)
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.last[format] == hash {
+		return false
+	}
+	cs.last[format] = hash
+	return true
+}
+
+func (cs *ClipboardSync) send(peer ChatPeer, update model.ClipboardUpdate) error {
+	switch peer.Transport {
+	case TransportLAN:
+		if peer.Address == "" {
+			return fmt.Errorf("address of %s is unknown", peer.Name)
+		}
+		return cs.chat.client.SendClipboard(peer.Address, update)
+	case TransportWebRTC:
+		return cs.chat.net.Sessions().SendClipboard(peer.Address, update)
+	}
+	return fmt.Errorf("unknown transport: %s", peer.Transport)
+}
+
+// apply writes update from the peer to local clipboard
+func (cs *ClipboardSync) apply(peer ChatPeer, update model.ClipboardUpdate) {
+	if !cs.Enabled() || !cs.Trusted(peer) {
+		log.Println("Clipboard update from", peer.Name, "ignored")
+		return
+	}
+
+	format, ok := clipboardFormats[update.Format]
+	if !ok {
+		return
+	}
+	// the watcher will see this content, it must not be sent back
+	if !cs.remember(update.Format, update.Data) {
+		return
+	}
+	clipboard.Write(format, update.Data)
+}
+
+// onLANClipboard checks sender by its address, the name in the update
+// is written by the sender and anyone on the network can use it
+func (cs *ClipboardSync) onLANClipboard(update model.ClipboardUpdate, host string) {
+	peer, ok := cs.chat.lanPeerAt(update.From, host)
+	if !ok {
+		log.Println("Clipboard update from unknown host", host, "ignored")
+		return
+	}
+	cs.apply(peer, update)
+}
+
+func (cs *ClipboardSync) onWebRTCClipboard(session *PeerSession, update model.ClipboardUpdate) {
+	cs.apply(webRTCPeer(session), update)
+}
+
+// CreateOptionsContent returns sync switch for the Options tab
+func (cs *ClipboardSync) CreateOptionsContent(window fyne.Window) fyne.CanvasObject {
+	var enableCheck *widget.Check
+	enableCheck = widget.NewCheck("Sync clipboard with trusted peers", func(checked bool) {
+		if !checked {
+			cs.Stop()
+			return
+		}
+		if err := cs.Start(); err != nil {
+			dialog.ShowError(err, window)
+			enableCheck.SetChecked(false)
+		}
+	})
+	enableCheck.SetChecked(cs.Enabled())
+
+	return container.NewVBox(
+		enableCheck,
+		widget.NewLabel(fmt.Sprintf(
+			"Text and images up to %d KiB are synced. Trust peers in the Chat tab.",
+			model.MaxClipboardSize/1024,
+		)),
+	)
+}
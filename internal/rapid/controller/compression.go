@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/lan/client"
+)
+
+// CreateCompressionOptions returns compression switch for the Options tab,
+// it applies to new LAN and WebRTC downloads
+func CreateCompressionOptions(c *client.LANClient, sessions *SessionManager) fyne.CanvasObject {
+	check := widget.NewCheck("Compress downloads (zstd, gzip)", func(checked bool) {
+		c.SetCompression(checked)
+		sessions.SetCompression(checked)
+	})
+	check.SetChecked(c.Compression() && sessions.Compression())
+
+	return container.NewVBox(
+		check,
+		widget.NewLabel("Images, video, archives and other compressed formats are sent as is."),
+	)
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/p2p/wire"
+	"github.com/caiguanhao/readqr"
+)
+
+// Files used to exchange offer and answer without clipboard images
+const (
+	OfferFileExt  = ".rapid-offer"
+	AnswerFileExt = ".rapid-answer"
+)
+
+// maxCarrierSize limits loaded files, QR images are the largest of them
+const maxCarrierSize = 10 * 1024 * 1024
+
+var qrImageExts = []string{".png", ".jpg", ".jpeg", ".gif"}
+
+// ReadCarrier returns payload stored in a carrier file, QR images
+// are decoded and any other content is treated as text
+func ReadCarrier(name string, data []byte) (string, error) {
+	if isImage(name, data) {
+		payload, err := readqr.Decode(bytes.NewReader(data))
+		if err != nil {
+			return "", fmt.Errorf("failed to read QR code: %w", err)
+		}
+		return payload, nil
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+func isImage(name string, data []byte) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, imageExt := range qrImageExts {
+		if ext == imageExt {
+			return true
+		}
+	}
+	return strings.HasPrefix(http.DetectContentType(data), "image/")
+}
+
+// carrier describes one side of the exchange in the UI
+type carrier struct {
+	// "offer" or "answer"
+	kind string
+	ext  string
+}
+
+// payloadReceiver joins QR frames from any carrier and passes
+// complete payload to accept
+func payloadReceiver(window fyne.Window, accept func(string) error) func(string) {
+	var frames wire.FrameCollector
+
+	return func(raw string) {
+		complete, err := frames.Add(raw)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if !complete {
+			received, total := frames.Progress()
+			dialog.ShowInformation("Info", fmt.Sprintf("Frame %d/%d read, load the next one", received, total), window)
+			return
+		}
+
+		payload := frames.Payload()
+		frames.Reset()
+		if err := accept(payload); err != nil {
+			dialog.ShowError(err, window)
+		}
+	}
+}
+
+// createCarrierButtons returns alternatives to clipboard QR images:
+// copyable text, exchange files and QR image files
+func createCarrierButtons(
+	window fyne.Window,
+	out carrier,
+	payload func() string,
+	in carrier,
+	receive func(string),
+) fyne.CanvasObject {
+	copyBtn := widget.NewButton("Copy "+out.kind+" text", func() {
+		text := payload()
+		if text == "" {
+			dialog.ShowInformation("Error", "Create "+out.kind+" first", window)
+			return
+		}
+
+		window.Clipboard().SetContent(text)
+		dialog.ShowInformation("Success", "Text copied to clipboard", window)
+	})
+
+	saveBtn := widget.NewButton("Save "+out.kind+" file", func() {
+		text := payload()
+		if text == "" {
+			dialog.ShowInformation("Error", "Create "+out.kind+" first", window)
+			return
+		}
+
+		save := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if w == nil {
+				return
+			}
+			defer w.Close()
+
+			if _, err := io.WriteString(w, text); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}, window)
+		save.SetFileName("connection" + out.ext)
+		save.Show()
+	})
+
+	textBtn := widget.NewButton("Enter "+in.kind+" text", func() {
+		entry := widget.NewMultiLineEntry()
+		entry.SetPlaceHolder("Paste " + in.kind + " text...")
+		entry.SetMinRowsVisible(4)
+
+		dialog.ShowForm("Enter "+in.kind, "Accept", "Cancel", []*widget.FormItem{
+			widget.NewFormItem("", entry),
+		}, func(ok bool) {
+			if ok {
+				receive(entry.Text)
+			}
+		}, window)
+	})
+
+	loadBtn := widget.NewButton("Load "+in.kind+" file", func() {
+		open := dialog.NewFileOpen(func(r fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if r == nil {
+				return
+			}
+			defer r.Close()
+
+			data, err := io.ReadAll(io.LimitReader(r, maxCarrierSize+1))
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if len(data) > maxCarrierSize {
+				dialog.ShowError(errors.New("file is too large"), window)
+				return
+			}
+
+			raw, err := ReadCarrier(r.URI().Name(), data)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			receive(raw)
+		}, window)
+		open.SetFilter(storage.NewExtensionFileFilter(append([]string{in.ext}, qrImageExts...)))
+		open.Show()
+	})
+
+	return container.NewGridWithColumns(2, copyBtn, saveBtn, textBtn, loadBtn)
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/internal/search"
+)
+
+var groupOptions = []string{"No grouping", "Group by folder", "Group by type"}
+
+// FileListHeader is header of file list, clicking a column sorts the list
+// by it and clicking it again reverses the order. It keeps the view, so
+// lists that replace their state show new files the same way.
+type FileListHeader struct {
+	keys     []SortKey
+	state    func() *FileState
+	onChange func()
+
+	view    FileView
+	buttons []*widget.Button
+	group   *widget.Select
+	content fyne.CanvasObject
+}
+
+// NewFileListHeader makes header with columns for keys, the first
+// one is leading, the rest are trailing
+func NewFileListHeader(keys []SortKey, state func() *FileState, onChange func()) *FileListHeader {
+	h := &FileListHeader{
+		keys:     keys,
+		state:    state,
+		onChange: onChange,
+		view:     DefaultFileView(),
+	}
+
+	for _, key := range keys {
+		button := widget.NewButton(key.Name, func() { h.toggle(key) })
+		button.Importance = widget.LowImportance
+		button.IconPlacement = widget.ButtonIconTrailingText
+		h.buttons = append(h.buttons, button)
+	}
+
+	h.group = widget.NewSelect(groupOptions, nil)
+	h.group.SetSelectedIndex(int(h.view.Group))
+	h.group.OnChanged = func(option string) {
+		for i, o := range groupOptions {
+			if o == option {
+				h.view.Group = GroupBy(i)
+			}
+		}
+		h.Apply()
+	}
+
+	trailing := container.NewHBox(h.group)
+	for _, button := range h.buttons[1:] {
+		trailing.Add(button)
+	}
+	h.content = container.NewBorder(nil, nil, h.buttons[0], trailing)
+
+	h.updateButtons()
+	return h
+}
+
+func (h *FileListHeader) Content() fyne.CanvasObject {
+	return h.content
+}
+
+// SetQuery filters the list, invalid query is returned and ignored
+func (h *FileListHeader) SetQuery(query string) error {
+	if _, err := search.Parse(query); err != nil {
+		return err
+	}
+	h.view.Query = query
+	h.Apply()
+	return nil
+}
+
+// Apply shows current state of the list with the header view
+func (h *FileListHeader) Apply() {
+	if state := h.state(); state != nil {
+		_ = state.SetView(h.view)
+	}
+	h.onChange()
+}
+
+func (h *FileListHeader) toggle(key SortKey) {
+	if h.view.Sort.Name == key.Name {
+		h.view.Descending = !h.view.Descending
+	} else {
+		h.view.Sort = key
+		h.view.Descending = false
+	}
+	h.updateButtons()
+	h.Apply()
+}
+
+func (h *FileListHeader) updateButtons() {
+	for i, key := range h.keys {
+		var icon fyne.Resource
+		if key.Name == h.view.Sort.Name {
+			icon = theme.MoveUpIcon()
+			if h.view.Descending {
+				icon = theme.MoveDownIcon()
+			}
+		}
+		h.buttons[i].SetIcon(icon)
+	}
+}
+
+// newSearchEntry filters the list through its header and marks invalid queries
+func newSearchEntry(header *FileListHeader) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("Search: name, *.go, /regexp/, ext:jpg, size:>1MB")
+	entry.Validator = func(query string) error {
+		_, err := search.Parse(query)
+		return err
+	}
+	entry.OnChanged = func(query string) {
+		_ = header.SetQuery(query)
+	}
+	return entry
+}
+
+// newFileList shows rows of the state with group headers. Previews and
+// onHover may be nil, onSelected is called for files only.
+func newFileList(
+	state func() *FileState,
+	previews *PreviewCache,
+	onHover func(model.File),
+	onSelected func(model.File),
+) *widget.List {
+	var list *widget.List
+	list = widget.NewList(
+		func() int { return len(state().Items()) },
+		func() fyne.CanvasObject {
+			return NewFileRow()
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			items := state().Items()
+			if i >= len(items) {
+				return
+			}
+			item := items[i]
+			row := o.(*FileRow)
+			if item.IsHeader() {
+				row.ShowHeader(item.Header)
+				return
+			}
+
+			var hover func()
+			if onHover != nil {
+				hover = func() { onHover(item.File.File) }
+			}
+			row.Update(item.File.File, previews, hover)
+		},
+	)
+
+	list.OnSelected = func(id widget.ListItemID) {
+		items := state().Items()
+		list.Unselect(id)
+		if id >= len(items) || items[id].IsHeader() {
+			return
+		}
+		if onSelected != nil {
+			onSelected(items[id].File.File)
+		}
+	}
+	list.HideSeparators = true
+	return list
+}
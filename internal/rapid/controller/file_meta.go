@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"os"
+
+	"github.com/0x0FACED/rapid/internal/model"
+)
+
+// restoreMetadata applies modification time and permissions of the peer's
+// file to the downloaded copy, unknown values are left as is
+func restoreMetadata(path string, file model.File) error {
+	if file.Mode != 0 {
+		// owner keeps read and write access, so the copy can be updated later
+		if err := os.Chmod(path, file.Mode.Perm()|0o600); err != nil {
+			return err
+		}
+	}
+	if !file.ModTime.IsZero() {
+		if err := os.Chtimes(path, file.ModTime, file.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
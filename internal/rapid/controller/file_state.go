@@ -1,73 +1,313 @@
 package controller
 
 import (
-	"sort"
+	"cmp"
+	"path"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/internal/search"
 )
 
+// FileEntry is file of the list with the peer that shares it
+type FileEntry struct {
+	model.File
+	Peer string
+}
+
+// SortKey orders file lists, lists may use any keys besides the built-in ones
+type SortKey struct {
+	Name    string
+	Compare func(a, b FileEntry) int
+}
+
+var (
+	SortByName = SortKey{Name: "Name", Compare: func(a, b FileEntry) int {
+		return compareFold(a.Name, b.Name)
+	}}
+	SortBySize = SortKey{Name: "Size", Compare: func(a, b FileEntry) int {
+		return cmp.Compare(a.Size, b.Size)
+	}}
+	SortByType = SortKey{Name: "Type", Compare: func(a, b FileEntry) int {
+		return compareFold(fileType(a.File), fileType(b.File))
+	}}
+	SortByDate = SortKey{Name: "Date", Compare: func(a, b FileEntry) int {
+		return a.ModTime.Compare(b.ModTime)
+	}}
+	SortByPeer = SortKey{Name: "Peer", Compare: func(a, b FileEntry) int {
+		return compareFold(a.Peer, b.Peer)
+	}}
+)
+
+type GroupBy int
+
+const (
+	GroupNone GroupBy = iota
+	GroupFolder
+	GroupType
+)
+
+// FileView is how file list is sorted, filtered and grouped
+type FileView struct {
+	Sort       SortKey
+	Descending bool
+	Group      GroupBy
+	// see search.Query for syntax
+	Query string
+}
+
+func DefaultFileView() FileView {
+	return FileView{Sort: SortByName}
+}
+
+// FileItem is row of file list, rows with Header start a group
+type FileItem struct {
+	Header string
+	File   FileEntry
+}
+
+func (i FileItem) IsHeader() bool {
+	return i.Header != ""
+}
+
 type FileState struct {
-	Files         map[string]model.File
+	Files map[string]model.File
+	// visible files in list order
 	FilteredFiles []model.File
 	SearchQuery   string
-	mu            sync.RWMutex
-	sortedIDs     []string
+	mu            sync.Mutex
+
+	peers  map[string]string
+	view   FileView
+	filter search.Query
+	// all files in list order and visible rows, rebuilt on read after changes
+	sorted []FileEntry
+	items  []FileItem
+	dirty  bool
 }
 
 func NewFileState() *FileState {
 	return &FileState{
 		Files:         make(map[string]model.File),
 		FilteredFiles: make([]model.File, 0),
+		peers:         make(map[string]string),
+		view:          DefaultFileView(),
 	}
 }
 
 func (f *FileState) Add(id string, file model.File) {
+	f.AddFromPeer("", id, file)
+}
+
+// AddFromPeer adds file shared by the peer, lists can be sorted by peers
+func (f *FileState) AddFromPeer(peer, id string, file model.File) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	if _, exists := f.Files[id]; !exists {
-		f.sortedIDs = append(f.sortedIDs, id)
-		sort.Slice(f.sortedIDs, func(i, j int) bool {
-			return f.sortedIDs[i] < f.sortedIDs[j]
-		})
-	}
 	f.Files[id] = file
+	f.peers[id] = peer
+	f.dirty = true
+}
 
-	if f.SearchQuery != "" {
-		f.Filter(f.SearchQuery)
+func (f *FileState) Remove(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.Files[id]; !exists {
+		return
 	}
+	delete(f.Files, id)
+	delete(f.peers, id)
+	f.dirty = true
 }
 
+// GetAll returns visible files in list order
 func (f *FileState) GetAll() []model.File {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
-
-	if f.SearchQuery == "" {
-		result := make([]model.File, 0, len(f.sortedIDs))
-		for _, id := range f.sortedIDs {
-			if file, exists := f.Files[id]; exists {
-				result = append(result, file)
-			}
-		}
-		return result
-	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
+	f.rebuild()
 	return f.FilteredFiles
 }
 
-func (f *FileState) Filter(query string) {
+// Items returns visible rows with group headers
+func (f *FileState) Items() []FileItem {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	f.SearchQuery = strings.ToLower(query)
-	f.FilteredFiles = make([]model.File, 0)
+	f.rebuild()
+	return f.items
+}
+
+// Unfiltered returns all files ignoring current search query
+func (f *FileState) Unfiltered() []model.File {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	for _, id := range f.sortedIDs {
-		file := f.Files[id]
-		if strings.Contains(strings.ToLower(file.Name), f.SearchQuery) {
-			f.FilteredFiles = append(f.FilteredFiles, file)
+	f.rebuild()
+	result := make([]model.File, 0, len(f.sorted))
+	for _, entry := range f.sorted {
+		result = append(result, entry.File)
+	}
+	return result
+}
+
+// Filter shows only files matching the query, invalid query
+// keeps the previous filter
+func (f *FileState) Filter(query string) error {
+	filter, err := search.Parse(query)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.view.Query = query
+	f.SearchQuery = query
+	f.filter = filter
+	f.dirty = true
+	return nil
+}
+
+// Sort orders the list by the key
+func (f *FileState) Sort(key SortKey, descending bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.view.Sort = key
+	f.view.Descending = descending
+	f.dirty = true
+}
+
+func (f *FileState) GroupBy(group GroupBy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.view.Group = group
+	f.dirty = true
+}
+
+func (f *FileState) View() FileView {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.view
+}
+
+// SetView applies sorting, grouping and filter of other list
+func (f *FileState) SetView(view FileView) error {
+	if err := f.Filter(view.Query); err != nil {
+		return err
+	}
+	f.Sort(view.Sort, view.Descending)
+	f.GroupBy(view.Group)
+	return nil
+}
+
+// rebuild must be called with lock held
+func (f *FileState) rebuild() {
+	if !f.dirty && f.items != nil {
+		return
+	}
+	f.dirty = false
+
+	f.sorted = make([]FileEntry, 0, len(f.Files))
+	for id, file := range f.Files {
+		f.sorted = append(f.sorted, FileEntry{File: file, Peer: f.peers[id]})
+	}
+	slices.SortFunc(f.sorted, f.compare)
+
+	visible := make([]FileEntry, 0, len(f.sorted))
+	for _, entry := range f.sorted {
+		if f.filter.Match(entry.File) {
+			visible = append(visible, entry)
+		}
+	}
+
+	f.FilteredFiles = make([]model.File, 0, len(visible))
+	f.items = make([]FileItem, 0, len(visible))
+
+	if f.view.Group == GroupNone {
+		for _, entry := range visible {
+			f.FilteredFiles = append(f.FilteredFiles, entry.File)
+			f.items = append(f.items, FileItem{File: entry})
 		}
+		return
 	}
+
+	// groups are ordered by name, files keep list order inside them
+	groups := make(map[string][]FileEntry)
+	names := make([]string, 0)
+	for _, entry := range visible {
+		name := fileGroup(entry.File, f.view.Group)
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], entry)
+	}
+	slices.SortFunc(names, compareFold)
+
+	for _, name := range names {
+		f.items = append(f.items, FileItem{Header: name})
+		for _, entry := range groups[name] {
+			f.FilteredFiles = append(f.FilteredFiles, entry.File)
+			f.items = append(f.items, FileItem{File: entry})
+		}
+	}
+}
+
+// compare uses names and ids for equal keys, so the order is stable
+func (f *FileState) compare(a, b FileEntry) int {
+	c := 0
+	if f.view.Sort.Compare != nil {
+		c = f.view.Sort.Compare(a, b)
+	}
+	if c == 0 {
+		c = compareFold(a.Name, b.Name)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if f.view.Descending {
+		return -c
+	}
+	return c
+}
+
+// fileGroup returns title of the file group
+func fileGroup(file model.File, group GroupBy) string {
+	switch group {
+	case GroupFolder:
+		// peers don't send local paths, their files are grouped by names
+		dir := path.Dir(filepath.ToSlash(file.Name))
+		if file.Path != "" {
+			dir = filepath.Dir(file.Path)
+		}
+		if dir == "." || dir == "" {
+			return "No folder"
+		}
+		return dir
+	case GroupType:
+		if t := fileType(file); t != "" {
+			return t
+		}
+		return "Unknown type"
+	}
+	return ""
+}
+
+// fileType is MIME type of the file or its extension when type is unknown
+func fileType(file model.File) string {
+	if file.MIMEType != "" {
+		mediaType, _, _ := strings.Cut(file.MIMEType, ";")
+		return mediaType
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Name)), ".")
+}
+
+func compareFold(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
 }
@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/lan/server"
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/internal/watch"
+)
+
+// FolderShare shares every file of the watched folder with LAN
+// and WebRTC peers and keeps shared list in sync with the folder
+type FolderShare struct {
+	server *server.LANServer
+	lan    *LANController
+	net    *NetController
+
+	dir    string
+	cancel context.CancelFunc
+	done   chan struct{}
+	// path -> shared file
+	files map[string]model.File
+
+	mu sync.Mutex
+}
+
+func NewFolderShare(s *server.LANServer, lan *LANController, net *NetController) *FolderShare {
+	return &FolderShare{
+		server: s,
+		lan:    lan,
+		net:    net,
+		files:  make(map[string]model.File),
+	}
+}
+
+// Dir returns watched folder, it's empty when nothing is watched
+func (fs *FolderShare) Dir() string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.dir
+}
+
+// Start shares files of the dir, previous folder is unshared
+func (fs *FolderShare) Start(dir string) error {
+	folder, err := watch.New(dir, watch.DefaultDebounce)
+	if err != nil {
+		return err
+	}
+
+	fs.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	fs.mu.Lock()
+	fs.dir = folder.Dir()
+	fs.cancel = cancel
+	fs.done = done
+	fs.mu.Unlock()
+
+	go func() {
+		if err := folder.Run(ctx); err != nil {
+			log.Println("Failed to watch folder:", err)
+		}
+	}()
+	go func() {
+		defer close(done)
+		for event := range folder.Events() {
+			fs.handle(event)
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops watching and unshares files of the folder
+func (fs *FolderShare) Stop() {
+	fs.mu.Lock()
+	cancel, done := fs.cancel, fs.done
+	fs.cancel, fs.done = nil, nil
+	fs.dir = ""
+	fs.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+
+	fs.mu.Lock()
+	files := fs.files
+	fs.files = make(map[string]model.File)
+	fs.mu.Unlock()
+
+	for _, file := range files {
+		fs.unshare(file)
+	}
+}
+
+func (fs *FolderShare) handle(event watch.Event) {
+	fs.mu.Lock()
+	file, shared := fs.files[event.Path]
+	fs.mu.Unlock()
+
+	switch event.Op {
+	case watch.Changed:
+		if shared {
+			fs.update(event.Path, file)
+		} else {
+			fs.share(event.Path)
+		}
+	case watch.Removed:
+		if shared {
+			fs.mu.Lock()
+			delete(fs.files, event.Path)
+			fs.mu.Unlock()
+			fs.unshare(file)
+		}
+	}
+}
+
+func (fs *FolderShare) share(path string) {
+	file, err := fs.server.ShareLocal(path)
+	if err != nil {
+		log.Println("Failed to share", path, err)
+		return
+	}
+	file, err = fs.server.Update(file.ID)
+	if err != nil {
+		log.Println("Failed to hash", path, err)
+		_ = fs.server.Unshare(file.ID)
+		return
+	}
+
+	fs.mu.Lock()
+	fs.files[path] = file
+	fs.mu.Unlock()
+
+	fs.lan.addShared(file)
+	fs.net.addShared(file)
+}
+
+// update hashes the file again, peers are notified only if content changed
+func (fs *FolderShare) update(path string, old model.File) {
+	file, err := fs.server.Update(old.ID)
+	if err != nil {
+		log.Println("Failed to hash", path, err)
+		return
+	}
+	if file.Hash == old.Hash && file.Size == old.Size {
+		return
+	}
+
+	fs.mu.Lock()
+	fs.files[path] = file
+	fs.mu.Unlock()
+
+	fs.lan.addShared(file)
+	fs.net.addShared(file)
+}
+
+func (fs *FolderShare) unshare(file model.File) {
+	if err := fs.server.Unshare(file.ID); err != nil && !errors.Is(err, server.ErrFileNotShared) {
+		log.Println("Failed to unshare", file.Path, err)
+	}
+	fs.lan.removeShared(file.ID)
+	fs.net.removeShared(file.ID)
+}
+
+// CreateOptionsContent returns watched folder settings for the Options tab
+func (fs *FolderShare) CreateOptionsContent(window fyne.Window) fyne.CanvasObject {
+	dirLabel := widget.NewLabel("")
+	updateLabel := func() {
+		if dir := fs.Dir(); dir != "" {
+			dirLabel.SetText("Watching: " + dir)
+		} else {
+			dirLabel.SetText("No folder is watched")
+		}
+	}
+	updateLabel()
+
+	chooseBtn := widget.NewButton("Choose folder", func() {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if uri == nil {
+				return
+			}
+
+			if err := fs.Start(uri.Path()); err != nil {
+				dialog.ShowError(err, window)
+			}
+			updateLabel()
+		}, window)
+	})
+
+	stopBtn := widget.NewButton("Stop", func() {
+		fs.Stop()
+		updateLabel()
+	})
+
+	return container.NewVBox(
+		dirLabel,
+		widget.NewLabel("New files are shared automatically, removed files are unshared."),
+		container.NewGridWithColumns(2, chooseBtn, stopBtn),
+	)
+}
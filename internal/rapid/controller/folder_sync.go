@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/lan/client"
+	"github.com/0x0FACED/rapid/internal/lan/server"
+	"github.com/0x0FACED/rapid/internal/mirror"
+)
+
+// FolderSyncController mirrors local folders to LAN and WebRTC peers
+// and accepts folders mirrored to us
+type FolderSyncController struct {
+	client *client.LANClient
+	server *server.LANServer
+	lan    *LANController
+	net    *NetController
+	store  *mirror.Store
+
+	// LAN peers send it to mirror folders to us
+	token  string
+	accept bool
+	mu     sync.Mutex
+}
+
+func NewFolderSyncController(
+	c *client.LANClient,
+	s *server.LANServer,
+	lan *LANController,
+	net *NetController,
+) *FolderSyncController {
+	return &FolderSyncController{
+		client: c,
+		server: s,
+		lan:    lan,
+		net:    net,
+		store:  mirror.NewStore(filepath.Join(s.DownloadsDir(), mirror.DefaultDir)),
+		token:  mirror.NewToken(),
+	}
+}
+
+// SetAccept enables folders mirrored to us by LAN peers with the sync token
+// and by WebRTC peers, they are authenticated by the connection password
+func (fc *FolderSyncController) SetAccept(accept bool) {
+	fc.mu.Lock()
+	fc.accept = accept
+	token := fc.token
+	fc.mu.Unlock()
+
+	var store *mirror.Store
+	if accept {
+		store = fc.store
+	}
+	fc.server.SetMirrorStore(store, token)
+	fc.net.Sessions().SetMirrorStore(store)
+}
+
+// Token returns sync token that LAN peers need to mirror folders to us
+func (fc *FolderSyncController) Token() string {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.token
+}
+
+// ResetToken makes new sync token, peers with the old one are rejected
+func (fc *FolderSyncController) ResetToken() {
+	fc.mu.Lock()
+	fc.token = mirror.NewToken()
+	accept := fc.accept
+	fc.mu.Unlock()
+
+	fc.SetAccept(accept)
+}
+
+// peers returns sync targets by their label in the UI,
+// token is used by LAN peers only
+func (fc *FolderSyncController) peers() map[string]func(token string) (mirror.Peer, error) {
+	peers := make(map[string]func(string) (mirror.Peer, error))
+
+	for _, server := range fc.lan.Servers() {
+		addr := server.Address()
+		label := fmt.Sprintf("LAN: %s (%s)", server.InstanceName, addr)
+		peers[label] = func(token string) (mirror.Peer, error) {
+			if token == "" {
+				return nil, errors.New("enter sync token of the LAN peer")
+			}
+			return fc.client.MirrorPeer(addr, token), nil
+		}
+	}
+
+	for _, session := range fc.net.Sessions().GetAll() {
+		if !session.Connected() {
+			continue
+		}
+		name := session.Name
+		peers["WebRTC: "+name] = func(string) (mirror.Peer, error) {
+			return fc.net.Sessions().MirrorPeer(name)
+		}
+	}
+	return peers
+}
+
+func (fc *FolderSyncController) peerLabels() []string {
+	peers := fc.peers()
+	labels := make([]string, 0, len(peers))
+	for label := range peers {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// CreateOptionsContent returns folder sync settings for the Options tab
+func (fc *FolderSyncController) CreateOptionsContent(window fyne.Window) fyne.CanvasObject {
+	acceptCheck := widget.NewCheck("Accept folders mirrored by peers", fc.SetAccept)
+
+	tokenLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+	showToken := func() { tokenLabel.SetText("Sync token: " + fc.Token()) }
+	showToken()
+	copyTokenBtn := widget.NewButton("Copy", func() {
+		window.Clipboard().SetContent(fc.Token())
+	})
+	resetTokenBtn := widget.NewButton("New token", func() {
+		fc.ResetToken()
+		showToken()
+	})
+
+	rootEntry := widget.NewEntry()
+	rootEntry.SetPlaceHolder("Local folder")
+	folderEntry := widget.NewEntry()
+	folderEntry.SetPlaceHolder("Remote folder name")
+
+	chooseBtn := widget.NewButton("Choose", func() {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if uri == nil {
+				return
+			}
+			rootEntry.SetText(uri.Path())
+			if folderEntry.Text == "" {
+				folderEntry.SetText(filepath.Base(uri.Path()))
+			}
+		}, window)
+	})
+
+	peerSelect := widget.NewSelect(fc.peerLabels(), nil)
+	peerSelect.PlaceHolder = "Select peer"
+	refreshBtn := widget.NewButton("Refresh", func() {
+		peerSelect.SetOptions(fc.peerLabels())
+	})
+
+	tokenEntry := widget.NewEntry()
+	tokenEntry.SetPlaceHolder("Sync token of the LAN peer")
+
+	deleteCheck := widget.NewCheck("Delete files removed locally", nil)
+
+	previewBtn := widget.NewButton("Preview and sync", func() {
+		resolve, ok := fc.peers()[peerSelect.Selected]
+		if !ok {
+			dialog.ShowInformation("Error", "Select a peer first", window)
+			return
+		}
+		peer, err := resolve(strings.TrimSpace(tokenEntry.Text))
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		job := &mirror.Job{
+			Name:   folderEntry.Text,
+			Root:   rootEntry.Text,
+			Folder: folderEntry.Text,
+			Peer:   peerSelect.Selected,
+			Delete: deleteCheck.Checked,
+		}
+		if err := job.Validate(); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		go fc.previewAndRun(window, job, peer)
+	})
+
+	return container.NewVBox(
+		acceptCheck,
+		container.NewBorder(nil, nil, nil, container.NewHBox(copyTokenBtn, resetTokenBtn), tokenLabel),
+		widget.NewLabel("Mirror a local folder to a peer, only new and changed files are sent."),
+		container.NewBorder(nil, nil, nil, chooseBtn, rootEntry),
+		folderEntry,
+		container.NewBorder(nil, nil, nil, refreshBtn, peerSelect),
+		tokenEntry,
+		deleteCheck,
+		previewBtn,
+	)
+}
+
+// previewAndRun shows dry run diff and applies it after confirmation
+func (fc *FolderSyncController) previewAndRun(window fyne.Window, job *mirror.Job, peer mirror.Peer) {
+	plan, err := job.Run(context.Background(), peer, true)
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+	if plan.Empty() {
+		dialog.ShowInformation("Folder sync", "Nothing to sync", window)
+		return
+	}
+
+	diff := widget.NewLabel(plan.String())
+	scroll := container.NewVScroll(diff)
+	scroll.SetMinSize(fyne.NewSize(400, 250))
+
+	dialog.ShowCustomConfirm("Folder sync", "Sync", "Cancel", scroll, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		go func() {
+			if err := job.Apply(context.Background(), peer, plan); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			dialog.ShowInformation("Folder sync", fmt.Sprintf("%d changes synced", len(plan.Changes)), window)
+		}()
+	}, window)
+}
@@ -2,9 +2,12 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image/color"
 	"log"
+	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -15,6 +18,8 @@ import (
 	"github.com/0x0FACED/rapid/internal/lan/client"
 	"github.com/0x0FACED/rapid/internal/lan/server"
 	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/pkg/cdc"
+	"github.com/0x0FACED/rapid/pkg/thumbnail"
 )
 
 type LANController struct {
@@ -26,15 +31,26 @@ type LANController struct {
 	sharedFiles   *FileState
 	serversList   *widget.List
 	receivedList  *widget.List
-	sharedList    *widget.List
-	serversChan   chan model.ServiceInstance
-	currentServer string
-	refreshTicker *time.Ticker
-	shutdownChan  chan struct{}
+	previews      *PreviewCache
+	preview       *PreviewPane
+	// headers keep sorting and filter of the lists
+	receivedHeader *FileListHeader
+	sharedHeader   *FileListHeader
+	sharedList     *widget.List
+	serversChan    chan model.ServiceInstance
+	currentServer  string
+	refreshTicker  *time.Ticker
+	shutdownChan   chan struct{}
+	// chunks of downloaded files, new downloads reuse them
+	chunks *cdc.Store
+	search *LANSearch
 }
 
+// chunksDir is subdirectory of downloads with chunk store
+const chunksDir = ".chunks"
+
 func NewLANController(client *client.LANClient, server *server.LANServer, instName string) *LANController {
-	return &LANController{
+	lc := &LANController{
 		instName:      instName,
 		client:        client,
 		server:        server,
@@ -43,7 +59,10 @@ func NewLANController(client *client.LANClient, server *server.LANServer, instNa
 		sharedFiles:   NewFileState(),
 		serversChan:   make(chan model.ServiceInstance, 20),
 		shutdownChan:  make(chan struct{}),
+		chunks:        cdc.NewStore(filepath.Join(server.DownloadsDir(), chunksDir)),
 	}
+	lc.search = NewLANSearch(lc)
+	return lc
 }
 
 func (lc *LANController) Start(ctx context.Context) {
@@ -101,6 +120,11 @@ func (lc *LANController) checkServersAvailability() {
 	}
 }
 
+// Servers returns discovered LAN servers
+func (lc *LANController) Servers() []model.ServiceInstance {
+	return lc.serverState.GetAll()
+}
+
 func (lc *LANController) refreshUI() {
 	if lc.serversList != nil {
 		lc.serversList.Refresh()
@@ -115,6 +139,11 @@ func (lc *LANController) refreshUI() {
 	}
 }
 
+// Search returns search over all LAN servers
+func (lc *LANController) Search() *LANSearch {
+	return lc.search
+}
+
 func (lc *LANController) CreateLANContent(w fyne.Window) fyne.CanvasObject {
 	lc.initServerList()
 	lc.initReceivedFilesList()
@@ -186,47 +215,38 @@ func (lc *LANController) updateReceivedFiles(server model.ServiceInstance) {
 
 	lc.receivedFiles = NewFileState()
 	for _, file := range files {
-		lc.receivedFiles.Add(file.ID, file)
+		lc.receivedFiles.AddFromPeer(server.InstanceName, file.ID, file)
 	}
-	lc.receivedList.Refresh()
+	lc.preview.Clear()
+	lc.receivedHeader.Apply()
 }
 
 func (lc *LANController) initReceivedFilesList() {
-	lc.receivedList = widget.NewList(
-		func() int { return len(lc.receivedFiles.GetAll()) },
-		func() fyne.CanvasObject {
-			return container.NewBorder(
-				nil,
-				nil,
-				widget.NewLabel(""),
-				widget.NewLabel(""),
-				nil,
-			)
-		},
-		func(i widget.ListItemID, o fyne.CanvasObject) {
-			files := lc.receivedFiles.GetAll()
-			if i >= len(files) {
-				return
-			}
-			file := files[i]
-			container := o.(*fyne.Container)
-			labels := container.Objects
-			labels[0].(*widget.Label).SetText(file.Name)
-			labels[1].(*widget.Label).SetText(file.SizeString())
+	lc.previews = NewPreviewCache(lc.loadPreview)
+	lc.preview = NewPreviewPane(lc.previews)
+	lc.previews.SetOnLoad(func(file model.File) {
+		lc.receivedList.Refresh()
+		lc.preview.Refresh(file)
+	})
+
+	lc.receivedList = newFileList(
+		func() *FileState { return lc.receivedFiles },
+		lc.previews,
+		lc.preview.Show,
+		func(file model.File) {
+			lc.preview.Show(file)
+			lc.downloadFile(file)
 		},
 	)
+}
 
-	lc.receivedList.OnSelected = func(id widget.ListItemID) {
-		files := lc.receivedFiles.GetAll()
-		if id >= len(files) {
-			return
-		}
-		file := files[id]
-		lc.downloadFile(file)
-		lc.receivedList.Unselect(id)
+// loadPreview requests preview of the file from current server
+func (lc *LANController) loadPreview(file model.File) (thumbnail.Thumbnail, error) {
+	server := lc.findCurrentServer()
+	if server == nil {
+		return thumbnail.Thumbnail{}, errors.New("server not found")
 	}
-
-	lc.receivedList.HideSeparators = true
+	return lc.client.GetThumbnail(server.Address(), file.ID, previewSize)
 }
 
 func (lc *LANController) downloadFile(file model.File) {
@@ -239,8 +259,49 @@ func (lc *LANController) downloadFile(file model.File) {
 	if server == nil {
 		return
 	}
+	lc.downloadFrom(*server, file)
+}
+
+func (lc *LANController) downloadFrom(server model.ServiceInstance, file model.File) {
+	if !lc.fetchFile(server, file) {
+		return
+	}
+	if err := restoreMetadata(file.Name, file); err != nil {
+		log.Printf("Error restoring metadata of %s: %v", file.Name, err)
+	}
+}
+
+// fetchFile picks the cheapest way to download the file, false if it failed
+func (lc *LANController) fetchFile(server model.ServiceInstance, file model.File) bool {
+	// old copy is updated with changed blocks only
+	if _, err := os.Stat(file.Name); err == nil {
+		stats, err := lc.client.DownloadDelta(server.Address(), file.ID, file.Name)
+		if err != nil {
+			log.Printf("Error updating file %s: %v", file.Name, err)
+			return false
+		}
+		log.Printf("Updated %s: %d bytes reused, %d bytes downloaded", file.Name, stats.Copied, stats.Literal)
+		return true
+	}
+
+	// same content on several servers is fetched from all of them
+	if sources := lc.swarmSources(server, file); len(sources) > 1 {
+		stats, err := lc.client.SwarmDownload(context.Background(), sources, file.Name, lc.chunks, lc.serverState.HasAddress)
+		if err == nil {
+			log.Printf("Downloaded %s from %d servers: %d bytes reused, %v", file.Name, len(sources), stats.Reused, stats.Downloaded)
+			return true
+		}
+		log.Printf("Swarm download of %s failed: %v", file.Name, err)
+	}
 
-	err := lc.client.DownloadFile(
+	stats, err := lc.client.DownloadChunked(server.Address(), file.ID, file.Name, lc.chunks)
+	if err == nil {
+		log.Printf("Downloaded %s: %d bytes reused, %d bytes downloaded", file.Name, stats.Reused, stats.Downloaded)
+		return true
+	}
+	log.Printf("Chunked download of %s failed, downloading whole file: %v", file.Name, err)
+
+	err = lc.client.DownloadFile(
 		server.IPv4,
 		strconv.Itoa(server.Port),
 		file.ID,
@@ -248,7 +309,38 @@ func (lc *LANController) downloadFile(file model.File) {
 	)
 	if err != nil {
 		log.Printf("Error downloading file %s: %v", file.Name, err)
+		return false
 	}
+	return true
+}
+
+// swarmSources returns current server and other servers that share the
+// same file, candidates without hash are matched by name and size and
+// checked by content hash before download
+func (lc *LANController) swarmSources(current model.ServiceInstance, file model.File) []client.SwarmSource {
+	sources := []client.SwarmSource{{Addr: current.Address(), FileID: file.ID}}
+
+	for _, server := range lc.serverState.GetAll() {
+		if server.Key() == current.Key() || server.InstanceName == lc.instName {
+			continue
+		}
+
+		files, err := lc.client.GetFiles(server.IPv4, strconv.Itoa(server.Port))
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			same := f.Hash != "" && f.Hash == file.Hash
+			if f.Hash == "" || file.Hash == "" {
+				same = f.Name == file.Name && f.Size == file.Size
+			}
+			if same {
+				sources = append(sources, client.SwarmSource{Addr: server.Address(), FileID: f.ID})
+				break
+			}
+		}
+	}
+	return sources
 }
 
 func (lc *LANController) findCurrentServer() *model.ServiceInstance {
@@ -262,30 +354,12 @@ func (lc *LANController) findCurrentServer() *model.ServiceInstance {
 }
 
 func (lc *LANController) initSharedFilesList() {
-	lc.sharedList = widget.NewList(
-		func() int { return len(lc.sharedFiles.GetAll()) },
-		func() fyne.CanvasObject {
-			return container.NewBorder(
-				nil,
-				nil,
-				widget.NewLabel(""),
-				widget.NewLabel(""),
-				nil,
-			)
-		},
-		func(i widget.ListItemID, o fyne.CanvasObject) {
-			files := lc.sharedFiles.GetAll()
-			if i >= len(files) {
-				return
-			}
-			file := files[i]
-			container := o.(*fyne.Container)
-			labels := container.Objects
-			labels[0].(*widget.Label).SetText(file.Name)
-			labels[1].(*widget.Label).SetText(file.SizeString())
-		},
+	lc.sharedList = newFileList(
+		func() *FileState { return lc.sharedFiles },
+		nil,
+		nil,
+		nil,
 	)
-	lc.sharedList.HideSeparators = true
 }
 
 func (lc *LANController) createServerListSection() fyne.CanvasObject {
@@ -309,13 +383,12 @@ func (lc *LANController) createServerListSection() fyne.CanvasObject {
 }
 
 func (lc *LANController) createReceivedFilesSection() fyne.CanvasObject {
-	spacer := layout.NewSpacer()
-
-	header := container.NewGridWithColumns(3,
-		widget.NewLabelWithStyle("Filename", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		spacer,
-		widget.NewLabelWithStyle("Size", fyne.TextAlignTrailing, fyne.TextStyle{Bold: true}),
+	lc.receivedHeader = NewFileListHeader(
+		[]SortKey{SortByName, SortByType, SortByDate, SortBySize},
+		func() *FileState { return lc.receivedFiles },
+		lc.receivedList.Refresh,
 	)
+	header := lc.receivedHeader.Content()
 	label := widget.NewLabelWithStyle("Received Files", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
 
 	separator := NewCustomSeparator(
@@ -324,27 +397,25 @@ func (lc *LANController) createReceivedFilesSection() fyne.CanvasObject {
 		true,
 	)
 
-	searchEntry := widget.NewEntry()
-	searchEntry.SetPlaceHolder("Search...")
-	searchEntry.OnChanged = func(query string) {
-		lc.receivedFiles.Filter(query)
-		lc.receivedList.Refresh()
-	}
+	searchEntry := newSearchEntry(lc.receivedHeader)
 
 	labelCont := container.NewGridWithColumns(2, label, searchEntry)
 
 	cont := container.NewBorder(labelCont, header, nil, nil, separator)
-	return container.NewBorder(cont, nil, nil, nil, lc.receivedList)
+	list := container.NewBorder(cont, nil, nil, nil, lc.receivedList)
+
+	split := container.NewHSplit(list, lc.preview.Content())
+	split.Offset = 0.7
+	return split
 }
 
 func (lc *LANController) createSharedFilesSection() fyne.CanvasObject {
-	spacer := layout.NewSpacer()
-
-	header := container.NewGridWithColumns(3,
-		widget.NewLabelWithStyle("Filename", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		spacer,
-		widget.NewLabelWithStyle("Size", fyne.TextAlignTrailing, fyne.TextStyle{Bold: true}),
+	lc.sharedHeader = NewFileListHeader(
+		[]SortKey{SortByName, SortByType, SortByDate, SortBySize},
+		func() *FileState { return lc.sharedFiles },
+		lc.sharedList.Refresh,
 	)
+	header := lc.sharedHeader.Content()
 	label := widget.NewLabelWithStyle("Your Shared files", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
 
 	separator := NewCustomSeparator(
@@ -353,12 +424,7 @@ func (lc *LANController) createSharedFilesSection() fyne.CanvasObject {
 		true,
 	)
 
-	searchEntry := widget.NewEntry()
-	searchEntry.SetPlaceHolder("Search...")
-	searchEntry.OnChanged = func(query string) {
-		lc.sharedFiles.Filter(query)
-		lc.sharedList.Refresh()
-	}
+	searchEntry := newSearchEntry(lc.sharedHeader)
 
 	labelCont := container.NewGridWithColumns(2, label, searchEntry)
 
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strconv"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/internal/search"
+	"github.com/0x0FACED/rapid/pkg/thumbnail"
+)
+
+// LANSearch searches files shared by every LAN server at once
+// and downloads found files from the servers that own them
+type LANSearch struct {
+	lan *LANController
+
+	results *FileState
+	// file id -> server that shares it
+	sources map[string]model.ServiceInstance
+	// increases with every search, late results of old ones are dropped
+	generation int
+
+	list     *widget.List
+	header   *FileListHeader
+	status   *widget.Label
+	previews *PreviewCache
+	preview  *PreviewPane
+
+	mu sync.Mutex
+}
+
+func NewLANSearch(lan *LANController) *LANSearch {
+	return &LANSearch{
+		lan:     lan,
+		results: NewFileState(),
+		sources: make(map[string]model.ServiceInstance),
+	}
+}
+
+type serverResult struct {
+	server model.ServiceInstance
+	files  []model.File
+	err    error
+}
+
+// Search asks all known servers for files matching the query,
+// servers that failed are returned as errors
+func (s *LANSearch) Search(query string) (int, []error) {
+	if _, err := search.Parse(query); err != nil {
+		return 0, []error{err}
+	}
+
+	s.mu.Lock()
+	s.generation++
+	generation := s.generation
+	s.mu.Unlock()
+
+	servers := s.lan.serverState.GetAll()
+	ch := make(chan serverResult, len(servers))
+	count := 0
+	for _, server := range servers {
+		if server.InstanceName == s.lan.instName {
+			continue
+		}
+		count++
+		go func(server model.ServiceInstance) {
+			files, err := s.lan.client.Search(server.IPv4, strconv.Itoa(server.Port), query)
+			ch <- serverResult{server: server, files: files, err: err}
+		}(server)
+	}
+
+	results := NewFileState()
+	sources := make(map[string]model.ServiceInstance)
+	var errs []error
+	for range count {
+		res := <-ch
+		if res.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", res.server.InstanceName, res.err))
+			continue
+		}
+		for _, file := range res.files {
+			results.AddFromPeer(res.server.InstanceName, file.ID, file)
+			sources[file.ID] = res.server
+		}
+	}
+
+	found := len(results.Files)
+
+	s.mu.Lock()
+	if generation == s.generation {
+		s.results = results
+		s.sources = sources
+	}
+	s.mu.Unlock()
+
+	if s.header != nil {
+		s.header.Apply()
+	}
+	return found, errs
+}
+
+func (s *LANSearch) state() *FileState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.results
+}
+
+func (s *LANSearch) source(id string) (model.ServiceInstance, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	server, ok := s.sources[id]
+	return server, ok
+}
+
+func (s *LANSearch) download(file model.File) {
+	server, ok := s.source(file.ID)
+	if !ok {
+		return
+	}
+	s.lan.downloadFrom(server, file)
+}
+
+// loadPreview requests preview of found file from its server
+func (s *LANSearch) loadPreview(file model.File) (thumbnail.Thumbnail, error) {
+	server, ok := s.source(file.ID)
+	if !ok {
+		return thumbnail.Thumbnail{}, fmt.Errorf("no server for %s", file.Name)
+	}
+	return s.lan.client.GetThumbnail(server.Address(), file.ID, previewSize)
+}
+
+func (s *LANSearch) CreateSearchContent() fyne.CanvasObject {
+	s.previews = NewPreviewCache(s.loadPreview)
+	s.preview = NewPreviewPane(s.previews)
+	s.list = newFileList(s.state, s.previews, s.preview.Show, func(file model.File) {
+		s.preview.Show(file)
+		s.download(file)
+	})
+	s.previews.SetOnLoad(func(file model.File) {
+		s.list.Refresh()
+		s.preview.Refresh(file)
+	})
+
+	s.header = NewFileListHeader(
+		[]SortKey{SortByName, SortByPeer, SortByType, SortByDate, SortBySize},
+		s.state,
+		s.list.Refresh,
+	)
+
+	s.status = widget.NewLabel("Search files shared by all devices on the network")
+
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("Search: name, *.pdf, /regexp/, ext:jpg, size:>1MB")
+	entry.Validator = func(query string) error {
+		_, err := search.Parse(query)
+		return err
+	}
+
+	run := func() {
+		query := entry.Text
+		if _, err := search.Parse(query); err != nil {
+			s.status.SetText(err.Error())
+			return
+		}
+		s.status.SetText("Searching...")
+		go func() {
+			found, errs := s.Search(query)
+			for _, err := range errs {
+				log.Println("Search failed:", err)
+			}
+
+			status := fmt.Sprintf("Found %d files", found)
+			if len(errs) > 0 {
+				status += fmt.Sprintf(", %d devices didn't answer", len(errs))
+			}
+			s.status.SetText(status)
+		}()
+	}
+	entry.OnSubmitted = func(string) { run() }
+
+	separator := NewCustomSeparator(
+		color.RGBA{R: 200, G: 200, B: 200, A: 255},
+		2,
+		true,
+	)
+
+	top := container.NewVBox(
+		container.NewBorder(nil, nil, nil, widget.NewButton("Search", run), entry),
+		s.status,
+		separator,
+		s.header.Content(),
+	)
+	list := container.NewBorder(top, nil, nil, nil, s.list)
+
+	split := container.NewHSplit(list, s.preview.Content())
+	split.Offset = 0.7
+	return split
+}
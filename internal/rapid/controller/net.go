@@ -2,6 +2,7 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image/color"
@@ -9,20 +10,22 @@ import (
 	"time"
 
 	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
-	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/configs"
 	"github.com/0x0FACED/rapid/internal/lan/server"
 	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/pkg/generator"
+	"github.com/0x0FACED/rapid/pkg/thumbnail"
 	"github.com/caiguanhao/readqr"
-	"github.com/skip2/go-qrcode"
 	"golang.design/x/clipboard"
 )
 
+const defaultSignalingURL = "ws://localhost:8090/ws"
+
 type NetController struct {
-	p2pstate *P2PConnectionState
+	sessions *SessionManager
 
 	window         *fyne.Window
 	instName       string
@@ -30,27 +33,132 @@ type NetController struct {
 	server         *server.LANServer
 	sharedFiles    *FileState
 	connectionInfo *container.Scroll
+	routeLabel     *widget.Label
+	peersList      *widget.List
 	receivedList   *widget.List
+	previews       *PreviewCache
+	preview        *PreviewPane
+	// headers keep sorting and filter of the lists
+	receivedHeader *FileListHeader
+	sharedHeader   *FileListHeader
 	sharedList     *widget.List
-	currentServer  string
+	currentPeer    string
+	onMessage      func(*PeerSession, model.Message)
+	onClipboard    func(*PeerSession, model.ClipboardUpdate)
 }
 
 func NewNetController(s *server.LANServer, instName string) (*NetController, error) {
-	p2pstate, err := NewP2PConnectionState()
-	if err != nil {
-		return nil, err
-	}
-
-	return &NetController{
-		p2pstate:      p2pstate,
+	nc := &NetController{
 		instName:      instName,
 		server:        s,
 		receivedFiles: NewFileState(),
 		sharedFiles:   NewFileState(),
-	}, nil
+		onMessage:     func(*PeerSession, model.Message) {},
+		onClipboard:   func(*PeerSession, model.ClipboardUpdate) {},
+	}
+
+	nc.sessions = NewSessionManager(s.DownloadsDir(), nc.sharedFiles.Unfiltered)
+	nc.sessions.SetBandwidth(s.Bandwidth())
+	nc.sessions.SetCallbacks(SessionCallbacks{
+		OnConnect:    nc.onPeerConnect,
+		OnDisconnect: nc.onPeerDisconnect,
+		OnFiles:      nc.onPeerFiles,
+		OnRoute:      nc.onPeerRoute,
+		OnComplete:   nc.onTransferComplete,
+		OnError:      nc.onTransferError,
+		OnMessage: func(session *PeerSession, msg model.Message) {
+			nc.onMessage(session, msg)
+		},
+		OnClipboard: func(session *PeerSession, update model.ClipboardUpdate) {
+			nc.onClipboard(session, update)
+		},
+	})
+
+	return nc, nil
+}
+
+// Sessions returns WebRTC peer sessions
+func (nc *NetController) Sessions() *SessionManager {
+	return nc.sessions
+}
+
+// SetOnMessage sets callback for chat messages from WebRTC peers
+func (nc *NetController) SetOnMessage(onMessage func(*PeerSession, model.Message)) {
+	nc.onMessage = onMessage
+}
+
+// SetOnClipboard sets callback for clipboard updates from WebRTC peers
+func (nc *NetController) SetOnClipboard(onClipboard func(*PeerSession, model.ClipboardUpdate)) {
+	nc.onClipboard = onClipboard
+}
+
+func (nc *NetController) onPeerConnect(session *PeerSession) {
+	if nc.currentPeer == "" {
+		nc.selectPeer(session.Name)
+	}
+	nc.refreshUI()
+
+	if nc.window != nil {
+		dialog.ShowInformation("Success", "Connected to "+session.Name+"!", *nc.window)
+	}
+}
+
+func (nc *NetController) onPeerDisconnect(session *PeerSession) {
+	nc.refreshUI()
+}
+
+func (nc *NetController) onPeerFiles(session *PeerSession) {
+	if session.Name == nc.currentPeer {
+		nc.updateReceivedFiles(session.Name)
+	}
+}
+
+func (nc *NetController) onPeerRoute(session *PeerSession) {
+	if session.Name == nc.currentPeer {
+		nc.updateRoute()
+	}
+}
+
+func (nc *NetController) onTransferComplete(session *PeerSession, t *Transfer) {
+	if t.Direction != TransferDownload || nc.window == nil {
+		return
+	}
+	dialog.ShowInformation("Success", "Received "+t.File.Name+" from "+session.Name, *nc.window)
+}
+
+func (nc *NetController) onTransferError(session *PeerSession, t *Transfer, err error) {
+	log.Println(session.Name, err)
+	if nc.window != nil {
+		dialog.ShowError(err, *nc.window)
+	}
+}
+
+// selectPeer shows files and route of the peer
+func (nc *NetController) selectPeer(name string) {
+	nc.currentPeer = name
+	nc.updateReceivedFiles(name)
+	if nc.preview != nil {
+		nc.preview.Clear()
+	}
+	nc.updateRoute()
+}
+
+func (nc *NetController) updateRoute() {
+	if nc.routeLabel == nil {
+		return
+	}
+	if nc.currentPeer == "" {
+		nc.routeLabel.SetText("Route: not connected")
+		return
+	}
+	nc.routeLabel.SetText("Route to " + nc.currentPeer + ": " + nc.sessions.Route(nc.currentPeer))
 }
 
 func (nc *NetController) refreshUI() {
+	if nc.peersList != nil {
+		nc.peersList.Refresh()
+	}
+
 	if nc.receivedList != nil {
 		nc.receivedList.Refresh()
 	}
@@ -89,11 +197,31 @@ func (nc *NetController) handleFileSelection(path string) error {
 		return fmt.Errorf("failed to share file: %w", err)
 	}
 
-	nc.sharedFiles.Add(file.ID, file)
-	nc.sharedList.Refresh()
+	nc.addShared(file)
 	return nil
 }
 
+// addShared adds new or updated file to shared list and announces it to peers
+func (nc *NetController) addShared(file model.File) {
+	nc.sharedFiles.Add(file.ID, file)
+	nc.announceShared()
+}
+
+func (nc *NetController) removeShared(id string) {
+	nc.sharedFiles.Remove(id)
+	nc.announceShared()
+}
+
+func (nc *NetController) announceShared() {
+	if nc.sharedList != nil {
+		nc.sharedList.Refresh()
+	}
+
+	if err := nc.sessions.Announce(); err != nil {
+		log.Println("Failed to announce files:", err)
+	}
+}
+
 func (nc *NetController) CreateLANTopPanel(window fyne.Window) fyne.CanvasObject {
 	fileDialogButton := widget.NewButton("Choose File", func() {
 		nc.showFilePicker(window)
@@ -101,14 +229,63 @@ func (nc *NetController) CreateLANTopPanel(window fyne.Window) fyne.CanvasObject
 
 	name := widget.NewLabelWithStyle("Your name: "+nc.instName, fyne.TextAlignTrailing, fyne.TextStyle{Bold: true, Italic: true})
 
-	cont := container.NewBorder(nil, nil, fileDialogButton, name, nil)
+	nc.routeLabel = widget.NewLabel("")
+	nc.updateRoute()
+
+	cont := container.NewBorder(nil, nil, fileDialogButton, name, nc.routeLabel)
 	return cont
 }
 
+// CreateOptionsContent returns ICE settings for the Options tab
+func (nc *NetController) CreateOptionsContent(window fyne.Window) fyne.CanvasObject {
+	cfg := nc.sessions.ICEConfig()
+
+	serversEntry := widget.NewMultiLineEntry()
+	serversEntry.SetPlaceHolder("stun:stun.example.com:3478\nturns:turn.example.com:5349?transport=tcp user secret")
+	serversEntry.SetText(configs.FormatICEServers(cfg.Servers))
+	serversEntry.SetMinRowsVisible(6)
+
+	lanOnlyCheck := widget.NewCheck("LAN only (no STUN/TURN)", nil)
+	lanOnlyCheck.SetChecked(cfg.LANOnly)
+
+	relayOnlyCheck := widget.NewCheck("Relay only (always use TURN)", nil)
+	relayOnlyCheck.SetChecked(cfg.RelayOnly)
+
+	applyBtn := widget.NewButton("Apply", func() {
+		servers, err := configs.ParseICEServers(serversEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		err = nc.sessions.SetICEConfig(configs.ICEConfig{
+			Servers:   servers,
+			LANOnly:   lanOnlyCheck.Checked,
+			RelayOnly: relayOnlyCheck.Checked,
+		})
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		dialog.ShowInformation("Success", "ICE settings will be used for the next connection", window)
+	})
+
+	return container.NewVBox(
+		widget.NewLabel("ICE servers, one per line: url [username credential]"),
+		serversEntry,
+		lanOnlyCheck,
+		relayOnlyCheck,
+		applyBtn,
+	)
+}
+
 func (nc *NetController) CreateNetContent(w fyne.Window) fyne.CanvasObject {
 	clipboard.Init()
+	nc.window = &w
 
 	nc.initConnectionInfo(w)
+	nc.initPeersList()
 	nc.initReceivedFilesList()
 	nc.initSharedFilesList()
 
@@ -132,13 +309,34 @@ func (nc *NetController) CreateNetContent(w fyne.Window) fyne.CanvasObject {
 }
 
 func (nc *NetController) initCreateConnectionTab(window fyne.Window) fyne.CanvasObject {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Enter peer name...")
+
 	passEntry := widget.NewEntry()
 	passEntry.SetPlaceHolder("Enter password...")
 
-	var qrImage *canvas.Image
-	qrImage = canvas.NewImageFromResource(nil)
-	qrImage.FillMode = canvas.ImageFillContain
-	qrImage.SetMinSize(fyne.NewSquareSize(100))
+	qrView := NewQRView()
+	var offer string
+
+	receiveAnswer := payloadReceiver(window, func(answer string) error {
+		session, ok := nc.sessions.Get(nameEntry.Text)
+		if !ok {
+			return errors.New("Create offer for this peer first")
+		}
+
+		if err := session.State.AcceptEncodedAnswer(answer); err != nil {
+			return err
+		}
+
+		go func() {
+			if err := session.State.WaitForConnection(30 * time.Second); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}()
+
+		dialog.ShowInformation("Info", "Answer accepted, connecting...", window)
+		return nil
+	})
 
 	genQRBtn := widget.NewButton("Create offer QR Code", func() {
 		if passEntry.Text == "" {
@@ -146,40 +344,33 @@ func (nc *NetController) initCreateConnectionTab(window fyne.Window) fyne.Canvas
 			return
 		}
 
-		nc.p2pstate.SetPassword(passEntry.Text)
-
-		offer, err := nc.p2pstate.CreateEncodedOffer(nil)
+		session, err := nc.sessions.GetOrCreate(nameEntry.Text)
 		if err != nil {
 			dialog.ShowError(err, window)
 			return
 		}
+		session.State.SetPassword(passEntry.Text)
+		nc.refreshUI()
 
-		qr, err := qrcode.New(offer, qrcode.Medium)
+		offer, err = session.State.CreateEncodedOffer(nil)
 		if err != nil {
 			dialog.ShowError(err, window)
 			return
 		}
 
-		var buf bytes.Buffer
-		err = qr.Write(400, &buf)
-		if err != nil {
+		if err := qrView.Show(offer); err != nil {
 			dialog.ShowError(err, window)
-			return
 		}
-
-		fmt.Println("PASS: ", passEntry.Text)
-
-		qrImage.Resource = fyne.NewStaticResource("qr.png", buf.Bytes())
-		qrImage.Refresh()
 	})
 
 	copyQRBtn := widget.NewButton("Copy QR Code", func() {
-		if qrImage.Resource == nil {
+		frame := qrView.Current()
+		if frame == nil {
 			dialog.ShowInformation("Error", "Generate QR code first", window)
 			return
 		}
 
-		_ = clipboard.Write(clipboard.FmtImage, qrImage.Resource.Content())
+		_ = clipboard.Write(clipboard.FmtImage, frame)
 		dialog.ShowInformation("Success", "QR code copied to clipboard", window)
 	})
 
@@ -195,53 +386,53 @@ func (nc *NetController) initCreateConnectionTab(window fyne.Window) fyne.Canvas
 			dialog.ShowError(err, window)
 			return
 		}
-
-		decodedAnswer, err := nc.p2pstate.DecodeAnswer(result)
-		if err != nil {
-			dialog.ShowError(err, window)
-			return
-		}
-
-		if err := nc.p2pstate.ValidatePassword(decodedAnswer.Hash); err != nil {
-			dialog.ShowError(fmt.Errorf("invalid password"), window)
-			return
-		}
-
-		if err := nc.p2pstate.conn.SetRemoteDescription(decodedAnswer.SDP); err != nil {
-			dialog.ShowError(err, window)
-			return
-		}
-
-		go func() {
-			if err := nc.p2pstate.WaitForConnection(30 * time.Second); err != nil {
-				dialog.ShowError(err, window)
-			}
-		}()
-
-		nc.p2pstate.onConnect = func() {
-			dialog.ShowInformation("Success", "Connected!", window)
-		}
-
-		dialog.ShowInformation("Info", "Answer accepted, connecting...", window)
+		receiveAnswer(result)
 	})
 
+	carriers := createCarrierButtons(
+		window,
+		carrier{kind: "offer", ext: OfferFileExt},
+		func() string { return offer },
+		carrier{kind: "answer", ext: AnswerFileExt},
+		receiveAnswer,
+	)
+
 	return container.NewVBox(
+		nameEntry,
 		passEntry,
 		genQRBtn,
-		qrImage,
+		qrView.Image,
 		copyQRBtn,
 		pasteQRBtn,
+		carriers,
 	)
 }
 
 func (nc *NetController) initConnectTab(window fyne.Window) fyne.CanvasObject {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Enter peer name...")
+
 	passEntry := widget.NewEntry()
 	passEntry.SetPlaceHolder("Enter password for connect...")
 
-	var qrImage *canvas.Image
-	qrImage = canvas.NewImageFromResource(nil)
-	qrImage.FillMode = canvas.ImageFillContain
-	qrImage.SetMinSize(fyne.NewSquareSize(100))
+	qrView := NewQRView()
+	var answer string
+
+	receiveOffer := payloadReceiver(window, func(offer string) error {
+		session, err := nc.sessions.GetOrCreate(nameEntry.Text)
+		if err != nil {
+			return err
+		}
+		session.State.SetPassword(passEntry.Text)
+		nc.refreshUI()
+
+		if err := session.State.AcceptEncodedOffer(offer); err != nil {
+			return err
+		}
+
+		dialog.ShowInformation("Success", "Offer loaded", window)
+		return nil
+	})
 
 	genQRBtn := widget.NewButton("Create answer QR Code", func() {
 		if passEntry.Text == "" {
@@ -249,52 +440,44 @@ func (nc *NetController) initConnectTab(window fyne.Window) fyne.CanvasObject {
 			return
 		}
 
-		nc.p2pstate.SetPassword(passEntry.Text)
-
-		fmt.Println("PASS SET: ", passEntry.Text)
-
-		answer, err := nc.p2pstate.CreateEncodedAnswer(nil)
-		if err != nil {
-			dialog.ShowError(err, window)
+		session, ok := nc.sessions.Get(nameEntry.Text)
+		if !ok {
+			dialog.ShowError(errors.New("Load offer from this peer first"), window)
 			return
 		}
+		session.State.SetPassword(passEntry.Text)
 
-		qr, err := qrcode.New(answer, qrcode.Medium)
+		var err error
+		answer, err = session.State.CreateEncodedAnswer(nil)
 		if err != nil {
 			dialog.ShowError(err, window)
 			return
 		}
 
-		var buf bytes.Buffer
-		err = qr.Write(400, &buf)
-		if err != nil {
+		if err := qrView.Show(answer); err != nil {
 			dialog.ShowError(err, window)
 			return
 		}
 
 		go func() {
-			if err := nc.p2pstate.WaitForConnection(30 * time.Second); err != nil {
+			if err := session.State.WaitForConnection(30 * time.Second); err != nil {
 				dialog.ShowError(err, window)
 			}
 		}()
-
-		qrImage.Resource = fyne.NewStaticResource("qr.png", buf.Bytes())
-		qrImage.Refresh()
 	})
 
 	copyQRBtn := widget.NewButton("Copy QR Code", func() {
-		if qrImage.Resource == nil {
+		frame := qrView.Current()
+		if frame == nil {
 			dialog.ShowInformation("Error", "Generate QR code first", window)
 			return
 		}
 
-		_ = clipboard.Write(clipboard.FmtImage, qrImage.Resource.Content())
+		_ = clipboard.Write(clipboard.FmtImage, frame)
 		dialog.ShowInformation("Success", "QR code copied to clipboard", window)
 	})
 
 	pasteQRBtn := widget.NewButton("Paste offer QR Code", func() {
-		nc.p2pstate.SetPassword(passEntry.Text)
-
 		imgBytes := clipboard.Read(clipboard.FmtImage)
 		// not image in clipboard
 		if imgBytes == nil {
@@ -308,39 +491,89 @@ func (nc *NetController) initConnectTab(window fyne.Window) fyne.CanvasObject {
 			dialog.ShowError(err, window)
 			return
 		}
-		// debug output
-		fmt.Println(result)
+		receiveOffer(result)
+	})
+
+	carriers := createCarrierButtons(
+		window,
+		carrier{kind: "answer", ext: AnswerFileExt},
+		func() string { return answer },
+		carrier{kind: "offer", ext: OfferFileExt},
+		receiveOffer,
+	)
 
-		decodedOffer, err := nc.p2pstate.DecodeOffer(result)
+	return container.NewVBox(
+		nameEntry,
+		passEntry,
+		pasteQRBtn,
+		genQRBtn,
+		qrView.Image,
+		copyQRBtn,
+		carriers,
+	)
+}
+
+func (nc *NetController) initConnectByCodeTab(window fyne.Window) fyne.CanvasObject {
+	serverEntry := widget.NewEntry()
+	serverEntry.SetText(defaultSignalingURL)
+
+	codeEntry := widget.NewEntry()
+	codeEntry.SetPlaceHolder("Enter room code...")
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Enter peer name (room code by default)...")
+
+	passEntry := widget.NewEntry()
+	passEntry.SetPlaceHolder("Enter password...")
+
+	genCodeBtn := widget.NewButton("Generate code", func() {
+		code, err := generator.GenerateCode()
 		if err != nil {
 			dialog.ShowError(err, window)
 			return
 		}
+		codeEntry.SetText(code)
+	})
 
-		if err := nc.p2pstate.ValidatePassword(decodedOffer.Hash); err != nil {
-			dialog.ShowError(fmt.Errorf("invalid password"), window)
+	var connectBtn *widget.Button
+	connectBtn = widget.NewButton("Connect", func() {
+		if codeEntry.Text == "" || passEntry.Text == "" {
+			dialog.ShowError(errors.New("Provide room code and password"), window)
 			return
 		}
 
-		if err := nc.p2pstate.conn.SetRemoteDescription(decodedOffer.SDP); err != nil {
-			dialog.ShowError(err, window)
-			return
+		name := nameEntry.Text
+		if name == "" {
+			name = codeEntry.Text
 		}
 
-		if err := nc.p2pstate.AddRemoteICECandidates(); err != nil {
+		session, err := nc.sessions.GetOrCreate(name)
+		if err != nil {
 			dialog.ShowError(err, window)
 			return
 		}
+		session.State.SetPassword(passEntry.Text)
+		nc.refreshUI()
 
-		dialog.ShowInformation("Success", "QR code loaded", window)
+		connectBtn.Disable()
+		go func() {
+			defer connectBtn.Enable()
+
+			ctx, cancel := context.WithTimeout(context.Background(), defaultConnectTimeout)
+			defer cancel()
+
+			if err := session.State.ConnectByCode(ctx, serverEntry.Text, codeEntry.Text); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}()
 	})
 
 	return container.NewVBox(
+		serverEntry,
+		container.NewBorder(nil, nil, nil, genCodeBtn, codeEntry),
+		nameEntry,
 		passEntry,
-		pasteQRBtn,
-		genQRBtn,
-		qrImage,
-		copyQRBtn,
+		connectBtn,
 	)
 }
 
@@ -348,6 +581,7 @@ func (nc *NetController) initConnectionInfo(window fyne.Window) {
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Host", nc.initCreateConnectionTab(window)),
 		container.NewTabItem("Client", nc.initConnectTab(window)),
+		container.NewTabItem("Code", nc.initConnectByCodeTab(window)),
 	)
 
 	input := widget.NewEntry()
@@ -357,13 +591,9 @@ func (nc *NetController) initConnectionInfo(window fyne.Window) {
 
 }
 
-func (lc *NetController) updateReceivedFiles(server model.ServiceInstance) {
-	// TODO: impl
-}
-
-func (nc *NetController) initReceivedFilesList() {
-	nc.receivedList = widget.NewList(
-		func() int { return len(nc.receivedFiles.GetAll()) },
+func (nc *NetController) initPeersList() {
+	nc.peersList = widget.NewList(
+		func() int { return len(nc.sessions.GetAll()) },
 		func() fyne.CanvasObject {
 			return container.NewBorder(
 				nil,
@@ -374,60 +604,111 @@ func (nc *NetController) initReceivedFilesList() {
 			)
 		},
 		func(i widget.ListItemID, o fyne.CanvasObject) {
-			files := nc.receivedFiles.GetAll()
-			if i >= len(files) {
+			sessions := nc.sessions.GetAll()
+			if i >= len(sessions) {
 				return
 			}
-			file := files[i]
+			session := sessions[i]
 			container := o.(*fyne.Container)
 			labels := container.Objects
-			labels[0].(*widget.Label).SetText(file.Name)
-			labels[1].(*widget.Label).SetText(file.SizeString())
+			labels[0].(*widget.Label).SetText(session.Name)
+			labels[1].(*widget.Label).SetText(session.Status())
 		},
 	)
 
-	nc.receivedList.OnSelected = func(id widget.ListItemID) {
-		files := nc.receivedFiles.GetAll()
-		if id >= len(files) {
+	nc.peersList.OnSelected = func(id widget.ListItemID) {
+		sessions := nc.sessions.GetAll()
+		if id >= len(sessions) {
 			return
 		}
-		file := files[id]
-		nc.downloadFile(file)
-		nc.receivedList.Unselect(id)
+		nc.selectPeer(sessions[id].Name)
+		nc.peersList.Unselect(id)
+	}
+	nc.peersList.HideSeparators = true
+}
+
+func (nc *NetController) updateReceivedFiles(name string) {
+	files := nc.sessions.Files(name)
+	if files == nil {
+		files = NewFileState()
 	}
 
-	nc.receivedList.HideSeparators = true
+	nc.receivedFiles = files
+	if nc.receivedHeader != nil {
+		nc.receivedHeader.Apply()
+	}
 }
 
-func (lc *NetController) downloadFile(file model.File) {
-	// TODO: impl
+func (nc *NetController) disconnectPeer() {
+	if nc.currentPeer == "" {
+		return
+	}
+
+	if err := nc.sessions.Remove(nc.currentPeer); err != nil {
+		log.Println("Failed to close session:", err)
+	}
+	nc.selectPeer("")
+	nc.refreshUI()
 }
 
-func (nc *NetController) initSharedFilesList() {
-	nc.sharedList = widget.NewList(
-		func() int { return len(nc.sharedFiles.GetAll()) },
-		func() fyne.CanvasObject {
-			return container.NewBorder(
-				nil,
-				nil,
-				widget.NewLabel(""),
-				widget.NewLabel(""),
-				nil,
-			)
-		},
-		func(i widget.ListItemID, o fyne.CanvasObject) {
-			files := nc.sharedFiles.GetAll()
-			if i >= len(files) {
-				return
-			}
-			file := files[i]
-			container := o.(*fyne.Container)
-			labels := container.Objects
-			labels[0].(*widget.Label).SetText(file.Name)
-			labels[1].(*widget.Label).SetText(file.SizeString())
+func (nc *NetController) initReceivedFilesList() {
+	nc.previews = NewPreviewCache(nc.loadPreview)
+	nc.preview = NewPreviewPane(nc.previews)
+	nc.previews.SetOnLoad(func(file model.File) {
+		nc.receivedList.Refresh()
+		nc.preview.Refresh(file)
+	})
+
+	nc.receivedList = newFileList(
+		func() *FileState { return nc.receivedFiles },
+		nc.previews,
+		nc.preview.Show,
+		func(file model.File) {
+			nc.preview.Show(file)
+			nc.downloadFile(file)
 		},
 	)
-	nc.sharedList.HideSeparators = true
+}
+
+// previewTimeout limits waiting for the peer to make preview
+const previewTimeout = 15 * time.Second
+
+// loadPreview requests preview of the file from current peer
+func (nc *NetController) loadPreview(file model.File) (thumbnail.Thumbnail, error) {
+	session, ok := nc.sessions.Get(nc.currentPeer)
+	if !ok {
+		return thumbnail.Thumbnail{}, ErrSessionNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), previewTimeout)
+	defer cancel()
+	return session.State.Thumbnails().Get(ctx, file.ID, previewSize)
+}
+
+func (nc *NetController) downloadFile(file model.File) {
+	session, ok := nc.sessions.Get(nc.currentPeer)
+	if !ok {
+		if nc.window != nil {
+			dialog.ShowError(ErrSessionNotFound, *nc.window)
+		}
+		return
+	}
+
+	if _, err := session.State.Transfers().Request(file); err != nil {
+		log.Printf("Error requesting file %s: %v", file.Name, err)
+		if nc.window != nil {
+			dialog.ShowError(err, *nc.window)
+		}
+	}
+}
+
+func (nc *NetController) initSharedFilesList() {
+	nc.sharedList = newFileList(
+		func() *FileState { return nc.sharedFiles },
+		nil,
+		nil,
+		nil,
+	)
 }
 
 func (nc *NetController) createServerListSection() fyne.CanvasObject {
@@ -435,17 +716,27 @@ func (nc *NetController) createServerListSection() fyne.CanvasObject {
 		widget.NewLabelWithStyle("P2P Connection", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 	)
 
-	return container.NewBorder(header, nil, nil, nil, nc.connectionInfo)
+	peersHeader := container.NewBorder(
+		nil,
+		nil,
+		widget.NewLabelWithStyle("Peers", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewButton("Disconnect", nc.disconnectPeer),
+	)
+	peers := container.NewBorder(peersHeader, nil, nil, nil, nc.peersList)
+
+	return container.NewVSplit(
+		container.NewBorder(header, nil, nil, nil, nc.connectionInfo),
+		peers,
+	)
 }
 
 func (nc *NetController) createReceivedFilesSection() fyne.CanvasObject {
-	spacer := layout.NewSpacer()
-
-	header := container.NewGridWithColumns(3,
-		widget.NewLabelWithStyle("Filename", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		spacer,
-		widget.NewLabelWithStyle("Size", fyne.TextAlignTrailing, fyne.TextStyle{Bold: true}),
+	nc.receivedHeader = NewFileListHeader(
+		[]SortKey{SortByName, SortByType, SortByDate, SortBySize},
+		func() *FileState { return nc.receivedFiles },
+		nc.receivedList.Refresh,
 	)
+	header := nc.receivedHeader.Content()
 	label := widget.NewLabelWithStyle("Received Files", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
 
 	separator := NewCustomSeparator(
@@ -454,27 +745,25 @@ func (nc *NetController) createReceivedFilesSection() fyne.CanvasObject {
 		true,
 	)
 
-	searchEntry := widget.NewEntry()
-	searchEntry.SetPlaceHolder("Search...")
-	searchEntry.OnChanged = func(query string) {
-		nc.receivedFiles.Filter(query)
-		nc.receivedList.Refresh()
-	}
+	searchEntry := newSearchEntry(nc.receivedHeader)
 
 	labelCont := container.NewGridWithColumns(2, label, searchEntry)
 
 	cont := container.NewBorder(labelCont, header, nil, nil, separator)
-	return container.NewBorder(cont, nil, nil, nil, nc.receivedList)
+	list := container.NewBorder(cont, nil, nil, nil, nc.receivedList)
+
+	split := container.NewHSplit(list, nc.preview.Content())
+	split.Offset = 0.7
+	return split
 }
 
 func (nc *NetController) createSharedFilesSection() fyne.CanvasObject {
-	spacer := layout.NewSpacer()
-
-	header := container.NewGridWithColumns(3,
-		widget.NewLabelWithStyle("Filename", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		spacer,
-		widget.NewLabelWithStyle("Size", fyne.TextAlignTrailing, fyne.TextStyle{Bold: true}),
+	nc.sharedHeader = NewFileListHeader(
+		[]SortKey{SortByName, SortByType, SortByDate, SortBySize},
+		func() *FileState { return nc.sharedFiles },
+		nc.sharedList.Refresh,
 	)
+	header := nc.sharedHeader.Content()
 	label := widget.NewLabelWithStyle("Your Shared files", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
 
 	separator := NewCustomSeparator(
@@ -483,12 +772,7 @@ func (nc *NetController) createSharedFilesSection() fyne.CanvasObject {
 		true,
 	)
 
-	searchEntry := widget.NewEntry()
-	searchEntry.SetPlaceHolder("Search...")
-	searchEntry.OnChanged = func(query string) {
-		nc.sharedFiles.Filter(query)
-		nc.sharedList.Refresh()
-	}
+	searchEntry := newSearchEntry(nc.sharedHeader)
 
 	labelCont := container.NewGridWithColumns(2, label, searchEntry)
 
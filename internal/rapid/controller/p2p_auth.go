@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/0x0FACED/rapid/pkg/spake2"
+	"github.com/pion/webrtc/v4"
+)
+
+// Control message types of the password handshake
+const (
+	msgPake        = "pake"
+	msgPakeConfirm = "pake_confirm"
+)
+
+const pakeTimeout = 15 * time.Second
+
+var (
+	ErrAuthFailed    = errors.New("peer authentication failed")
+	ErrNoFingerprint = errors.New("no DTLS fingerprint in SDP")
+)
+
+type pakeMessage struct {
+	Data []byte `json:"data"`
+}
+
+// startAuth begins SPAKE2 over the control channel. Identities of both sides
+// are their DTLS certificate fingerprints, so a successful handshake proves
+// that the peer who knows the password is the one on the other end of DTLS.
+func (c *P2PConnectionState) startAuth() error {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.pake != nil {
+		return nil
+	}
+
+	conn := c.Conn()
+	if conn == nil || conn.LocalDescription() == nil || conn.RemoteDescription() == nil {
+		return errors.New("session descriptions are not set")
+	}
+
+	local, err := sdpFingerprint(conn.LocalDescription().SDP)
+	if err != nil {
+		return err
+	}
+	remote, err := sdpFingerprint(conn.RemoteDescription().SDP)
+	if err != nil {
+		return err
+	}
+
+	// offerer is always side A
+	role, idA, idB := spake2.RoleA, local, remote
+	if conn.LocalDescription().Type != webrtc.SDPTypeOffer {
+		role, idA, idB = spake2.RoleB, remote, local
+	}
+
+	state, err := spake2.New(role, []byte(c.Password()), []byte(idA), []byte(idB))
+	if err != nil {
+		return err
+	}
+	c.pake = state
+
+	time.AfterFunc(pakeTimeout, func() {
+		// reset or new offer may have replaced the connection meanwhile
+		c.authMu.Lock()
+		current := c.pake == state
+		c.authMu.Unlock()
+		if current && c.Conn() == conn && !c.authenticated.Load() {
+			c.failAuth(errors.New("handshake timeout"))
+		}
+	})
+
+	return c.sendControl(msgPake, pakeMessage{Data: state.Message()})
+}
+
+func (c *P2PConnectionState) handleAuth(msg controlMessage) {
+	var payload pakeMessage
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		c.failAuth(err)
+		return
+	}
+
+	// peer may be faster than our OnOpen
+	if err := c.startAuth(); err != nil {
+		c.failAuth(err)
+		return
+	}
+
+	c.authMu.Lock()
+	state := c.pake
+	c.authMu.Unlock()
+
+	switch msg.Type {
+	case msgPake:
+		confirm, err := state.Finish(payload.Data)
+		if err != nil {
+			c.failAuth(err)
+			return
+		}
+		if err := c.sendControl(msgPakeConfirm, pakeMessage{Data: confirm}); err != nil {
+			c.failAuth(err)
+		}
+	case msgPakeConfirm:
+		if err := state.Verify(payload.Data); err != nil {
+			c.failAuth(err)
+			return
+		}
+
+		c.authenticated.Store(true)
+		c.onConnect()
+
+		if err := c.transfers.Announce(); err != nil {
+			log.Println("Failed to announce files:", err)
+		}
+	}
+}
+
+// failAuth closes the connection, nothing is exchanged with unauthenticated peer
+func (c *P2PConnectionState) failAuth(err error) {
+	c.authMu.Lock()
+	if c.authErr != nil {
+		c.authMu.Unlock()
+		return
+	}
+	c.authErr = fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	c.authMu.Unlock()
+
+	log.Println(c.authErr)
+	go func() {
+		if err := c.Close(); err != nil {
+			log.Println("Failed to close connection:", err)
+		}
+	}()
+}
+
+func (c *P2PConnectionState) AuthError() error {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	return c.authErr
+}
+
+func (c *P2PConnectionState) resetAuth() {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	c.pake = nil
+	c.authErr = nil
+	c.authenticated.Store(false)
+}
+
+// sdpFingerprint returns value of the a=fingerprint attribute
+func sdpFingerprint(sdp string) (string, error) {
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimSpace(line)
+		if value, ok := strings.CutPrefix(line, "a=fingerprint:"); ok {
+			return strings.ToLower(value), nil
+		}
+	}
+	return "", ErrNoFingerprint
+}
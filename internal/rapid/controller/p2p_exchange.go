@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x0FACED/rapid/internal/p2p/wire"
+	"github.com/pion/webrtc/v4"
+)
+
+// exchangeTTL is lifetime of offer and answer codes
+const exchangeTTL = 10 * time.Minute
+
+type DecodedOffer struct {
+	SDP       webrtc.SessionDescription
+	SessionID []byte
+}
+
+type DecodedAnswer struct {
+	SDP       webrtc.SessionDescription
+	SessionID []byte
+}
+
+// CreateEncodedOffer returns offer in compact text form for one-shot exchange,
+// the answer must carry the same session id
+func (c *P2PConnectionState) CreateEncodedOffer(opts *webrtc.OfferOptions) (string, error) {
+	if _, err := c.newOffer(opts); err != nil {
+		return "", err
+	}
+
+	id, err := wire.NewSessionID()
+	if err != nil {
+		return "", err
+	}
+	c.setExchangeID(id)
+
+	// no trickle for one-shot exchange, candidates must be in SDP
+	offer := c.waitForGathering()
+	return wire.Seal(*offer, id, exchangeTTL)
+}
+
+// CreateEncodedAnswer returns answer in compact text form for one-shot exchange
+func (c *P2PConnectionState) CreateEncodedAnswer(opts *webrtc.AnswerOptions) (string, error) {
+	if _, err := c.newAnswer(opts); err != nil {
+		return "", err
+	}
+
+	// no trickle for one-shot exchange, candidates must be in SDP
+	answer := c.waitForGathering()
+	return wire.Seal(*answer, c.ExchangeID(), exchangeTTL)
+}
+
+func (c *P2PConnectionState) DecodeOffer(offer string) (*DecodedOffer, error) {
+	sdp, env, err := decodeDescription(offer, webrtc.SDPTypeOffer)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedOffer{SDP: sdp, SessionID: env.SessionID}, nil
+}
+
+func (c *P2PConnectionState) DecodeAnswer(answer string) (*DecodedAnswer, error) {
+	sdp, env, err := decodeDescription(answer, webrtc.SDPTypeAnswer)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedAnswer{SDP: sdp, SessionID: env.SessionID}, nil
+}
+
+// AcceptEncodedOffer validates offer received by any carrier and applies it
+func (c *P2PConnectionState) AcceptEncodedOffer(offer string) error {
+	decoded, err := c.DecodeOffer(offer)
+	if err != nil {
+		return err
+	}
+
+	exchanges := c.exchangeLog()
+	if !exchanges.claim(decoded.SessionID) {
+		return wire.ErrReplayed
+	}
+	if err := c.AcceptOffer(decoded.SDP); err != nil {
+		exchanges.release(decoded.SessionID)
+		return err
+	}
+
+	c.setExchangeID(decoded.SessionID)
+	return nil
+}
+
+// AcceptEncodedAnswer validates answer received by any carrier and applies it
+func (c *P2PConnectionState) AcceptEncodedAnswer(answer string) error {
+	decoded, err := c.DecodeAnswer(answer)
+	if err != nil {
+		return err
+	}
+
+	conn := c.Conn()
+	if conn == nil || conn.LocalDescription() == nil || conn.LocalDescription().Type != webrtc.SDPTypeOffer {
+		return errors.New("create offer before accepting answer")
+	}
+
+	// answer to our encoded offer must carry its session id,
+	// session id is optional only for offers made without it
+	if id := c.ExchangeID(); len(id) > 0 && !bytes.Equal(id, decoded.SessionID) {
+		return wire.ErrSessionMismatch
+	}
+
+	exchanges := c.exchangeLog()
+	if !exchanges.claim(decoded.SessionID) {
+		return wire.ErrReplayed
+	}
+	if err := c.SetRemoteDescription(decoded.SDP); err != nil {
+		exchanges.release(decoded.SessionID)
+		return err
+	}
+	return nil
+}
+
+// ExchangeID returns session id of the current one-shot exchange
+func (c *P2PConnectionState) ExchangeID() []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.exchangeID
+}
+
+func (c *P2PConnectionState) setExchangeID(id []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exchangeID = id
+}
+
+func (c *P2PConnectionState) exchangeLog() *ExchangeLog {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.exchanges
+}
+
+// SetExchangeLog shares used session ids with other connections,
+// so a code accepted by one of them is rejected by the rest
+func (c *P2PConnectionState) SetExchangeLog(exchanges *ExchangeLog) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exchanges = exchanges
+}
+
+// ExchangeLog remembers session ids of accepted codes until they expire
+type ExchangeLog struct {
+	used map[string]time.Time
+	mu   sync.Mutex
+}
+
+func NewExchangeLog() *ExchangeLog {
+	return &ExchangeLog{used: make(map[string]time.Time)}
+}
+
+// claim marks session id as used, false means it's already used.
+// Codes without session id can't be tracked and are always allowed.
+func (l *ExchangeLog) claim(id []byte) bool {
+	if len(id) == 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for key, expires := range l.used {
+		if now.After(expires) {
+			delete(l.used, key)
+		}
+	}
+	if _, ok := l.used[string(id)]; ok {
+		return false
+	}
+	l.used[string(id)] = now.Add(2 * exchangeTTL)
+	return true
+}
+
+// release forgets session id of code that failed to apply
+func (l *ExchangeLog) release(id []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.used, string(id))
+}
+
+// decodeDescription is the only path for encoded descriptions,
+// text, files and QR codes are decoded to the same payload
+func decodeDescription(payload string, expected webrtc.SDPType) (webrtc.SessionDescription, *wire.Envelope, error) {
+	payload = strings.TrimSpace(payload)
+	if payload == "" {
+		return webrtc.SessionDescription{}, nil, fmt.Errorf("empty %s", expected)
+	}
+	return wire.Open(payload, expected, time.Now())
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/internal/p2p/wire"
+)
+
+func TestOfferIsNotAcceptedTwice(t *testing.T) {
+	lanOnly := configs.ICEConfig{LANOnly: true}
+
+	remote, err := NewP2PConnectionState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { remote.Close() })
+	if err := remote.SetICEConfig(lanOnly); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := remote.CreateEncodedOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewSessionManager(t.TempDir(), func() []model.File { return nil })
+	t.Cleanup(m.CloseAll)
+	if err := m.SetICEConfig(lanOnly); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := m.GetOrCreate("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.State.AcceptEncodedOffer(offer); err != nil {
+		t.Fatalf("first accept: %v", err)
+	}
+	if err := first.State.AcceptEncodedOffer(offer); !errors.Is(err, wire.ErrReplayed) {
+		t.Errorf("same session: error = %v, want ErrReplayed", err)
+	}
+
+	// new session has fresh connection state, the code is still used
+	second, err := m.GetOrCreate("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := second.State.AcceptEncodedOffer(offer); !errors.Is(err, wire.ErrReplayed) {
+		t.Errorf("another session: error = %v, want ErrReplayed", err)
+	}
+}
+
+func TestExchangeLogRelease(t *testing.T) {
+	log := NewExchangeLog()
+	id := []byte("session")
+
+	if !log.claim(id) {
+		t.Fatal("new id is not claimed")
+	}
+	if log.claim(id) {
+		t.Fatal("used id is claimed again")
+	}
+
+	// code that failed to apply may be used again
+	log.release(id)
+	if !log.claim(id) {
+		t.Fatal("released id is not claimed")
+	}
+
+	if !log.claim(nil) || !log.claim(nil) {
+		t.Fatal("codes without session id must be allowed")
+	}
+}
+
+func TestAnswerMustCarrySessionID(t *testing.T) {
+	lanOnly := configs.ICEConfig{LANOnly: true}
+
+	newState := func() *P2PConnectionState {
+		c, err := NewP2PConnectionState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		if err := c.SetICEConfig(lanOnly); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	offerer, answerer := newState(), newState()
+	offer, err := offerer.CreateEncodedOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := answerer.AcceptEncodedOffer(offer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := answerer.newAnswer(nil); err != nil {
+		t.Fatal(err)
+	}
+	answer := answerer.waitForGathering()
+
+	other, err := wire.NewSessionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, id := range map[string][]byte{"without session id": nil, "another session": other} {
+		code, err := wire.Seal(*answer, id, exchangeTTL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := offerer.AcceptEncodedAnswer(code); !errors.Is(err, wire.ErrSessionMismatch) {
+			t.Errorf("%s: error = %v, want ErrSessionMismatch", name, err)
+		}
+	}
+
+	code, err := wire.Seal(*answer, answerer.ExchangeID(), exchangeTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := offerer.AcceptEncodedAnswer(code); err != nil {
+		t.Fatalf("answer of the same session: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/pion/stun/v3"
+	"github.com/pion/webrtc/v4"
+)
+
+// TrickleFunc sends local candidate to the peer, nil means end of candidates
+type TrickleFunc func(candidate *webrtc.ICECandidateInit) error
+
+func (c *P2PConnectionState) handleLocalCandidate(candidate *webrtc.ICECandidate) {
+	c.trickleMu.Lock()
+	defer c.trickleMu.Unlock()
+
+	var init *webrtc.ICECandidateInit
+	c.mu.Lock()
+	if candidate == nil {
+		c.gatheringDone = true
+	} else {
+		json := candidate.ToJSON()
+		init = &json
+		c.iceCandidates = append(c.iceCandidates, json)
+	}
+	trickle := c.trickle
+	c.mu.Unlock()
+
+	if trickle == nil {
+		return
+	}
+	if err := trickle(init); err != nil {
+		log.Println("Failed to trickle candidate:", err)
+	}
+}
+
+// StartTrickle sends already gathered candidates and all next ones through fn.
+// It must be called after local description was delivered to the peer.
+func (c *P2PConnectionState) StartTrickle(fn TrickleFunc) error {
+	c.trickleMu.Lock()
+	defer c.trickleMu.Unlock()
+
+	c.mu.Lock()
+	c.trickle = fn
+	gathered := slices.Clone(c.iceCandidates)
+	done := c.gatheringDone
+	c.mu.Unlock()
+
+	for i := range gathered {
+		if err := fn(&gathered[i]); err != nil {
+			return err
+		}
+	}
+	if done {
+		return fn(nil)
+	}
+	return nil
+}
+
+func (c *P2PConnectionState) StopTrickle() {
+	c.trickleMu.Lock()
+	defer c.trickleMu.Unlock()
+
+	c.mu.Lock()
+	c.trickle = nil
+	c.mu.Unlock()
+}
+
+// AddRemoteICECandidate adds candidate received from the peer, empty candidate
+// means end of candidates. Candidates that come before remote description
+// are queued until SetRemoteDescription.
+func (c *P2PConnectionState) AddRemoteICECandidate(candidate webrtc.ICECandidateInit) error {
+	c.mu.Lock()
+	conn := c.conn
+	if conn == nil || conn.RemoteDescription() == nil {
+		c.pendingCandidates = append(c.pendingCandidates, candidate)
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	return conn.AddICECandidate(candidate)
+}
+
+// SetRemoteDescription applies peer description and queued remote candidates
+func (c *P2PConnectionState) SetRemoteDescription(sd webrtc.SessionDescription) error {
+	conn := c.Conn()
+	if err := conn.SetRemoteDescription(sd); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	pending := c.pendingCandidates
+	c.pendingCandidates = nil
+	c.mu.Unlock()
+
+	for _, candidate := range pending {
+		if err := conn.AddICECandidate(candidate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AcceptOffer resets connection and applies offer from the peer
+func (c *P2PConnectionState) AcceptOffer(offer webrtc.SessionDescription) error {
+	if err := c.reset(); err != nil {
+		return err
+	}
+	return c.SetRemoteDescription(offer)
+}
+
+// waitForGathering blocks until all local candidates are in local description,
+// used for one-shot exchange where candidates can't be trickled
+func (c *P2PConnectionState) waitForGathering() *webrtc.SessionDescription {
+	conn := c.Conn()
+	<-webrtc.GatheringCompletePromise(conn)
+	return conn.LocalDescription()
+}
+
+// iceConfiguration validates ICE servers and builds peer connection config
+func iceConfiguration(cfg configs.ICEConfig) (webrtc.Configuration, error) {
+	config := webrtc.Configuration{
+		// tcp + udp
+		ICETransportPolicy: webrtc.ICETransportPolicyAll,
+	}
+
+	// without servers only host candidates are gathered,
+	// so we don't wait for unreachable STUN in offline LAN
+	if cfg.LANOnly {
+		return config, nil
+	}
+
+	for _, server := range cfg.Servers {
+		for _, raw := range server.URLs {
+			uri, err := stun.ParseURI(raw)
+			if err != nil {
+				return config, fmt.Errorf("invalid ICE server %q: %w", raw, err)
+			}
+
+			isTURN := uri.Scheme == stun.SchemeTypeTURN || uri.Scheme == stun.SchemeTypeTURNS
+			if isTURN && (server.Username == "" || server.Credential == "") {
+				return config, fmt.Errorf("TURN server %q requires username and credential", raw)
+			}
+		}
+
+		config.ICEServers = append(config.ICEServers, webrtc.ICEServer{
+			URLs:           server.URLs,
+			Username:       server.Username,
+			Credential:     server.Credential,
+			CredentialType: webrtc.ICECredentialTypePassword,
+		})
+	}
+
+	if cfg.RelayOnly {
+		if !hasTURN(cfg.Servers) {
+			return config, errors.New("relay only mode requires TURN server")
+		}
+		config.ICETransportPolicy = webrtc.ICETransportPolicyRelay
+	}
+
+	return config, nil
+}
+
+func hasTURN(servers []configs.ICEServerConfig) bool {
+	for _, server := range servers {
+		for _, raw := range server.URLs {
+			uri, err := stun.ParseURI(raw)
+			if err == nil && (uri.Scheme == stun.SchemeTypeTURN || uri.Scheme == stun.SchemeTypeTURNS) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// describeCandidatePair returns route like "host -> srflx (udp)"
+func describeCandidatePair(pair *webrtc.ICECandidatePair) string {
+	if pair == nil || pair.Local == nil || pair.Remote == nil {
+		return "not connected"
+	}
+
+	return fmt.Sprintf(
+		"%s -> %s (%s)",
+		pair.Local.Typ,
+		pair.Remote.Typ,
+		pair.Local.Protocol,
+	)
+}
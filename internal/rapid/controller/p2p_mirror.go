@@ -0,0 +1,347 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0x0FACED/rapid/internal/mirror"
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/google/uuid"
+)
+
+// Control messages of folder sync, files are pulled by the
+// receiver through usual transfers
+const (
+	msgSyncRequest  = "sync_request"
+	msgSyncResponse = "sync_response"
+)
+
+// Sync operations
+const (
+	syncManifest = "manifest"
+	syncPush     = "push"
+	syncDelete   = "delete"
+)
+
+// Manifest is sent in pages, so every response fits into one control
+// message. Entries of the first page are kept for the next ones until
+// the listing is read or expires
+const (
+	maxManifestPage = 32 << 10
+	listingTTL      = time.Minute
+)
+
+var (
+	ErrSyncNotAccepted = errors.New("folder sync is disabled")
+	ErrListingExpired  = errors.New("manifest listing expired")
+)
+
+type syncRequest struct {
+	RequestID string       `json:"request_id"`
+	Op        string       `json:"op"`
+	Folder    string       `json:"folder"`
+	Entry     mirror.Entry `json:"entry,omitempty"`
+	// exported file that receiver pulls for push
+	FileID string `json:"file_id,omitempty"`
+	// next page of manifest listing, empty for the first one
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type syncResponse struct {
+	RequestID string         `json:"request_id"`
+	Entries   []mirror.Entry `json:"entries,omitempty"`
+	// set when manifest has more pages
+	Cursor string `json:"cursor,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// syncListing is the rest of manifest that wasn't sent yet
+type syncListing struct {
+	folder  string
+	entries []mirror.Entry
+	expires time.Time
+}
+
+// P2PMirror is folder sync over WebRTC connection, it serves
+// requests of the peer and implements mirror.Peer for our jobs
+type P2PMirror struct {
+	state *P2PConnectionState
+	// nil when we don't accept folders from the peer
+	store    *mirror.Store
+	pending  map[string]chan syncResponse
+	listings map[string]*syncListing
+	// running pulls are canceled when connection is closed
+	pulls map[string]context.CancelFunc
+
+	mu sync.Mutex
+}
+
+func NewP2PMirror(state *P2PConnectionState) *P2PMirror {
+	return &P2PMirror{
+		state:    state,
+		pending:  make(map[string]chan syncResponse),
+		listings: make(map[string]*syncListing),
+		pulls:    make(map[string]context.CancelFunc),
+	}
+}
+
+func (m *P2PMirror) SetStore(store *mirror.Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+}
+
+func (m *P2PMirror) Manifest(ctx context.Context, folder string) (*mirror.Manifest, error) {
+	manifest := mirror.NewManifest()
+	req := syncRequest{Op: syncManifest, Folder: folder}
+	for {
+		resp, err := m.request(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range resp.Entries {
+			manifest.Entries[entry.Path] = entry
+		}
+		if resp.Cursor == "" {
+			return manifest, nil
+		}
+		req.Cursor = resp.Cursor
+	}
+}
+
+// Push exports local file until the peer pulls and commits it
+func (m *P2PMirror) Push(ctx context.Context, folder string, entry mirror.Entry, local string) error {
+	file := model.File{
+		ID:   uuid.NewString(),
+		Name: path.Base(entry.Path),
+		Path: local,
+		Size: entry.Size,
+	}
+
+	transfers := m.state.Transfers()
+	transfers.Export(file)
+	defer transfers.Unexport(file.ID)
+
+	_, err := m.request(ctx, syncRequest{Op: syncPush, Folder: folder, Entry: entry, FileID: file.ID})
+	return err
+}
+
+func (m *P2PMirror) Delete(ctx context.Context, folder, p string) error {
+	_, err := m.request(ctx, syncRequest{Op: syncDelete, Folder: folder, Entry: mirror.Entry{Path: p}})
+	return err
+}
+
+func (m *P2PMirror) request(ctx context.Context, req syncRequest) (syncResponse, error) {
+	req.RequestID = uuid.NewString()
+	ch := make(chan syncResponse, 1)
+
+	m.mu.Lock()
+	m.pending[req.RequestID] = ch
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.pending, req.RequestID)
+		m.mu.Unlock()
+	}()
+
+	if err := m.state.sendControl(msgSyncRequest, req); err != nil {
+		return syncResponse{}, err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != "" {
+			return resp, errors.New(resp.Error)
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return syncResponse{}, ctx.Err()
+	}
+}
+
+// failPending ends requests that can't be answered anymore,
+// listings and pulls of the peer are dropped too
+func (m *P2PMirror) failPending(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, ch := range m.pending {
+		ch <- syncResponse{RequestID: id, Error: err.Error()}
+		delete(m.pending, id)
+	}
+	for id, cancel := range m.pulls {
+		cancel()
+		delete(m.pulls, id)
+	}
+	clear(m.listings)
+}
+
+func (m *P2PMirror) handleControl(msg controlMessage) {
+	switch msg.Type {
+	case msgSyncRequest:
+		var req syncRequest
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			log.Println("Invalid sync request:", err)
+			return
+		}
+		// push waits for the transfer
+		go m.serve(req)
+	case msgSyncResponse:
+		var resp syncResponse
+		if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+			log.Println("Invalid sync response:", err)
+			return
+		}
+
+		m.mu.Lock()
+		ch, ok := m.pending[resp.RequestID]
+		delete(m.pending, resp.RequestID)
+		m.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+func (m *P2PMirror) serve(req syncRequest) {
+	resp := syncResponse{RequestID: req.RequestID}
+	if err := m.handle(req, &resp); err != nil {
+		resp.Error = err.Error()
+	}
+
+	if err := m.state.sendControl(msgSyncResponse, resp); err != nil {
+		log.Println("Failed to send sync response:", err)
+		_ = m.state.sendControl(msgSyncResponse, syncResponse{RequestID: req.RequestID, Error: err.Error()})
+	}
+}
+
+func (m *P2PMirror) handle(req syncRequest, resp *syncResponse) error {
+	m.mu.Lock()
+	store := m.store
+	m.mu.Unlock()
+	if store == nil {
+		return ErrSyncNotAccepted
+	}
+
+	switch req.Op {
+	case syncManifest:
+		return m.manifestPage(store, req, resp)
+	case syncPush:
+		return m.pull(store, req)
+	case syncDelete:
+		return store.Delete(req.Folder, req.Entry.Path)
+	}
+	return errors.New("unknown sync operation: " + req.Op)
+}
+
+// manifestPage starts new listing of the folder or continues one
+// with the cursor and puts next page of entries into resp
+func (m *P2PMirror) manifestPage(store *mirror.Store, req syncRequest, resp *syncResponse) error {
+	var entries []mirror.Entry
+	if req.Cursor == "" {
+		manifest, err := store.Manifest(req.Folder)
+		if err != nil {
+			return err
+		}
+		entries = make([]mirror.Entry, 0, len(manifest.Entries))
+		for _, entry := range manifest.Entries {
+			entries = append(entries, entry)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	} else {
+		m.mu.Lock()
+		listing, ok := m.listings[req.Cursor]
+		delete(m.listings, req.Cursor)
+		m.mu.Unlock()
+		if !ok || listing.folder != req.Folder || time.Now().After(listing.expires) {
+			return ErrListingExpired
+		}
+		entries = listing.entries
+	}
+
+	// at least one entry, paths are much shorter than the page anyway
+	n, size := 0, 0
+	for n < len(entries) {
+		data, err := json.Marshal(entries[n])
+		if err != nil {
+			return err
+		}
+		if n > 0 && size+len(data) > maxManifestPage {
+			break
+		}
+		size += len(data) + 1
+		n++
+	}
+	resp.Entries = entries[:n]
+	if n == len(entries) {
+		return nil
+	}
+
+	resp.Cursor = uuid.NewString()
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for cursor, listing := range m.listings {
+		if now.After(listing.expires) {
+			delete(m.listings, cursor)
+		}
+	}
+	m.listings[resp.Cursor] = &syncListing{
+		folder:  req.Folder,
+		entries: entries[n:],
+		expires: now.Add(listingTTL),
+	}
+	return nil
+}
+
+// pull downloads pushed file into temp file of the folder and commits it
+func (m *P2PMirror) pull(store *mirror.Store, req syncRequest) error {
+	if _, err := mirror.CleanPath(req.Entry.Path); err != nil {
+		return err
+	}
+
+	tmp, err := store.TempFile(req.Folder)
+	if err != nil {
+		return err
+	}
+	_ = tmp.Close()
+
+	file := model.File{
+		ID:   req.FileID,
+		Name: path.Base(req.Entry.Path),
+		Size: req.Entry.Size,
+	}
+	transfers := m.state.Transfers()
+	t, err := transfers.pull(file, tmp.Name())
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	m.pulls[req.RequestID] = cancel
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.pulls, req.RequestID)
+		m.mu.Unlock()
+		cancel()
+	}()
+
+	if err := t.Wait(ctx); err != nil {
+		// incomplete download is removed with the transfer
+		_ = transfers.Cancel(t.ID)
+		return err
+	}
+
+	return store.Commit(req.Folder, req.Entry, tmp.Name())
+}
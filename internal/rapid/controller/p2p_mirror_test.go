@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/rapid/internal/mirror"
+)
+
+func TestManifestPages(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "docs")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// long names make manifest several times larger than a page
+	const files = 500
+	for i := range files {
+		name := fmt.Sprintf("%03d-%s.txt", i, strings.Repeat("x", 200))
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewP2PMirror(nil)
+	store := mirror.NewStore(root)
+
+	got := make(map[string]bool)
+	req := syncRequest{Op: syncManifest, Folder: "docs"}
+	pages := 0
+	for {
+		var resp syncResponse
+		if err := m.manifestPage(store, req, &resp); err != nil {
+			t.Fatal(err)
+		}
+		pages++
+
+		// page has to fit into one control message
+		data, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 64<<10-1024 {
+			t.Fatalf("page %d is %d bytes", pages, len(data))
+		}
+
+		for _, entry := range resp.Entries {
+			got[entry.Path] = true
+		}
+		if resp.Cursor == "" {
+			break
+		}
+		req.Cursor = resp.Cursor
+	}
+	if len(got) != files || pages < 3 {
+		t.Errorf("%d entries in %d pages, want %d entries in several pages", len(got), pages, files)
+	}
+	if len(m.listings) != 0 {
+		t.Errorf("%d listings are left", len(m.listings))
+	}
+
+	// cursor is used once and only for its folder
+	var first syncResponse
+	if err := m.manifestPage(store, syncRequest{Op: syncManifest, Folder: "docs"}, &first); err != nil {
+		t.Fatal(err)
+	}
+	err := m.manifestPage(store, syncRequest{Op: syncManifest, Folder: "other", Cursor: first.Cursor}, &syncResponse{})
+	if !errors.Is(err, ErrListingExpired) {
+		t.Errorf("cursor of another folder: error = %v", err)
+	}
+
+	if err := m.manifestPage(store, syncRequest{Op: syncManifest, Folder: "docs"}, &first); err != nil {
+		t.Fatal(err)
+	}
+	m.listings[first.Cursor].expires = time.Now().Add(-time.Second)
+	err = m.manifestPage(store, syncRequest{Op: syncManifest, Folder: "docs", Cursor: first.Cursor}, &syncResponse{})
+	if !errors.Is(err, ErrListingExpired) {
+		t.Errorf("expired cursor: error = %v", err)
+	}
+}
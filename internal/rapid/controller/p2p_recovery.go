@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/0x0FACED/rapid/internal/p2p/signaling"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	// ICE may recover from Disconnected by itself
+	disconnectGrace = 5 * time.Second
+
+	restartBackoffMin  = 2 * time.Second
+	restartBackoffMax  = 30 * time.Second
+	maxRestartAttempts = 5
+
+	redialTimeout = 10 * time.Second
+)
+
+var ErrReconnectFailed = errors.New("reconnection failed")
+
+// handleConnectionState restarts ICE of an authenticated session
+// when signaling path exists, otherwise the peer is reported as disconnected.
+func (c *P2PConnectionState) handleConnectionState(conn *webrtc.PeerConnection, state webrtc.PeerConnectionState) {
+	// events of replaced connection
+	if c.Conn() != conn {
+		return
+	}
+
+	switch state {
+	case webrtc.PeerConnectionStateConnected:
+		// onConnect is called after password handshake
+		c.isConnected.Store(true)
+		if c.authenticated.Load() {
+			go c.transfers.Resume()
+		}
+	case webrtc.PeerConnectionStateDisconnected:
+		c.isConnected.Store(false)
+		if !c.canRestart() {
+			c.onDisconnect()
+			return
+		}
+
+		time.AfterFunc(disconnectGrace, func() {
+			if c.Conn() == conn && conn.ConnectionState() == webrtc.PeerConnectionStateDisconnected {
+				c.startRestart(conn)
+			}
+		})
+	case webrtc.PeerConnectionStateFailed:
+		c.isConnected.Store(false)
+		if !c.canRestart() {
+			c.onDisconnect()
+			return
+		}
+		c.startRestart(conn)
+	case webrtc.PeerConnectionStateClosed:
+		c.isConnected.Store(false)
+		c.onDisconnect()
+	}
+}
+
+func (c *P2PConnectionState) canRestart() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.authenticated.Load() && c.signalingURL != ""
+}
+
+func (c *P2PConnectionState) startRestart(conn *webrtc.PeerConnection) {
+	if !c.restarting.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer c.restarting.Store(false)
+
+		if err := c.restartICE(conn); err != nil {
+			log.Println(err)
+			c.closeSignaling()
+			// Closed state reports disconnect
+			if err := conn.Close(); err != nil {
+				log.Println("Failed to close connection:", err)
+			}
+		}
+	}()
+}
+
+// restartICE retries ICE restart with exponential backoff until
+// connection is back, replaced or retry limit is reached
+func (c *P2PConnectionState) restartICE(conn *webrtc.PeerConnection) error {
+	backoff := restartBackoffMin
+	for attempt := 1; attempt <= maxRestartAttempts; attempt++ {
+		if c.Conn() != conn || conn.ConnectionState() == webrtc.PeerConnectionStateClosed {
+			return nil
+		}
+
+		log.Printf("ICE restart attempt %d/%d", attempt, maxRestartAttempts)
+		if err := c.requestRestart(); err != nil {
+			log.Println("ICE restart failed:", err)
+		}
+
+		if waitConnected(conn, backoff) {
+			log.Println("Connection restored")
+			return nil
+		}
+		backoff = min(backoff*2, restartBackoffMax)
+	}
+
+	return fmt.Errorf("%w after %d attempts", ErrReconnectFailed, maxRestartAttempts)
+}
+
+// requestRestart sends restart offer if we are offerer in signaling room,
+// otherwise the peer sends it when it sees us joined
+func (c *P2PConnectionState) requestRestart() error {
+	client := c.signalingClient()
+	if client == nil {
+		var err error
+		if client, err = c.redial(); err != nil {
+			return err
+		}
+	}
+
+	if client.Role() != signaling.RoleOfferer {
+		return nil
+	}
+	return c.restartOffer(client)
+}
+
+// restartOffer renegotiates ICE of the current connection,
+// DTLS session, password handshake and channels are kept
+func (c *P2PConnectionState) restartOffer(client *signaling.Client) error {
+	c.StopTrickle()
+	c.clearLocalCandidates()
+
+	conn := c.Conn()
+	offer, err := conn.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
+	if err != nil {
+		return err
+	}
+	if err := conn.SetLocalDescription(offer); err != nil {
+		return err
+	}
+	c.SetOffer(&offer)
+
+	if err := client.Send(signaling.TypeOffer, offer); err != nil {
+		return err
+	}
+	return c.StartTrickle(signalingTrickle(client))
+}
+
+// acceptRestart answers restart offer without resetting connection
+func (c *P2PConnectionState) acceptRestart(client *signaling.Client, offer webrtc.SessionDescription) error {
+	c.StopTrickle()
+	c.clearLocalCandidates()
+
+	if err := c.SetRemoteDescription(offer); err != nil {
+		return err
+	}
+
+	answer, err := c.newAnswer(nil)
+	if err != nil {
+		return err
+	}
+	if err := client.Send(signaling.TypeAnswer, answer); err != nil {
+		return err
+	}
+	return c.StartTrickle(signalingTrickle(client))
+}
+
+// restartable reports whether offer from the peer is ICE restart of current session
+func (c *P2PConnectionState) restartable() bool {
+	conn := c.Conn()
+	return c.authenticated.Load() &&
+		conn != nil &&
+		conn.ConnectionState() != webrtc.PeerConnectionStateClosed
+}
+
+// redial joins the same room again, signaling connection
+// may be lost together with the network
+func (c *P2PConnectionState) redial() (*signaling.Client, error) {
+	c.mu.RLock()
+	serverURL, code := c.signalingURL, c.signalingCode
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), redialTimeout)
+	defer cancel()
+
+	client, err := signaling.Dial(ctx, serverURL, code)
+	if err != nil {
+		return nil, err
+	}
+
+	c.keepSignaling(client, serverURL, code)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.runSignaling(client)
+	}()
+	go c.watchSignaling(client, errCh)
+
+	return client, nil
+}
+
+// keepSignaling stores signaling client for ICE restarts
+func (c *P2PConnectionState) keepSignaling(client *signaling.Client, serverURL, code string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.signaling = client
+	c.signalingURL = serverURL
+	c.signalingCode = code
+}
+
+func (c *P2PConnectionState) watchSignaling(client *signaling.Client, errCh <-chan error) {
+	err := <-errCh
+	log.Println(err)
+
+	c.mu.Lock()
+	if c.signaling == client {
+		c.signaling = nil
+	}
+	c.mu.Unlock()
+
+	client.Close()
+}
+
+func (c *P2PConnectionState) signalingClient() *signaling.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.signaling
+}
+
+// closeSignaling drops signaling path, session can't be restarted after it
+func (c *P2PConnectionState) closeSignaling() {
+	c.mu.Lock()
+	client := c.signaling
+	c.signaling = nil
+	c.signalingURL = ""
+	c.signalingCode = ""
+	c.mu.Unlock()
+
+	if client != nil {
+		client.Close()
+	}
+}
+
+func (c *P2PConnectionState) clearLocalCandidates() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.iceCandidates = make([]webrtc.ICECandidateInit, 0)
+	c.gatheringDone = false
+}
+
+func waitConnected(conn *webrtc.PeerConnection, timeout time.Duration) bool {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	deadline := time.After(timeout)
+	for {
+		switch conn.ConnectionState() {
+		case webrtc.PeerConnectionStateConnected:
+			return true
+		case webrtc.PeerConnectionStateClosed:
+			return false
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			return false
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/internal/bandwidth"
+	"github.com/0x0FACED/rapid/internal/mirror"
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/pion/webrtc/v4"
+)
+
+var (
+	ErrSessionNotFound = errors.New("peer session not found")
+	ErrEmptyPeerName   = errors.New("peer name is empty")
+)
+
+// PeerSession is connection to one remote peer with its own file list
+type PeerSession struct {
+	Name  string
+	State *P2PConnectionState
+	Files *FileState
+	Route string
+}
+
+// Status returns human readable connection status
+func (s *PeerSession) Status() string {
+	if s.State.restarting.Load() {
+		return "reconnecting"
+	}
+	if s.State.authenticated.Load() && s.State.isConnected.Load() {
+		return "connected"
+	}
+
+	conn := s.State.Conn()
+	if conn == nil {
+		return "disconnected"
+	}
+	switch conn.ConnectionState() {
+	case webrtc.PeerConnectionStateNew,
+		webrtc.PeerConnectionStateConnecting,
+		webrtc.PeerConnectionStateConnected:
+		return "connecting"
+	default:
+		return "disconnected"
+	}
+}
+
+func (s *PeerSession) Connected() bool {
+	return s.State.authenticated.Load() && s.State.isConnected.Load()
+}
+
+// App messages sent over the control channel
+const (
+	appChat      = "message"
+	appClipboard = "clipboard"
+)
+
+// appMessage is payload of the app control message
+type appMessage struct {
+	Type      string                 `json:"type"`
+	Message   *model.Message         `json:"message,omitempty"`
+	Clipboard *model.ClipboardUpdate `json:"clipboard,omitempty"`
+}
+
+// SessionCallbacks are called with session that triggered the event
+type SessionCallbacks struct {
+	OnConnect    func(*PeerSession)
+	OnDisconnect func(*PeerSession)
+	OnFiles      func(*PeerSession)
+	OnRoute      func(*PeerSession)
+	OnComplete   func(*PeerSession, *Transfer)
+	OnError      func(*PeerSession, *Transfer, error)
+	OnMessage    func(*PeerSession, model.Message)
+	OnClipboard  func(*PeerSession, model.ClipboardUpdate)
+}
+
+// SessionManager keeps named peer connections, each session
+// has independent WebRTC state, password and transfers
+type SessionManager struct {
+	sessions   map[string]*PeerSession
+	sortedKeys []string
+
+	iceConfig    configs.ICEConfig
+	downloadsDir string
+	catalog      func() []model.File
+	callbacks    SessionCallbacks
+	// nil when we don't accept mirrored folders
+	mirrorStore *mirror.Store
+	// nil means unlimited
+	bandwidth   *bandwidth.Manager
+	compression bool
+	// session ids of accepted codes, shared so codes can't be replayed
+	// into another session
+	exchanges *ExchangeLog
+
+	mu sync.RWMutex
+}
+
+func NewSessionManager(downloadsDir string, catalog func() []model.File) *SessionManager {
+	return &SessionManager{
+		sessions:     make(map[string]*PeerSession),
+		iceConfig:    configs.DefaultICEConfig(),
+		downloadsDir: downloadsDir,
+		catalog:      catalog,
+		compression:  true,
+		exchanges:    NewExchangeLog(),
+		callbacks: SessionCallbacks{
+			OnConnect:    func(*PeerSession) {},
+			OnDisconnect: func(*PeerSession) {},
+			OnFiles:      func(*PeerSession) {},
+			OnRoute:      func(*PeerSession) {},
+			OnComplete:   func(*PeerSession, *Transfer) {},
+			OnError:      func(*PeerSession, *Transfer, error) {},
+			OnMessage:    func(*PeerSession, model.Message) {},
+			OnClipboard:  func(*PeerSession, model.ClipboardUpdate) {},
+		},
+	}
+}
+
+func (m *SessionManager) SetCallbacks(callbacks SessionCallbacks) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbacks = callbacks
+}
+
+// GetOrCreate returns session with the name, new session is created if needed
+func (m *SessionManager) GetOrCreate(name string) (*PeerSession, error) {
+	if name == "" {
+		return nil, ErrEmptyPeerName
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if session, ok := m.sessions[name]; ok {
+		return session, nil
+	}
+
+	state, err := NewP2PConnectionState()
+	if err != nil {
+		return nil, err
+	}
+	if err := state.SetICEConfig(m.iceConfig); err != nil {
+		return nil, err
+	}
+	state.Mirror().SetStore(m.mirrorStore)
+	state.SetExchangeLog(m.exchanges)
+
+	session := &PeerSession{
+		Name:  name,
+		State: state,
+		Files: NewFileState(),
+		Route: "not connected",
+	}
+	m.bind(session)
+
+	m.sessions[name] = session
+	m.sortedKeys = append(m.sortedKeys, name)
+	sort.Strings(m.sortedKeys)
+
+	return session, nil
+}
+
+// bind routes events of the session state to manager callbacks
+func (m *SessionManager) bind(session *PeerSession) {
+	state := session.State
+
+	state.SetCallbacks(
+		func() { m.getCallbacks().OnConnect(session) },
+		func() { m.getCallbacks().OnDisconnect(session) },
+		func(data []byte) { m.handleApp(session, data) },
+	)
+	state.SetOnRoute(func(route string) {
+		m.mu.Lock()
+		session.Route = route
+		m.mu.Unlock()
+		m.getCallbacks().OnRoute(session)
+	})
+
+	transfers := state.Transfers()
+	transfers.SetDownloadsDir(m.downloadsDir)
+	transfers.SetCatalog(m.catalog)
+	transfers.SetBandwidth(m.bandwidth, session.Name)
+	transfers.SetCompression(m.compression)
+	transfers.SetCallbacks(
+		func(files []model.File) {
+			received := NewFileState()
+			for _, file := range files {
+				received.AddFromPeer(session.Name, file.ID, file)
+			}
+
+			m.mu.Lock()
+			session.Files = received
+			m.mu.Unlock()
+			m.getCallbacks().OnFiles(session)
+		},
+		func(t *Transfer) { m.getCallbacks().OnComplete(session, t) },
+		func(t *Transfer, err error) { m.getCallbacks().OnError(session, t, err) },
+	)
+}
+
+func (m *SessionManager) handleApp(session *PeerSession, data []byte) {
+	var msg appMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Println("Invalid message from", session.Name, err)
+		return
+	}
+
+	switch {
+	case msg.Type == appChat && msg.Message != nil:
+		if err := msg.Message.Validate(); err != nil {
+			log.Println("Invalid message from", session.Name, err)
+			return
+		}
+		m.getCallbacks().OnMessage(session, *msg.Message)
+	case msg.Type == appClipboard && msg.Clipboard != nil:
+		if err := msg.Clipboard.Validate(); err != nil {
+			log.Println("Invalid clipboard update from", session.Name, err)
+			return
+		}
+		m.getCallbacks().OnClipboard(session, *msg.Clipboard)
+	default:
+		log.Println("Unknown message from", session.Name, msg.Type)
+	}
+}
+
+func (m *SessionManager) getCallbacks() SessionCallbacks {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.callbacks
+}
+
+func (m *SessionManager) Get(name string) (*PeerSession, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	session, ok := m.sessions[name]
+	return session, ok
+}
+
+// GetAll returns sessions sorted by name
+func (m *SessionManager) GetAll() []*PeerSession {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*PeerSession, 0, len(m.sortedKeys))
+	for _, key := range m.sortedKeys {
+		if session, ok := m.sessions[key]; ok {
+			result = append(result, session)
+		}
+	}
+	return result
+}
+
+// Files returns file list of the session, it is replaced on every announce
+func (m *SessionManager) Files(name string) *FileState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if session, ok := m.sessions[name]; ok {
+		return session.Files
+	}
+	return nil
+}
+
+// Route returns selected route of the session
+func (m *SessionManager) Route(name string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if session, ok := m.sessions[name]; ok {
+		return session.Route
+	}
+	return "not connected"
+}
+
+// Remove closes session and forgets it
+func (m *SessionManager) Remove(name string) error {
+	m.mu.Lock()
+	session, ok := m.sessions[name]
+	if !ok {
+		m.mu.Unlock()
+		return ErrSessionNotFound
+	}
+	delete(m.sessions, name)
+	for i, key := range m.sortedKeys {
+		if key == name {
+			m.sortedKeys = append(m.sortedKeys[:i], m.sortedKeys[i+1:]...)
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	return session.State.Close()
+}
+
+// SendMessage sends chat message to the connected peer
+func (m *SessionManager) SendMessage(name string, msg model.Message) error {
+	session, ok := m.Get(name)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	return m.sendApp(session, appMessage{Type: appChat, Message: &msg})
+}
+
+// SendClipboard sends clipboard contents to the connected peer
+func (m *SessionManager) SendClipboard(name string, update model.ClipboardUpdate) error {
+	session, ok := m.Get(name)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if err := update.Validate(); err != nil {
+		return err
+	}
+	return m.sendApp(session, appMessage{Type: appClipboard, Clipboard: &update})
+}
+
+func (m *SessionManager) sendApp(session *PeerSession, msg appMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return session.State.SendMessage(data)
+}
+
+// Announce sends our shared files to every connected peer
+func (m *SessionManager) Announce() error {
+	var errs []error
+	for _, session := range m.GetAll() {
+		if !session.Connected() {
+			continue
+		}
+		if err := session.State.Transfers().Announce(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MirrorPeer returns folder sync with the connected peer
+func (m *SessionManager) MirrorPeer(name string) (mirror.Peer, error) {
+	session, ok := m.Get(name)
+	if !ok || !session.Connected() {
+		return nil, ErrSessionNotFound
+	}
+	return session.State.Mirror(), nil
+}
+
+// SetMirrorStore sets store for folders mirrored by every peer, nil disables it
+func (m *SessionManager) SetMirrorStore(store *mirror.Store) {
+	m.mu.Lock()
+	m.mirrorStore = store
+	m.mu.Unlock()
+
+	for _, session := range m.GetAll() {
+		session.State.Mirror().SetStore(store)
+	}
+}
+
+// SetBandwidth limits transfers of every peer, peers are matched by session name
+func (m *SessionManager) SetBandwidth(bw *bandwidth.Manager) {
+	m.mu.Lock()
+	m.bandwidth = bw
+	m.mu.Unlock()
+
+	for _, session := range m.GetAll() {
+		session.State.Transfers().SetBandwidth(bw, session.Name)
+	}
+}
+
+// SetCompression offers compressed chunks for downloads from every peer
+func (m *SessionManager) SetCompression(enabled bool) {
+	m.mu.Lock()
+	m.compression = enabled
+	m.mu.Unlock()
+
+	for _, session := range m.GetAll() {
+		session.State.Transfers().SetCompression(enabled)
+	}
+}
+
+func (m *SessionManager) Compression() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.compression
+}
+
+func (m *SessionManager) ICEConfig() configs.ICEConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.iceConfig
+}
+
+// SetICEConfig validates config, it's used for the next connection of every session
+func (m *SessionManager) SetICEConfig(cfg configs.ICEConfig) error {
+	if _, err := iceConfiguration(cfg); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.iceConfig = cfg
+	m.mu.Unlock()
+
+	for _, session := range m.GetAll() {
+		if err := session.State.SetICEConfig(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *SessionManager) CloseAll() {
+	for _, session := range m.GetAll() {
+		_ = m.Remove(session.Name)
+	}
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/0x0FACED/rapid/internal/p2p/signaling"
+	"github.com/pion/webrtc/v4"
+)
+
+const defaultConnectTimeout = time.Minute
+
+// ConnectByCode negotiates connection through the signaling server,
+// both peers must join the same room code and use the same password.
+func (c *P2PConnectionState) ConnectByCode(ctx context.Context, serverURL, code string) error {
+	client, err := signaling.Dial(ctx, serverURL, code)
+	if err != nil {
+		return err
+	}
+
+	// previous connection is replaced when negotiation starts
+	c.closeSignaling()
+	c.resetAuth()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.runSignaling(client)
+	}()
+
+	connected := make(chan error, 1)
+	go func() {
+		connected <- c.waitForAuth(ctx)
+	}()
+
+	select {
+	case err = <-errCh:
+	case err = <-connected:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.StopTrickle()
+		client.Close()
+		return err
+	}
+
+	// signaling stays open, it is the path for ICE restart
+	c.keepSignaling(client, serverURL, code)
+	go c.watchSignaling(client, errCh)
+	return nil
+}
+
+func (c *P2PConnectionState) runSignaling(client *signaling.Client) error {
+	for {
+		msg, err := client.Receive()
+		if err != nil {
+			return fmt.Errorf("signaling connection lost: %w", err)
+		}
+
+		if err := c.handleSignaling(client, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *P2PConnectionState) handleSignaling(client *signaling.Client, msg signaling.Message) error {
+	switch msg.Type {
+	case signaling.TypePeerJoined:
+		if client.Role() != signaling.RoleOfferer {
+			return nil
+		}
+		// peer came back after network change
+		if c.restartable() {
+			return c.restartOffer(client)
+		}
+
+		offer, err := c.newOffer(nil)
+		if err != nil {
+			return err
+		}
+		if err := client.Send(signaling.TypeOffer, offer); err != nil {
+			return err
+		}
+		return c.StartTrickle(signalingTrickle(client))
+	case signaling.TypeOffer:
+		var offer webrtc.SessionDescription
+		if err := json.Unmarshal(msg.Payload, &offer); err != nil {
+			return fmt.Errorf("invalid offer: %w", err)
+		}
+
+		if c.restartable() {
+			return c.acceptRestart(client, offer)
+		}
+
+		if err := c.AcceptOffer(offer); err != nil {
+			return err
+		}
+
+		answer, err := c.newAnswer(nil)
+		if err != nil {
+			return err
+		}
+		if err := client.Send(signaling.TypeAnswer, answer); err != nil {
+			return err
+		}
+		return c.StartTrickle(signalingTrickle(client))
+	case signaling.TypeAnswer:
+		var answer webrtc.SessionDescription
+		if err := json.Unmarshal(msg.Payload, &answer); err != nil {
+			return fmt.Errorf("invalid answer: %w", err)
+		}
+		return c.SetRemoteDescription(answer)
+	case signaling.TypeCandidate:
+		var candidate webrtc.ICECandidateInit
+		if err := json.Unmarshal(msg.Payload, &candidate); err != nil {
+			return fmt.Errorf("invalid candidate: %w", err)
+		}
+		return c.AddRemoteICECandidate(candidate)
+	case signaling.TypeEndOfCandidates:
+		return c.AddRemoteICECandidate(webrtc.ICECandidateInit{})
+	case signaling.TypePeerLeft:
+		log.Println("Peer left signaling room")
+	case signaling.TypeError:
+		return errors.New(msg.Error)
+	}
+
+	return nil
+}
+
+func (c *P2PConnectionState) waitForAuth(ctx context.Context) error {
+	timeout := defaultConnectTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	return c.WaitForConnection(timeout)
+}
+
+func signalingTrickle(client *signaling.Client) TrickleFunc {
+	return func(candidate *webrtc.ICECandidateInit) error {
+		if candidate == nil {
+			return client.Send(signaling.TypeEndOfCandidates, nil)
+		}
+		return client.Send(signaling.TypeCandidate, candidate)
+	}
+}
@@ -1,137 +1,122 @@
 package controller
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log"
 	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/0x0FACED/rapid/configs"
+	"github.com/0x0FACED/rapid/internal/p2p/signaling"
+	"github.com/0x0FACED/rapid/pkg/spake2"
 	"github.com/pion/webrtc/v4"
-	"golang.org/x/crypto/bcrypt"
-)
-
-type EncodedOffer struct {
-	// offer string
-	SDP string `json:"sdp"`
-	// hash of pass
-	Hash string `json:"hash"`
-}
-
-type DecodedOffer struct {
-	// offer string
-	SDP webrtc.SessionDescription `json:"sdp"`
-	// hash of pass
-	Hash string `json:"hash"`
-}
-
-type EncodedAnswer struct {
-	SDP  string `json:"sdp"`
-	Hash string `json:"hash"`
-}
-
-type DecodedAnswer struct {
-	SDP  webrtc.SessionDescription
-	Hash string
-}
-
-var (
-	ICEServers = []webrtc.ICEServer{
-		{URLs: []string{"stun:stun.l.google.com:19302"}},
-		{URLs: []string{"stun:stun.l.google.com:5349"}},
-		{URLs: []string{"stun:stun1.l.google.com:3478"}},
-		{URLs: []string{"stun:stun1.l.google.com:5349"}},
-		{URLs: []string{"stun:stun2.l.google.com:19302"}},
-		{URLs: []string{"stun:stun2.l.google.com:5349"}},
-		{URLs: []string{"stun:stun3.l.google.com:3478"}},
-		{URLs: []string{"stun:stun3.l.google.com:5349"}},
-		{URLs: []string{"stun:stun4.l.google.com:19302"}},
-	}
 )
 
 type P2PConnectionState struct {
 	conn *webrtc.PeerConnection
-	dc   *webrtc.DataChannel
-
-	offer         *webrtc.SessionDescription
-	answer        *webrtc.SessionDescription
-	iceCandidates []webrtc.ICECandidateInit
-
-	password string
+	// control channel, file data goes through transfers channels
+	dc        *webrtc.DataChannel
+	transfers *TransferManager
+	mirror    *P2PMirror
+	previews  *P2PThumbnails
+
+	offer  *webrtc.SessionDescription
+	answer *webrtc.SessionDescription
+	// local candidates, see p2p_ice.go
+	iceCandidates     []webrtc.ICECandidateInit
+	gatheringDone     bool
+	trickle           TrickleFunc
+	trickleMu         sync.Mutex
+	pendingCandidates []webrtc.ICECandidateInit
+	// one-shot exchange state, see p2p_exchange.go
+	exchangeID []byte
+	exchanges  *ExchangeLog
+	// kept after connect for ICE restarts, see p2p_recovery.go
+	signaling     *signaling.Client
+	signalingURL  string
+	signalingCode string
+	restarting    atomic.Bool
+
+	password  string
+	iceConfig configs.ICEConfig
+
+	// password handshake state, see p2p_auth.go
+	pake          *spake2.State
+	authErr       error
+	authenticated atomic.Bool
+	authMu        sync.Mutex
 
 	isConnected  atomic.Bool
 	onConnect    func()
 	onDisconnect func()
 	onMessage    func([]byte)
+	onRoute      func(string)
 
 	mu sync.RWMutex
 }
 
 func NewP2PConnectionState() (*P2PConnectionState, error) {
-	// TODO: refactor
-	conn, err := webrtc.NewPeerConnection(webrtc.Configuration{
-		// hardcoded free ice servers list
-		ICEServers: ICEServers,
-		// tcp + udp
-		ICETransportPolicy: webrtc.ICETransportPolicyAll,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	dc, err := conn.CreateDataChannel("rapid", nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return &P2PConnectionState{
-		conn:          conn,
-		dc:            dc,
+	state := &P2PConnectionState{
 		iceCandidates: make([]webrtc.ICECandidateInit, 0),
+		exchanges:     NewExchangeLog(),
+
+		iceConfig: configs.DefaultICEConfig(),
 
 		onConnect:    func() {},
 		onDisconnect: func() {},
 		onMessage:    func([]byte) {},
-	}, nil
+		onRoute:      func(string) {},
+	}
+	state.transfers = NewTransferManager(state)
+	state.mirror = NewP2PMirror(state)
+	state.previews = NewP2PThumbnails(state)
+
+	// TODO: refactor
+	if err := state.Initialize(); err != nil {
+		return nil, err
+	}
+
+	return state, nil
 }
 
 func (c *P2PConnectionState) Initialize() error {
-	conn, err := webrtc.NewPeerConnection(webrtc.Configuration{
-		ICEServers:         ICEServers,
-		ICETransportPolicy: webrtc.ICETransportPolicyAll,
-	})
+	config, err := iceConfiguration(c.ICEConfig())
 	if err != nil {
 		return err
 	}
 
-	conn.OnICECandidate(func(candidate *webrtc.ICECandidate) {
-		if candidate == nil {
-			return
-		}
-		c.AddICECandidate(candidate.ToJSON())
+	conn, err := webrtc.NewPeerConnection(config)
+	if err != nil {
+		return err
+	}
+
+	conn.SCTP().Transport().ICETransport().OnSelectedCandidatePairChange(func(pair *webrtc.ICECandidatePair) {
+		c.onRoute(describeCandidatePair(pair))
 	})
 
+	c.mu.Lock()
+	c.iceCandidates = make([]webrtc.ICECandidateInit, 0)
+	c.gatheringDone = false
+	c.pendingCandidates = nil
+	c.mu.Unlock()
+
+	conn.OnICECandidate(c.handleLocalCandidate)
+
 	conn.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
-		switch state {
-		case webrtc.PeerConnectionStateConnected:
-			c.isConnected.Store(true)
-			c.onConnect()
-		case webrtc.PeerConnectionStateDisconnected,
-			webrtc.PeerConnectionStateFailed,
-			webrtc.PeerConnectionStateClosed:
-			c.isConnected.Store(false)
-			c.onDisconnect()
-		}
+		c.handleConnectionState(conn, state)
 	})
 
-	dc, err := conn.CreateDataChannel("rapid", &webrtc.DataChannelInit{
+	// channels opened by the peer are always transfer channels
+	conn.OnDataChannel(func(dc *webrtc.DataChannel) {
+		c.transfers.handleChannel(dc)
+	})
+
+	dc, err := conn.CreateDataChannel(controlChannelLabel, &webrtc.DataChannelInit{
 		Negotiated: BoolToPtr(true),
 		ID:         Uint16ToPtr(0),
 	})
@@ -141,14 +126,21 @@ func (c *P2PConnectionState) Initialize() error {
 
 	dc.OnOpen(func() {
 		fmt.Println("Data channel opened!")
+		go func() {
+			if err := c.startAuth(); err != nil {
+				c.failAuth(err)
+			}
+		}()
 	})
 
 	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
-		c.onMessage(msg.Data)
+		c.handleControl(msg.Data)
 	})
 
+	c.mu.Lock()
 	c.conn = conn
 	c.dc = dc
+	c.mu.Unlock()
 	return nil
 }
 
@@ -162,191 +154,160 @@ func Uint16ToPtr(val uint16) *uint16 {
 	return &val
 }
 
-func (c *P2PConnectionState) Conn() *webrtc.PeerConnection {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.conn
+// Mirror returns folder sync with the peer
+func (c *P2PConnectionState) Mirror() *P2PMirror {
+	return c.mirror
 }
 
-// TODO: refactor
-func (c *P2PConnectionState) CreateEncodedOffer(opts *webrtc.OfferOptions) (string, error) {
-	if c.conn != nil {
-		err := c.Close()
-		if err != nil {
-			return "", err
-		}
-	}
+// Thumbnails returns previews of the peer files
+func (c *P2PConnectionState) Thumbnails() *P2PThumbnails {
+	return c.previews
+}
 
-	if err := c.Initialize(); err != nil {
-		return "", err
-	}
+func (c *P2PConnectionState) Transfers() *TransferManager {
+	return c.transfers
+}
 
-	offer, err := c.conn.CreateOffer(opts)
-	if err != nil {
-		return "", err
+// openChannel opens new in-band negotiated channel on current connection
+func (c *P2PConnectionState) openChannel(label string, profile ChannelProfile) (*webrtc.DataChannel, error) {
+	conn := c.Conn()
+	if conn == nil {
+		return nil, errors.New("no connection")
 	}
+	return conn.CreateDataChannel(label, profile.dataChannelInit())
+}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword(
-		[]byte(c.Password()),
-		bcrypt.DefaultCost,
-	)
+func (c *P2PConnectionState) sendControl(msgType string, payload any) error {
+	data, err := json.Marshal(payload)
 	if err != nil {
-		return "", err
-	}
-
-	data := EncodedOffer{
-		SDP:  offer.SDP,
-		Hash: string(hashedPassword),
+		return err
 	}
 
-	jsonData, err := json.Marshal(data)
+	msg, err := json.Marshal(controlMessage{Type: msgType, Payload: data})
 	if err != nil {
-		return "", err
-	}
-
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	if _, err := gz.Write(jsonData); err != nil {
-		return "", err
-	}
-	if err := gz.Close(); err != nil {
-		return "", err
+		return err
 	}
 
-	if err = c.conn.SetLocalDescription(offer); err != nil {
-		return "", err
+	dc := c.DataChannel()
+	if dc == nil {
+		return errors.New("no control channel")
 	}
-
-	c.offer = &offer
-
-	return base64.URLEncoding.EncodeToString(buf.Bytes()), nil
+	return dc.Send(msg)
 }
 
-func (c *P2PConnectionState) DecodeOffer(offer string) (*DecodedOffer, error) {
-	decoded, err := base64.URLEncoding.DecodeString(offer)
-	if err != nil {
-		return nil, fmt.Errorf("base64 decode failed: %w", err)
+func (c *P2PConnectionState) handleControl(data []byte) {
+	var msg controlMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Println("Invalid control message:", err)
+		return
 	}
 
-	gzReader, err := gzip.NewReader(bytes.NewReader(decoded))
-	if err != nil {
-		return nil, fmt.Errorf("gzip reader create failed: %w", err)
+	if msg.Type == msgPake || msg.Type == msgPakeConfirm {
+		c.handleAuth(msg)
+		return
 	}
-	defer gzReader.Close()
 
-	uncompressed, err := io.ReadAll(gzReader)
-	if err != nil {
-		return nil, fmt.Errorf("gzip decompress failed: %w", err)
+	if !c.authenticated.Load() {
+		log.Println("Dropping control message from unauthenticated peer:", msg.Type)
+		return
 	}
 
-	var encodedOffer EncodedOffer
-	if err := json.Unmarshal(uncompressed, &encodedOffer); err != nil {
-		return nil, fmt.Errorf("json unmarshal failed: %w", err)
-	}
-
-	if encodedOffer.SDP == "" {
-		return nil, errors.New("empty SDP in offer")
+	if msg.Type == msgApp {
+		var payload []byte
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return
+		}
+		c.onMessage(payload)
+		return
 	}
 
-	sdp := webrtc.SessionDescription{
-		Type: webrtc.SDPTypeOffer,
-		SDP:  encodedOffer.SDP,
+	if msg.Type == msgSyncRequest || msg.Type == msgSyncResponse {
+		c.mirror.handleControl(msg)
+		return
 	}
 
-	decodedOffer := &DecodedOffer{
-		SDP:  sdp,
-		Hash: encodedOffer.Hash,
+	if msg.Type == msgThumbnailRequest || msg.Type == msgThumbnailResponse {
+		c.previews.handleControl(msg)
+		return
 	}
 
-	return decodedOffer, nil
+	c.transfers.handleControl(msg)
 }
 
-func (c *P2PConnectionState) DecodeAnswer(answer string) (*DecodedAnswer, error) {
-	decoded, err := base64.URLEncoding.DecodeString(answer)
-	if err != nil {
-		return nil, fmt.Errorf("base64 decode failed: %w", err)
-	}
-
-	gzReader, err := gzip.NewReader(bytes.NewReader(decoded))
-	if err != nil {
-		return nil, fmt.Errorf("gzip reader create failed: %w", err)
-	}
-	defer gzReader.Close()
+func (c *P2PConnectionState) Conn() *webrtc.PeerConnection {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn
+}
 
-	uncompressed, err := io.ReadAll(gzReader)
-	if err != nil {
-		return nil, fmt.Errorf("gzip decompress failed: %w", err)
+// reset replaces current connection with a fresh one
+func (c *P2PConnectionState) reset() error {
+	if c.Conn() != nil {
+		if err := c.Close(); err != nil {
+			return err
+		}
 	}
 
-	var encodedAnswer EncodedAnswer
-	if err := json.Unmarshal(uncompressed, &encodedAnswer); err != nil {
-		return nil, fmt.Errorf("json unmarshal failed: %w", err)
-	}
+	c.StopTrickle()
+	c.closeSignaling()
 
-	if encodedAnswer.SDP == "" {
-		return nil, errors.New("empty SDP in answer")
+	if err := c.Initialize(); err != nil {
+		return err
 	}
+	c.resetAuth()
 
-	return &DecodedAnswer{
-		SDP: webrtc.SessionDescription{
-			Type: webrtc.SDPTypeAnswer,
-			SDP:  encodedAnswer.SDP,
-		},
-		Hash: encodedAnswer.Hash,
-	}, nil
+	c.SetOffer(nil)
+	c.SetAnswer(nil)
+	// new exchange sets its own id
+	c.setExchangeID(nil)
+	return nil
 }
 
-func (c *P2PConnectionState) CreateEncodedAnswer(opts *webrtc.AnswerOptions) (string, error) {
-	if c.conn.RemoteDescription() == nil {
-		return "", errors.New("remote description not set")
+// newOffer resets connection and sets new offer as local description
+func (c *P2PConnectionState) newOffer(opts *webrtc.OfferOptions) (webrtc.SessionDescription, error) {
+	if err := c.reset(); err != nil {
+		return webrtc.SessionDescription{}, err
 	}
 
-	answer, err := c.conn.CreateAnswer(opts)
+	conn := c.Conn()
+	offer, err := conn.CreateOffer(opts)
 	if err != nil {
-		return "", err
+		return webrtc.SessionDescription{}, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword(
-		[]byte(c.Password()),
-		bcrypt.DefaultCost,
-	)
-	if err != nil {
-		return "", err
+	if err = conn.SetLocalDescription(offer); err != nil {
+		return webrtc.SessionDescription{}, err
 	}
 
-	data := EncodedAnswer{
-		SDP:  answer.SDP,
-		Hash: string(hashedPassword),
-	}
+	c.SetOffer(&offer)
+	return offer, nil
+}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return "", err
+// newAnswer answers remote offer and sets answer as local description
+func (c *P2PConnectionState) newAnswer(opts *webrtc.AnswerOptions) (webrtc.SessionDescription, error) {
+	conn := c.Conn()
+	if conn.RemoteDescription() == nil {
+		return webrtc.SessionDescription{}, errors.New("remote description not set")
 	}
 
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	if _, err := gz.Write(jsonData); err != nil {
-		return "", err
-	}
-	if err := gz.Close(); err != nil {
-		return "", err
+	answer, err := conn.CreateAnswer(opts)
+	if err != nil {
+		return webrtc.SessionDescription{}, err
 	}
 
-	if err = c.conn.SetLocalDescription(answer); err != nil {
-		return "", err
+	if err = conn.SetLocalDescription(answer); err != nil {
+		return webrtc.SessionDescription{}, err
 	}
 
-	c.answer = &answer
-
-	return base64.URLEncoding.EncodeToString(buf.Bytes()), nil
+	c.SetAnswer(&answer)
+	return answer, nil
 }
 
 func (c *P2PConnectionState) SendMessage(msg []byte) error {
-	if !c.isConnected.Load() {
+	if !c.authenticated.Load() {
 		return errors.New("not connected")
 	}
-	return c.dc.Send(msg)
+	return c.sendControl(msgApp, msg)
 }
 
 func (c *P2PConnectionState) WaitForConnection(timeout time.Duration) error {
@@ -358,7 +319,10 @@ func (c *P2PConnectionState) WaitForConnection(timeout time.Duration) error {
 		case <-ctx.Done():
 			return errors.New("connection timeout")
 		default:
-			if c.isConnected.Load() {
+			if err := c.AuthError(); err != nil {
+				return err
+			}
+			if c.authenticated.Load() {
 				return nil
 			}
 			time.Sleep(100 * time.Millisecond)
@@ -366,13 +330,6 @@ func (c *P2PConnectionState) WaitForConnection(timeout time.Duration) error {
 	}
 }
 
-func (c *P2PConnectionState) ValidatePassword(hash string) error {
-	return bcrypt.CompareHashAndPassword(
-		[]byte(hash),
-		[]byte(c.Password()),
-	)
-}
-
 func (c *P2PConnectionState) SetCallbacks(
 	onConnect func(),
 	onDisconnect func(),
@@ -437,29 +394,41 @@ func (c *P2PConnectionState) SetPassword(password string) {
 	c.password = password
 }
 
-func (c *P2PConnectionState) AddICECandidate(candidate webrtc.ICECandidateInit) {
+func (c *P2PConnectionState) ICEConfig() configs.ICEConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.iceConfig
+}
+
+// SetICEConfig validates config, it's used for the next connection
+func (c *P2PConnectionState) SetICEConfig(cfg configs.ICEConfig) error {
+	if _, err := iceConfiguration(cfg); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.iceCandidates = append(c.iceCandidates, candidate)
+	c.iceConfig = cfg
+	return nil
+}
+
+// SetOnRoute sets callback for selected candidate pair changes
+func (c *P2PConnectionState) SetOnRoute(onRoute func(string)) {
+	c.onRoute = onRoute
 }
 
+// ICECandidates returns gathered local candidates
 func (c *P2PConnectionState) ICECandidates() []webrtc.ICECandidateInit {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return slices.Clone(c.iceCandidates)
 }
 
-func (c *P2PConnectionState) AddRemoteICECandidates() error {
-	for _, candidate := range c.ICECandidates() {
-		if err := c.conn.AddICECandidate(candidate); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 func (c *P2PConnectionState) Close() error {
+	c.transfers.CloseAll()
+	c.mirror.failPending(ErrTransferAborted)
+	c.previews.failPending(ErrTransferAborted)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/0x0FACED/rapid/pkg/thumbnail"
+	"github.com/google/uuid"
+)
+
+// Control messages of file previews
+const (
+	msgThumbnailRequest  = "thumbnail_request"
+	msgThumbnailResponse = "thumbnail_response"
+)
+
+// previews are sent in one control message, bigger
+// images may not fit into the channel message size
+const maxP2PThumbnailSize = 256
+
+type thumbnailRequest struct {
+	RequestID string `json:"request_id"`
+	FileID    string `json:"file_id"`
+	Size      int    `json:"size"`
+}
+
+type thumbnailResponse struct {
+	RequestID   string         `json:"request_id"`
+	Kind        thumbnail.Kind `json:"kind,omitempty"`
+	ContentType string         `json:"content_type,omitempty"`
+	Data        []byte         `json:"data,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// P2PThumbnails serves previews of our shared files
+// and requests previews of the peer files
+type P2PThumbnails struct {
+	state   *P2PConnectionState
+	pending map[string]chan thumbnailResponse
+
+	mu sync.Mutex
+}
+
+func NewP2PThumbnails(state *P2PConnectionState) *P2PThumbnails {
+	return &P2PThumbnails{
+		state:   state,
+		pending: make(map[string]chan thumbnailResponse),
+	}
+}
+
+// Get requests preview of the peer file, size is the longest side of image
+func (t *P2PThumbnails) Get(ctx context.Context, fileID string, size int) (thumbnail.Thumbnail, error) {
+	req := thumbnailRequest{
+		RequestID: uuid.NewString(),
+		FileID:    fileID,
+		Size:      min(size, maxP2PThumbnailSize),
+	}
+	ch := make(chan thumbnailResponse, 1)
+
+	t.mu.Lock()
+	t.pending[req.RequestID] = ch
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, req.RequestID)
+		t.mu.Unlock()
+	}()
+
+	if err := t.state.sendControl(msgThumbnailRequest, req); err != nil {
+		return thumbnail.Thumbnail{}, err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != "" {
+			return thumbnail.Thumbnail{}, errors.New(resp.Error)
+		}
+		return thumbnail.Thumbnail{Kind: resp.Kind, ContentType: resp.ContentType, Data: resp.Data}, nil
+	case <-ctx.Done():
+		return thumbnail.Thumbnail{}, ctx.Err()
+	}
+}
+
+// failPending ends requests that can't be answered anymore
+func (t *P2PThumbnails) failPending(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, ch := range t.pending {
+		ch <- thumbnailResponse{RequestID: id, Error: err.Error()}
+		delete(t.pending, id)
+	}
+}
+
+func (t *P2PThumbnails) handleControl(msg controlMessage) {
+	switch msg.Type {
+	case msgThumbnailRequest:
+		var req thumbnailRequest
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			log.Println("Invalid thumbnail request:", err)
+			return
+		}
+		// images are decoded outside of the channel callback
+		go t.serve(req)
+	case msgThumbnailResponse:
+		var resp thumbnailResponse
+		if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+			log.Println("Invalid thumbnail response:", err)
+			return
+		}
+
+		t.mu.Lock()
+		ch, ok := t.pending[resp.RequestID]
+		delete(t.pending, resp.RequestID)
+		t.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+func (t *P2PThumbnails) serve(req thumbnailRequest) {
+	resp := thumbnailResponse{RequestID: req.RequestID}
+
+	file, found := t.state.Transfers().sharedFile(req.FileID)
+	if found {
+		thumb, err := thumbnail.Generate(file.Name, file.Path, min(req.Size, maxP2PThumbnailSize))
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Kind, resp.ContentType, resp.Data = thumb.Kind, thumb.ContentType, thumb.Data
+		}
+	} else {
+		resp.Error = ErrFileNotShared.Error()
+	}
+
+	if err := t.state.sendControl(msgThumbnailResponse, resp); err != nil {
+		log.Println("Failed to send thumbnail:", err)
+		_ = t.state.sendControl(msgThumbnailResponse, thumbnailResponse{RequestID: req.RequestID, Error: err.Error()})
+	}
+}
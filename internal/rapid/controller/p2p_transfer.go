@@ -0,0 +1,947 @@
+package controller
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/0x0FACED/rapid/internal/bandwidth"
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/pkg/compression"
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	// label of negotiated control channel (id 0)
+	controlChannelLabel = "rapid-control"
+	// prefix of per-transfer channels, full label is prefix + transfer id
+	fileChannelPrefix = "rapid-file:"
+
+	// 8 bytes of big endian offset before every chunk
+	chunkHeaderSize = 8
+	// 16 KiB is the safe message size for all sctp implementations
+	chunkSize = 16 * 1024
+	// compressed chunk carries up to this many file bytes, it is sent
+	// only if it fits into chunkSize after compression
+	packedChunkSize = 64 * 1024
+	// set in offset of compressed chunks, offsets always count file bytes
+	compressedFlag = 1 << 63
+
+	maxBufferedAmount       = 1024 * 1024
+	bufferedAmountThreshold = 256 * 1024
+
+	// receiver acknowledges progress after every ackInterval bytes
+	ackInterval = 1024 * 1024
+	// sender doesn't run ahead of acknowledged offset by more, receiver
+	// acknowledges written bytes, so its download limits pace the sender
+	maxInFlight = 8 * ackInterval
+	// received chunks wait for download limits here, every chunk but
+	// the last one carries at least chunkSize bytes of the file
+	maxQueuedChunks = maxInFlight/chunkSize + 16
+)
+
+// Control message types
+const (
+	msgApp             = "app"
+	msgFiles           = "files"
+	msgTransferRequest = "transfer_request"
+	msgTransferAck     = "transfer_ack"
+	msgTransferDone    = "transfer_done"
+	msgTransferCancel  = "transfer_cancel"
+)
+
+var (
+	ErrTransferNotFound = errors.New("transfer not found")
+	ErrFileNotShared    = errors.New("file is not shared")
+	ErrTransferAborted  = errors.New("transfer aborted")
+	ErrQueueOverflow    = errors.New("peer sent more than acknowledged window")
+)
+
+// ChannelProfile describes delivery guarantees of a data channel
+type ChannelProfile int
+
+const (
+	// ordered and reliable, used for control messages
+	ChannelReliable ChannelProfile = iota
+	// reliable but unordered, chunks carry their own offsets
+	ChannelUnordered
+)
+
+func (p ChannelProfile) dataChannelInit() *webrtc.DataChannelInit {
+	switch p {
+	case ChannelUnordered:
+		return &webrtc.DataChannelInit{
+			Ordered: BoolToPtr(false),
+		}
+	default:
+		return &webrtc.DataChannelInit{
+			Ordered: BoolToPtr(true),
+		}
+	}
+}
+
+type controlMessage struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type transferRequest struct {
+	TransferID string         `json:"transfer_id"`
+	FileID     string         `json:"file_id"`
+	Profile    ChannelProfile `json:"profile"`
+	// non zero when resuming after reconnect
+	Offset int64 `json:"offset,omitempty"`
+	// receiver can decode chunks compressed with it, sender decides per chunk
+	Encoding compression.Encoding `json:"encoding,omitempty"`
+}
+
+type transferAck struct {
+	TransferID string `json:"transfer_id"`
+	Offset     int64  `json:"offset"`
+}
+
+type transferRef struct {
+	TransferID string `json:"transfer_id"`
+}
+
+type TransferDirection int
+
+const (
+	TransferUpload TransferDirection = iota
+	TransferDownload
+)
+
+// Transfer is a single file transfer with its own data channel
+type Transfer struct {
+	ID        string
+	File      model.File
+	Direction TransferDirection
+	Profile   ChannelProfile
+
+	dc   *webrtc.DataChannel
+	out  *os.File
+	path string
+	// Identity or zstd, see transferRequest
+	encoding compression.Encoding
+	// internal transfers don't trigger manager callbacks
+	silent bool
+	// releases bandwidth tracking, set when transfer is added
+	release func()
+	// nil after successful finish
+	err error
+
+	// download: bytes written without gaps, chunks after a gap
+	// are kept in pending until the gap is filled
+	watermark int64
+	pending   map[int64]int64
+	lastAck   int64
+	suspended bool
+	// upload: offset acknowledged by receiver, ackCh is signaled on acks
+	acked atomic.Int64
+	ackCh chan struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+}
+
+// Received returns bytes that are written without gaps
+func (t *Transfer) Received() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.watermark
+}
+
+// Acked returns bytes acknowledged by the receiver
+func (t *Transfer) Acked() int64 {
+	return t.acked.Load()
+}
+
+// advance marks chunk as written, returns new watermark
+// and whether the receiver should acknowledge it
+func (t *Transfer) advance(offset, n int64) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case offset+n <= t.watermark:
+		// duplicate after resume
+	case offset <= t.watermark:
+		t.watermark = offset + n
+	default:
+		t.pending[offset] = n
+	}
+
+	for {
+		n, ok := t.pending[t.watermark]
+		if !ok {
+			break
+		}
+		delete(t.pending, t.watermark)
+		t.watermark += n
+	}
+
+	ack := t.watermark-t.lastAck >= ackInterval
+	if ack {
+		t.lastAck = t.watermark
+	}
+	return t.watermark, ack
+}
+
+// Wait blocks until the transfer is finished, failed or aborted
+func (t *Transfer) Wait(ctx context.Context) error {
+	select {
+	case <-t.done:
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// context is canceled when the transfer is closed
+func (t *Transfer) context() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-t.done
+		cancel()
+	}()
+	return ctx
+}
+
+func (t *Transfer) setErr(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+func (t *Transfer) close() {
+	t.closeOnce.Do(func() {
+		close(t.done)
+
+		t.mu.Lock()
+		dc := t.dc
+		t.mu.Unlock()
+
+		if dc != nil {
+			_ = dc.Close()
+		}
+		if t.out != nil {
+			_ = t.out.Close()
+		}
+	})
+}
+
+// TransferManager owns per-transfer data channels of one P2P connection
+type TransferManager struct {
+	state *P2PConnectionState
+
+	dir       string
+	transfers map[string]*Transfer
+	// files served only on request, not announced, see p2p_mirror.go
+	exports map[string]model.File
+	// nil means unlimited, peer is the session name
+	bandwidth *bandwidth.Manager
+	peer      string
+	// offer compressed chunks for our downloads
+	compression bool
+
+	catalog    func() []model.File
+	onFiles    func([]model.File)
+	onComplete func(*Transfer)
+	onError    func(*Transfer, error)
+
+	mu sync.Mutex
+}
+
+func NewTransferManager(state *P2PConnectionState) *TransferManager {
+	return &TransferManager{
+		state:     state,
+		dir:       ".",
+		transfers: make(map[string]*Transfer),
+		exports:   make(map[string]model.File),
+
+		compression: true,
+
+		catalog:    func() []model.File { return nil },
+		onFiles:    func([]model.File) {},
+		onComplete: func(*Transfer) {},
+		onError:    func(*Transfer, error) {},
+	}
+}
+
+func (m *TransferManager) SetDownloadsDir(dir string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dir = dir
+}
+
+// SetBandwidth limits data channels of the transfers by limits of the peer
+func (m *TransferManager) SetBandwidth(bw *bandwidth.Manager, peer string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bandwidth = bw
+	m.peer = peer
+}
+
+// wait blocks until n bytes of the transfer may be sent or received
+func (m *TransferManager) wait(ctx context.Context, t *Transfer, n int) error {
+	m.mu.Lock()
+	bw, stream := m.bandwidth, m.stream(t)
+	m.mu.Unlock()
+
+	return bw.Wait(ctx, n, stream)
+}
+
+// stream describes the transfer for bandwidth limits, must be called with m.mu held
+func (m *TransferManager) stream(t *Transfer) bandwidth.Stream {
+	dir := bandwidth.Upload
+	if t.Direction == TransferDownload {
+		dir = bandwidth.Download
+	}
+	return bandwidth.Stream{
+		Direction: dir,
+		Peer:      m.peer,
+		Transfer:  t.ID,
+		Name:      t.File.Name,
+	}
+}
+
+// SetCompression offers compressed chunks to the peer for new downloads,
+// the peer sends already compressed formats as is
+func (m *TransferManager) SetCompression(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.compression = enabled
+}
+
+// SetCatalog sets source of files that we share with the peer
+func (m *TransferManager) SetCatalog(catalog func() []model.File) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.catalog = catalog
+}
+
+func (m *TransferManager) SetCallbacks(
+	onFiles func([]model.File),
+	onComplete func(*Transfer),
+	onError func(*Transfer, error),
+) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onFiles = onFiles
+	m.onComplete = onComplete
+	m.onError = onError
+}
+
+// Announce sends list of our shared files to the peer
+func (m *TransferManager) Announce() error {
+	m.mu.Lock()
+	files := m.catalog()
+	m.mu.Unlock()
+
+	// local paths are not sent
+	return m.state.sendControl(msgFiles, model.Infos(files))
+}
+
+// Export allows the peer to request the file without announcing it
+func (m *TransferManager) Export(file model.File) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exports[file.ID] = file
+}
+
+func (m *TransferManager) Unexport(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.exports, id)
+}
+
+// Request asks the peer to open a channel and send the file
+func (m *TransferManager) Request(file model.File) (*Transfer, error) {
+	m.mu.Lock()
+	path := filepath.Join(m.dir, filepath.Base(file.Name))
+	m.mu.Unlock()
+
+	return m.request(file, path, false)
+}
+
+// pull downloads the file to path without callbacks, caller waits for it
+func (m *TransferManager) pull(file model.File, path string) (*Transfer, error) {
+	return m.request(file, path, true)
+}
+
+func (m *TransferManager) request(file model.File, path string, silent bool) (*Transfer, error) {
+	if err := file.Validate(); err != nil {
+		return nil, err
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := compression.Identity
+	m.mu.Lock()
+	if m.compression {
+		encoding = compression.Zstd
+	}
+	m.mu.Unlock()
+
+	t := &Transfer{
+		ID:        uuid.NewString(),
+		File:      file,
+		Direction: TransferDownload,
+		Profile:   ChannelUnordered,
+		out:       out,
+		path:      path,
+		encoding:  encoding,
+		silent:    silent,
+		pending:   make(map[int64]int64),
+		done:      make(chan struct{}),
+	}
+
+	m.add(t)
+
+	err = m.state.sendControl(msgTransferRequest, transferRequest{
+		TransferID: t.ID,
+		FileID:     file.ID,
+		Profile:    t.Profile,
+		Encoding:   t.encoding,
+	})
+	if err != nil {
+		m.remove(t)
+		t.close()
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Cancel stops the transfer on both sides and closes its channel
+func (m *TransferManager) Cancel(id string) error {
+	t := m.get(id)
+	if t == nil {
+		return ErrTransferNotFound
+	}
+
+	err := m.state.sendControl(msgTransferCancel, transferRef{TransferID: id})
+	m.abort(t)
+	return err
+}
+
+func (m *TransferManager) Transfers() []*Transfer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]*Transfer, 0, len(m.transfers))
+	for _, t := range m.transfers {
+		result = append(result, t)
+	}
+	return result
+}
+
+// Resume re-requests downloads whose channels were lost with the connection,
+// sender continues from the last acknowledged offset
+func (m *TransferManager) Resume() {
+	for _, t := range m.Transfers() {
+		if t.Direction != TransferDownload {
+			continue
+		}
+
+		t.mu.Lock()
+		suspended := t.suspended
+		t.mu.Unlock()
+
+		if suspended {
+			m.resume(t)
+		}
+	}
+}
+
+func (m *TransferManager) resume(t *Transfer) {
+	t.mu.Lock()
+	t.suspended = false
+	offset := t.lastAck
+	t.mu.Unlock()
+
+	err := m.state.sendControl(msgTransferRequest, transferRequest{
+		TransferID: t.ID,
+		FileID:     t.File.ID,
+		Profile:    t.Profile,
+		Offset:     offset,
+		Encoding:   t.encoding,
+	})
+	if err != nil {
+		log.Println("Failed to resume transfer:", err)
+		m.suspend(t)
+	}
+}
+
+// suspend keeps the download and its partial file until connection is back
+func (m *TransferManager) suspend(t *Transfer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.suspended = true
+	t.dc = nil
+	// chunks after the gap may be lost with the channel
+	t.watermark = t.lastAck
+	clear(t.pending)
+}
+
+// CloseAll aborts every active transfer, used when connection is closed
+func (m *TransferManager) CloseAll() {
+	m.mu.Lock()
+	transfers := m.transfers
+	m.transfers = make(map[string]*Transfer)
+	m.mu.Unlock()
+
+	for _, t := range transfers {
+		t.release()
+		t.setErr(ErrTransferAborted)
+		m.cleanup(t)
+	}
+}
+
+func (m *TransferManager) handleControl(msg controlMessage) {
+	switch msg.Type {
+	case msgFiles:
+		var infos []model.FileInfo
+		if err := json.Unmarshal(msg.Payload, &infos); err != nil {
+			log.Println("Invalid files message:", err)
+			return
+		}
+		m.mu.Lock()
+		onFiles := m.onFiles
+		m.mu.Unlock()
+		onFiles(model.Files(infos))
+	case msgTransferRequest:
+		var req transferRequest
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			log.Println("Invalid transfer request:", err)
+			return
+		}
+		if err := m.serve(req); err != nil {
+			log.Println("Failed to serve transfer:", err)
+			_ = m.state.sendControl(msgTransferCancel, transferRef{TransferID: req.TransferID})
+		}
+	case msgTransferAck:
+		var ack transferAck
+		if err := json.Unmarshal(msg.Payload, &ack); err != nil {
+			return
+		}
+		if t := m.get(ack.TransferID); t != nil && t.Direction == TransferUpload {
+			t.acked.Store(ack.Offset)
+			select {
+			case t.ackCh <- struct{}{}:
+			default:
+			}
+		}
+	case msgTransferDone:
+		var ref transferRef
+		if err := json.Unmarshal(msg.Payload, &ref); err != nil {
+			return
+		}
+		if t := m.get(ref.TransferID); t != nil {
+			m.remove(t)
+			t.close()
+		}
+	case msgTransferCancel:
+		var ref transferRef
+		if err := json.Unmarshal(msg.Payload, &ref); err != nil {
+			return
+		}
+		if t := m.get(ref.TransferID); t != nil {
+			m.abort(t)
+		}
+	}
+}
+
+// handleChannel is called for every channel opened by the peer
+func (m *TransferManager) handleChannel(dc *webrtc.DataChannel) {
+	if !m.state.authenticated.Load() {
+		log.Println("Rejecting data channel from unauthenticated peer")
+		_ = dc.Close()
+		return
+	}
+
+	id, ok := strings.CutPrefix(dc.Label(), fileChannelPrefix)
+	if !ok {
+		log.Println("Unknown data channel:", dc.Label())
+		_ = dc.Close()
+		return
+	}
+
+	t := m.get(id)
+	if t == nil || t.Direction != TransferDownload {
+		_ = dc.Close()
+		return
+	}
+
+	t.mu.Lock()
+	t.dc = dc
+	t.mu.Unlock()
+
+	dc.OnOpen(func() {
+		if t.File.Size == 0 {
+			m.finish(t)
+		}
+	})
+
+	dc.OnClose(func() {
+		if m.get(t.ID) == nil {
+			return
+		}
+
+		t.mu.Lock()
+		current := t.dc == dc
+		t.mu.Unlock()
+		if !current {
+			return
+		}
+
+		m.suspend(t)
+		// channel is lost but connection is alive, no need to wait
+		if m.state.isConnected.Load() {
+			m.resume(t)
+		}
+	})
+
+	// blocking in OnMessage would stall the whole sctp association,
+	// so chunks are written by another goroutine
+	queue := make(chan []byte, maxQueuedChunks)
+	go m.receive(t, queue)
+
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		if len(msg.Data) < chunkHeaderSize {
+			return
+		}
+		select {
+		case queue <- msg.Data:
+		default:
+			m.fail(t, ErrQueueOverflow)
+		}
+	})
+}
+
+// receive writes queued chunks within download limits until transfer is closed
+func (m *TransferManager) receive(t *Transfer, queue <-chan []byte) {
+	ctx := t.context()
+	for {
+		var chunk []byte
+		select {
+		case chunk = <-queue:
+		case <-ctx.Done():
+			return
+		}
+
+		// fails only when transfer is closed, the rest of it doesn't matter
+		if err := m.wait(ctx, t, len(chunk)); err != nil {
+			return
+		}
+		if err := m.writeChunk(t, chunk); err != nil {
+			m.fail(t, err)
+			return
+		}
+	}
+}
+
+func (m *TransferManager) writeChunk(t *Transfer, chunk []byte) error {
+	header := binary.BigEndian.Uint64(chunk[:chunkHeaderSize])
+	offset := int64(header &^ compressedFlag)
+	data := chunk[chunkHeaderSize:]
+	if header&compressedFlag != 0 {
+		if t.encoding == compression.Identity {
+			return compression.ErrUnsupported
+		}
+		decoded, err := compression.Decode(data, t.encoding, packedChunkSize)
+		if err != nil {
+			return err
+		}
+		data = decoded
+	}
+	if offset < 0 || offset > t.File.Size-int64(len(data)) {
+		return fmt.Errorf("chunk at %d is out of file", offset)
+	}
+
+	if _, err := t.out.WriteAt(data, offset); err != nil {
+		return err
+	}
+
+	watermark, ack := t.advance(offset, int64(len(data)))
+	if watermark >= t.File.Size {
+		m.finish(t)
+		return nil
+	}
+	if ack {
+		_ = m.state.sendControl(msgTransferAck, transferAck{TransferID: t.ID, Offset: watermark})
+	}
+	return nil
+}
+
+// sharedFile finds the file the peer is allowed to request
+func (m *TransferManager) sharedFile(id string) (model.File, bool) {
+	m.mu.Lock()
+	files := m.catalog()
+	file, found := m.exports[id]
+	m.mu.Unlock()
+
+	for _, f := range files {
+		if !found && f.ID == id {
+			return f, true
+		}
+	}
+	return file, found
+}
+
+func (m *TransferManager) serve(req transferRequest) error {
+	file, found := m.sharedFile(req.FileID)
+	if !found {
+		return ErrFileNotShared
+	}
+	if req.Offset < 0 || req.Offset > file.Size {
+		return fmt.Errorf("invalid offset %d", req.Offset)
+	}
+	if req.Profile != ChannelReliable && req.Profile != ChannelUnordered {
+		return fmt.Errorf("unknown channel profile %d", req.Profile)
+	}
+
+	// resumed transfer replaces the old one with lost channel
+	if old := m.get(req.TransferID); old != nil {
+		m.remove(old)
+		old.close()
+	}
+
+	dc, err := m.state.openChannel(fileChannelPrefix+req.TransferID, req.Profile)
+	if err != nil {
+		return err
+	}
+
+	t := &Transfer{
+		ID:        req.TransferID,
+		File:      file,
+		Direction: TransferUpload,
+		Profile:   req.Profile,
+		dc:        dc,
+		path:      file.Path,
+		ackCh:     make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	t.acked.Store(req.Offset)
+	// already compressed formats are sent as is
+	if req.Encoding == compression.Zstd && compression.CompressibleFile(file.Name, file.Path) {
+		t.encoding = compression.Zstd
+	}
+
+	m.add(t)
+
+	dc.OnOpen(func() {
+		go m.upload(t)
+	})
+
+	return nil
+}
+
+func (m *TransferManager) upload(t *Transfer) {
+	f, err := os.Open(t.File.Path)
+	if err != nil {
+		m.fail(t, err)
+		return
+	}
+	defer f.Close()
+
+	low := make(chan struct{}, 1)
+	t.dc.SetBufferedAmountLowThreshold(bufferedAmountThreshold)
+	t.dc.OnBufferedAmountLow(func() {
+		select {
+		case low <- struct{}{}:
+		default:
+		}
+	})
+
+	ctx := t.context()
+	offset := t.acked.Load()
+	for {
+		for offset-t.acked.Load() >= maxInFlight {
+			select {
+			case <-t.ackCh:
+			case <-t.done:
+				return
+			}
+		}
+
+		if t.dc.BufferedAmount() > maxBufferedAmount {
+			select {
+			case <-low:
+			case <-t.done:
+				return
+			}
+		}
+
+		select {
+		case <-t.done:
+			return
+		default:
+		}
+
+		chunk, n, err := t.nextChunk(f, offset)
+		if n > 0 {
+			if err := m.wait(ctx, t, len(chunk)); err != nil {
+				return
+			}
+			if err := t.dc.Send(chunk); err != nil {
+				// receiver resumes it after reconnect or when it closed the channel
+				if !m.state.isConnected.Load() || t.dc.ReadyState() != webrtc.DataChannelStateOpen {
+					m.abort(t)
+					return
+				}
+				m.fail(t, err)
+				return
+			}
+			offset += int64(n)
+		}
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			m.fail(t, err)
+			return
+		}
+	}
+}
+
+// nextChunk reads message with file bytes at offset, n is how many
+// file bytes it carries. Chunks that don't shrink are sent as is.
+func (t *Transfer) nextChunk(f *os.File, offset int64) ([]byte, int, error) {
+	if t.encoding == compression.Identity {
+		chunk := make([]byte, chunkHeaderSize+chunkSize)
+		n, err := f.ReadAt(chunk[chunkHeaderSize:], offset)
+		binary.BigEndian.PutUint64(chunk[:chunkHeaderSize], uint64(offset))
+		return chunk[:chunkHeaderSize+n], n, err
+	}
+
+	data := make([]byte, packedChunkSize)
+	n, err := f.ReadAt(data, offset)
+	if n == 0 {
+		return nil, 0, err
+	}
+	data = data[:n]
+
+	header := uint64(offset)
+	packed, packErr := compression.Encode(data, t.encoding)
+	if packErr == nil && len(packed) <= chunkSize && len(packed) < n {
+		data = packed
+		header |= compressedFlag
+	} else if n > chunkSize {
+		// the rest is read again by the next chunk
+		data, n, err = data[:chunkSize], chunkSize, nil
+	}
+
+	chunk := make([]byte, chunkHeaderSize+len(data))
+	binary.BigEndian.PutUint64(chunk[:chunkHeaderSize], header)
+	copy(chunk[chunkHeaderSize:], data)
+	return chunk, n, err
+}
+
+func (m *TransferManager) finish(t *Transfer) {
+	if m.remove(t) == nil {
+		return
+	}
+
+	_ = m.state.sendControl(msgTransferDone, transferRef{TransferID: t.ID})
+	t.close()
+	if t.Direction == TransferDownload {
+		if err := restoreMetadata(t.path, t.File); err != nil {
+			log.Println("Failed to restore file metadata:", err)
+		}
+	}
+	if t.silent {
+		return
+	}
+
+	m.mu.Lock()
+	onComplete := m.onComplete
+	m.mu.Unlock()
+	onComplete(t)
+}
+
+func (m *TransferManager) fail(t *Transfer, err error) {
+	if m.remove(t) == nil {
+		return
+	}
+
+	err = fmt.Errorf("transfer %s failed: %w", t.File.Name, err)
+	t.setErr(err)
+
+	_ = m.state.sendControl(msgTransferCancel, transferRef{TransferID: t.ID})
+	m.cleanup(t)
+	if t.silent {
+		return
+	}
+
+	m.mu.Lock()
+	onError := m.onError
+	m.mu.Unlock()
+	onError(t, err)
+}
+
+func (m *TransferManager) abort(t *Transfer) {
+	if m.remove(t) == nil {
+		return
+	}
+	t.setErr(ErrTransferAborted)
+	m.cleanup(t)
+}
+
+// cleanup closes channel and removes incomplete download
+func (m *TransferManager) cleanup(t *Transfer) {
+	t.close()
+	if t.Direction == TransferDownload {
+		_ = os.Remove(t.path)
+	}
+}
+
+// add registers the transfer, it can be limited on its own
+// in the Options tab until it's removed
+func (m *TransferManager) add(t *Transfer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t.release = m.bandwidth.Track(m.stream(t))
+	m.transfers[t.ID] = t
+}
+
+func (m *TransferManager) get(id string) *Transfer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.transfers[id]
+}
+
+// remove returns nil if transfer was already removed or replaced
+// by resumed one with the same id
+func (m *TransferManager) remove(t *Transfer) *Transfer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if current, ok := m.transfers[t.ID]; !ok || current != t {
+		return nil
+	}
+	delete(m.transfers, t.ID)
+	t.release()
+	return t
+}
@@ -0,0 +1,335 @@
+package controller
+
+import (
+	"bytes"
+	"image"
+	"log"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/model"
+	"github.com/0x0FACED/rapid/pkg/thumbnail"
+)
+
+const (
+	// previews are loaded once in pane size, list rows scale them down
+	previewSize     = 256
+	previewIconSize = 32
+	// peers generate previews, don't ask for the whole list at once
+	maxPreviewLoads = 4
+)
+
+type preview struct {
+	kind  thumbnail.Kind
+	image image.Image
+	text  string
+}
+
+// PreviewCache loads previews of peer files in background and keeps them
+// until the file changes
+type PreviewCache struct {
+	load    func(model.File) (thumbnail.Thumbnail, error)
+	onLoad  func(model.File)
+	entries map[string]*preview
+	loads   chan struct{}
+
+	mu sync.Mutex
+}
+
+func NewPreviewCache(load func(model.File) (thumbnail.Thumbnail, error)) *PreviewCache {
+	return &PreviewCache{
+		load:    load,
+		onLoad:  func(model.File) {},
+		entries: make(map[string]*preview),
+		loads:   make(chan struct{}, maxPreviewLoads),
+	}
+}
+
+func (c *PreviewCache) SetOnLoad(onLoad func(model.File)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onLoad = onLoad
+}
+
+// get returns cached preview, preview that is not loaded yet is requested
+// and reported to onLoad when ready
+func (c *PreviewCache) get(file model.File) preview {
+	key := previewKey(file)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if p, ok := c.entries[key]; ok {
+		return *p
+	}
+	c.entries[key] = &preview{}
+	go c.fetch(key, file)
+	return preview{}
+}
+
+func (c *PreviewCache) fetch(key string, file model.File) {
+	c.loads <- struct{}{}
+	thumb, err := c.load(file)
+	<-c.loads
+
+	p := preview{kind: thumbnail.KindNone}
+	if err != nil {
+		log.Printf("Failed to load preview of %s: %v", file.Name, err)
+	} else {
+		p = decodePreview(thumb)
+	}
+
+	c.mu.Lock()
+	c.entries[key] = &p
+	onLoad := c.onLoad
+	c.mu.Unlock()
+
+	onLoad(file)
+}
+
+func decodePreview(thumb thumbnail.Thumbnail) preview {
+	p := preview{kind: thumb.Kind}
+	switch thumb.Kind {
+	case thumbnail.KindImage:
+		img, _, err := image.Decode(bytes.NewReader(thumb.Data))
+		if err != nil {
+			p.kind = thumbnail.KindNone
+			break
+		}
+		p.image = img
+	case thumbnail.KindText:
+		p.text = string(thumb.Data)
+	default:
+		p.kind = thumbnail.KindNone
+	}
+	return p
+}
+
+// previewKey changes when the peer updates file
+func previewKey(file model.File) string {
+	return file.ID + "@" + file.ModTime.String()
+}
+
+// fileIcon is generic icon for files without image preview
+func fileIcon(file model.File) fyne.Resource {
+	switch {
+	case strings.HasPrefix(file.MIMEType, "image/"):
+		return theme.FileImageIcon()
+	case strings.HasPrefix(file.MIMEType, "text/"):
+		return theme.FileTextIcon()
+	case strings.HasPrefix(file.MIMEType, "video/"):
+		return theme.FileVideoIcon()
+	case strings.HasPrefix(file.MIMEType, "audio/"):
+		return theme.FileAudioIcon()
+	case file.MIMEType == "application/pdf":
+		return theme.DocumentIcon()
+	}
+	return theme.FileIcon()
+}
+
+// setPreviewImage shows image preview or generic icon of the file
+func setPreviewImage(img *canvas.Image, file model.File, p preview) {
+	if p.image != nil {
+		img.Resource = nil
+		img.Image = p.image
+	} else {
+		img.Image = nil
+		img.Resource = fileIcon(file)
+	}
+	img.Refresh()
+}
+
+// FileRow is row of files list with preview icon,
+// hovering it shows the file in preview pane
+type FileRow struct {
+	widget.BaseWidget
+	icon *canvas.Image
+	name *widget.Label
+	size *widget.Label
+
+	onHover func()
+}
+
+func NewFileRow() *FileRow {
+	icon := canvas.NewImageFromResource(theme.FileIcon())
+	icon.FillMode = canvas.ImageFillContain
+	icon.SetMinSize(fyne.NewSquareSize(previewIconSize))
+
+	row := &FileRow{
+		icon:    icon,
+		name:    widget.NewLabel(""),
+		size:    widget.NewLabel(""),
+		onHover: func() {},
+	}
+	row.ExtendBaseWidget(row)
+	return row
+}
+
+func (r *FileRow) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewBorder(
+		nil,
+		nil,
+		container.NewHBox(r.icon, r.name),
+		r.size,
+		nil,
+	))
+}
+
+// Update shows the file, preview is requested from cache when
+// previews are set, otherwise row has generic icon
+func (r *FileRow) Update(file model.File, previews *PreviewCache, onHover func()) {
+	r.setHeader(false)
+	r.name.SetText(file.Name)
+	r.size.SetText(file.SizeString())
+
+	var p preview
+	if previews != nil {
+		p = previews.get(file)
+	}
+	setPreviewImage(r.icon, file, p)
+	r.icon.Show()
+
+	if onHover == nil {
+		onHover = func() {}
+	}
+	r.onHover = onHover
+}
+
+// ShowHeader turns the row into title of file group
+func (r *FileRow) ShowHeader(title string) {
+	r.setHeader(true)
+	r.name.SetText(title)
+	r.size.SetText("")
+	r.icon.Hide()
+	r.onHover = func() {}
+}
+
+func (r *FileRow) setHeader(header bool) {
+	if r.name.TextStyle.Bold != header {
+		r.name.TextStyle.Bold = header
+		r.name.Refresh()
+	}
+}
+
+func (r *FileRow) MouseIn(*desktop.MouseEvent) {
+	r.onHover()
+}
+
+func (r *FileRow) MouseMoved(*desktop.MouseEvent) {}
+
+func (r *FileRow) MouseOut() {}
+
+// PreviewPane shows preview and details of hovered or selected peer file
+type PreviewPane struct {
+	previews *PreviewCache
+	file     *model.File
+
+	image   *canvas.Image
+	text    *widget.Label
+	name    *widget.Label
+	details *widget.Label
+	content fyne.CanvasObject
+
+	mu sync.Mutex
+}
+
+func NewPreviewPane(previews *PreviewCache) *PreviewPane {
+	image := canvas.NewImageFromResource(nil)
+	image.FillMode = canvas.ImageFillContain
+	image.SetMinSize(fyne.NewSquareSize(previewSize / 2))
+
+	text := widget.NewLabel("")
+	text.TextStyle = fyne.TextStyle{Monospace: true}
+	text.Wrapping = fyne.TextWrapBreak
+
+	name := widget.NewLabelWithStyle("Hover a file to preview it", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	name.Wrapping = fyne.TextWrapWord
+
+	p := &PreviewPane{
+		previews: previews,
+		image:    image,
+		text:     text,
+		name:     name,
+		details:  widget.NewLabel(""),
+	}
+	p.content = container.NewBorder(
+		name,
+		p.details,
+		nil,
+		nil,
+		container.NewStack(image, container.NewVScroll(text)),
+	)
+	p.image.Hide()
+	p.text.Hide()
+	return p
+}
+
+func (p *PreviewPane) Content() fyne.CanvasObject {
+	return p.content
+}
+
+// Show displays the file, preview is shown when it's loaded
+func (p *PreviewPane) Show(file model.File) {
+	p.mu.Lock()
+	p.file = &file
+	p.mu.Unlock()
+
+	p.name.SetText(file.Name)
+	p.details.SetText(fileDetails(file))
+	p.update(file)
+}
+
+// Refresh updates the pane after preview of the file is loaded
+func (p *PreviewPane) Refresh(file model.File) {
+	p.mu.Lock()
+	current := p.file
+	p.mu.Unlock()
+
+	if current == nil || previewKey(*current) != previewKey(file) {
+		return
+	}
+	p.update(file)
+}
+
+func (p *PreviewPane) Clear() {
+	p.mu.Lock()
+	p.file = nil
+	p.mu.Unlock()
+
+	p.name.SetText("Hover a file to preview it")
+	p.details.SetText("")
+	p.image.Hide()
+	p.text.Hide()
+}
+
+func (p *PreviewPane) update(file model.File) {
+	preview := p.previews.get(file)
+
+	if preview.kind == thumbnail.KindText {
+		p.image.Hide()
+		p.text.SetText(preview.text)
+		p.text.Show()
+		return
+	}
+
+	p.text.Hide()
+	setPreviewImage(p.image, file, preview)
+	p.image.Show()
+}
+
+func fileDetails(file model.File) string {
+	lines := []string{"Size: " + file.SizeString()}
+	if file.MIMEType != "" {
+		lines = append(lines, "Type: "+file.MIMEType)
+	}
+	if !file.ModTime.IsZero() {
+		lines = append(lines, "Modified: "+file.ModTime.Local().Format("2006-01-02 15:04"))
+	}
+	return strings.Join(lines, "\n")
+}
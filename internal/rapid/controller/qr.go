@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"github.com/0x0FACED/rapid/internal/p2p/wire"
+	"github.com/skip2/go-qrcode"
+)
+
+const qrFrameInterval = 700 * time.Millisecond
+
+// QRView shows payload as QR code, payload that doesn't fit
+// into one readable code is animated over several frames
+type QRView struct {
+	Image *canvas.Image
+
+	frames  [][]byte
+	current int
+	stop    chan struct{}
+	mu      sync.Mutex
+}
+
+func NewQRView() *QRView {
+	image := canvas.NewImageFromResource(nil)
+	image.FillMode = canvas.ImageFillContain
+	image.SetMinSize(fyne.NewSquareSize(100))
+
+	return &QRView{Image: image}
+}
+
+func (v *QRView) Show(payload string) error {
+	parts := wire.SplitFrames(payload, wire.MaxFrameSize)
+	if len(parts) > wire.MaxFrames {
+		return errors.New("payload is too large for QR code")
+	}
+
+	frames := make([][]byte, 0, len(parts))
+	for _, part := range parts {
+		qr, err := qrcode.New(part, qrcode.Medium)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := qr.Write(400, &buf); err != nil {
+			return err
+		}
+		frames = append(frames, buf.Bytes())
+	}
+
+	v.mu.Lock()
+	if v.stop != nil {
+		close(v.stop)
+		v.stop = nil
+	}
+	v.frames = frames
+	v.current = 0
+	if len(frames) > 1 {
+		v.stop = make(chan struct{})
+		go v.animate(v.stop)
+	}
+	v.mu.Unlock()
+
+	v.render()
+	return nil
+}
+
+// Current returns PNG of the frame on screen
+func (v *QRView) Current() []byte {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.frames) == 0 {
+		return nil
+	}
+	return v.frames[v.current]
+}
+
+// FrameCount returns number of frames of the shown payload
+func (v *QRView) FrameCount() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.frames)
+}
+
+func (v *QRView) animate(stop chan struct{}) {
+	ticker := time.NewTicker(qrFrameInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			v.mu.Lock()
+			v.current = (v.current + 1) % len(v.frames)
+			v.mu.Unlock()
+			v.render()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (v *QRView) render() {
+	frame := v.Current()
+	if frame == nil {
+		return
+	}
+
+	v.Image.Resource = fyne.NewStaticResource("qr.png", frame)
+	v.Image.Refresh()
+}
@@ -45,6 +45,19 @@ func (s *ServerState) Remove(key string) {
 	delete(s.Instances, key)
 }
 
+// HasAddress reports whether server with the address (ip:port) is online
+func (s *ServerState) HasAddress(addr string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, instance := range s.Instances {
+		if instance.Address() == addr {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *ServerState) GetAll() []model.ServiceInstance {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -8,6 +8,7 @@ import (
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/model"
 )
 
 func (lc *LANController) showFilePicker(w fyne.Window) {
@@ -39,11 +40,25 @@ func (lc *LANController) handleFileSelection(path string) error {
 		return fmt.Errorf("failed to share file: %w", err)
 	}
 
-	lc.sharedFiles.Add(file.ID, file)
-	lc.sharedList.Refresh()
+	lc.addShared(file)
 	return nil
 }
 
+// addShared adds new or updated file to shared list
+func (lc *LANController) addShared(file model.File) {
+	lc.sharedFiles.Add(file.ID, file)
+	if lc.sharedList != nil {
+		lc.sharedList.Refresh()
+	}
+}
+
+func (lc *LANController) removeShared(id string) {
+	lc.sharedFiles.Remove(id)
+	if lc.sharedList != nil {
+		lc.sharedList.Refresh()
+	}
+}
+
 func (lc *LANController) CreateLANTopPanel(window fyne.Window) fyne.CanvasObject {
 	fileDialogButton := widget.NewButton("Choose File", func() {
 		lc.showFilePicker(window)
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"net/url"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/lan/server"
+)
+
+// CreateWebAccessOptions shows address of the browser interface as QR code
+// with login token and PIN for typing the address by hand
+func CreateWebAccessOptions(w fyne.Window, s *server.LANServer) fyne.CanvasObject {
+	urls := s.WebURLs()
+	if len(urls) == 0 {
+		return widget.NewLabel("No local network address found.")
+	}
+
+	qr := NewQRView()
+	qr.Image.SetMinSize(fyne.NewSquareSize(200))
+	pin := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true, Monospace: true})
+
+	address := widget.NewSelect(urls, nil)
+
+	update := func() {
+		token, code := s.WebAccess()
+		pin.SetText("PIN: " + code)
+
+		if address.Selected == "" {
+			return
+		}
+		link := address.Selected + "?token=" + url.QueryEscape(token)
+		if err := qr.Show(link); err != nil {
+			dialog.ShowError(err, w)
+		}
+	}
+	address.OnChanged = func(string) { update() }
+
+	reset := widget.NewButton("New PIN and QR code", func() {
+		s.ResetWebAccess()
+		update()
+	})
+
+	address.SetSelectedIndex(0)
+
+	return container.NewVBox(
+		widget.NewLabel("Open the address on a phone or laptop, or scan the code to log in without PIN."),
+		address,
+		container.NewCenter(qr.Image),
+		pin,
+		widget.NewLabel("Uploads from browsers are saved to the \"browser\" folder of downloads."),
+		reset,
+	)
+}
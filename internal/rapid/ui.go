@@ -2,7 +2,9 @@ package rapid
 
 import (
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/widget"
+	"github.com/0x0FACED/rapid/internal/rapid/controller"
 )
 
 // TODO: move
@@ -12,9 +14,16 @@ func (a *Rapid) createWebRTCContent() fyne.CanvasObject {
 	return widget.NewLabel("test webrtc")
 }
 
-// TODO: impl
-func createOptionsContent() fyne.CanvasObject {
-	return widget.NewLabel("Options Content")
+func (a *Rapid) createOptionsContent(w fyne.Window) fyne.CanvasObject {
+	return container.NewVScroll(container.NewVBox(
+		widget.NewCard("WebRTC", "Connection settings", a.netController.CreateOptionsContent(w)),
+		widget.NewCard("Shared folder", "Watched folder", a.folderShare.CreateOptionsContent(w)),
+		widget.NewCard("Folder sync", "One-way mirror to a peer", a.folderSync.CreateOptionsContent(w)),
+		widget.NewCard("Bandwidth", "Transfer speed limits", controller.CreateBandwidthOptions(w, a.lan.Bandwidth())),
+		widget.NewCard("Compression", "Transfer compression", controller.CreateCompressionOptions(a.client, a.netController.Sessions())),
+		widget.NewCard("Browser access", "Web interface for devices without rapid", controller.CreateWebAccessOptions(w, a.lan)),
+		widget.NewCard("Clipboard", "Shared clipboard", a.chatController.ClipboardSync().CreateOptionsContent(w)),
+	))
 }
 
 // TODO: add more widgets
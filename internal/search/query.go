@@ -0,0 +1,177 @@
+// Package search matches shared files by user queries.
+package search
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/0x0FACED/rapid/internal/model"
+)
+
+// Query is a list of terms separated by spaces, file must match all of them:
+//
+//	report          name contains "report", case-insensitive
+//	*.tar.gz        glob over the name, * ? and [] are supported
+//	/^img_\d+/      regular expression over the name, case-insensitive
+//	ext:jpg,png     extension is one of the list
+//	size:>10MB      size range, also <, >=, <=, 1MB..5MB or exact size
+type Query struct {
+	text    []string
+	globs   []string
+	regexps []*regexp.Regexp
+	exts    []string
+
+	sized   bool
+	minSize int64
+	maxSize int64
+}
+
+func Parse(query string) (Query, error) {
+	var filter Query
+
+	for _, term := range strings.Fields(query) {
+		lower := strings.ToLower(term)
+
+		switch {
+		case strings.HasPrefix(lower, "ext:"):
+			for _, ext := range strings.Split(lower[len("ext:"):], ",") {
+				if ext = strings.TrimPrefix(ext, "."); ext != "" {
+					filter.exts = append(filter.exts, ext)
+				}
+			}
+		case strings.HasPrefix(lower, "size:"):
+			if err := filter.parseSizeRange(lower[len("size:"):]); err != nil {
+				return Query{}, err
+			}
+		case len(term) > 2 && strings.HasPrefix(term, "/") && strings.HasSuffix(term, "/"):
+			re, err := regexp.Compile("(?i)" + term[1:len(term)-1])
+			if err != nil {
+				return Query{}, fmt.Errorf("invalid regexp %s: %w", term, err)
+			}
+			filter.regexps = append(filter.regexps, re)
+		case strings.ContainsAny(term, "*?["):
+			if _, err := path.Match(lower, ""); err != nil {
+				return Query{}, fmt.Errorf("invalid pattern %s: %w", term, err)
+			}
+			filter.globs = append(filter.globs, lower)
+		default:
+			filter.text = append(filter.text, lower)
+		}
+	}
+
+	return filter, nil
+}
+
+func (f *Query) parseSizeRange(value string) error {
+	lo, hi := int64(0), int64(math.MaxInt64)
+
+	var err error
+	switch {
+	case strings.Contains(value, ".."):
+		from, to, _ := strings.Cut(value, "..")
+		if from != "" {
+			if lo, err = parseSize(from); err != nil {
+				return err
+			}
+		}
+		if to != "" {
+			if hi, err = parseSize(to); err != nil {
+				return err
+			}
+		}
+	case strings.HasPrefix(value, ">="):
+		lo, err = parseSize(value[2:])
+	case strings.HasPrefix(value, "<="):
+		hi, err = parseSize(value[2:])
+	case strings.HasPrefix(value, ">"):
+		lo, err = parseSize(value[1:])
+		lo++
+	case strings.HasPrefix(value, "<"):
+		hi, err = parseSize(value[1:])
+		hi--
+	default:
+		lo, err = parseSize(value)
+		hi = lo
+	}
+	if err != nil {
+		return err
+	}
+
+	// several size terms narrow the range
+	if !f.sized {
+		f.sized, f.minSize, f.maxSize = true, lo, hi
+		return nil
+	}
+	f.minSize = max(f.minSize, lo)
+	f.maxSize = min(f.maxSize, hi)
+	return nil
+}
+
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30}, {"tib", 1 << 40},
+	{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30}, {"tb", 1 << 40},
+	{"k", 1 << 10}, {"m", 1 << 20}, {"g", 1 << 30}, {"t", 1 << 40},
+	{"b", 1},
+}
+
+// parseSize parses sizes like 512, 10kb or 1.5GiB, units are 1024-based
+func parseSize(value string) (int64, error) {
+	number, unit := value, int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			number, unit = strings.TrimSuffix(value, u.suffix), u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(n) || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	// conversion of values out of int64 range is undefined
+	size := n * float64(unit)
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", value)
+	}
+	return int64(size), nil
+}
+
+// Match reports whether the file matches all terms of the query
+func (f Query) Match(file model.File) bool {
+	name := strings.ToLower(file.Name)
+
+	for _, text := range f.text {
+		if !strings.Contains(name, text) {
+			return false
+		}
+	}
+	for _, glob := range f.globs {
+		if ok, _ := path.Match(glob, name); !ok {
+			return false
+		}
+	}
+	for _, re := range f.regexps {
+		if !re.MatchString(file.Name) {
+			return false
+		}
+	}
+	// suffix instead of filepath.Ext, so ext:tar.gz works
+	if len(f.exts) > 0 && !slices.ContainsFunc(f.exts, func(ext string) bool {
+		return strings.HasSuffix(name, "."+ext)
+	}) {
+		return false
+	}
+	if f.sized && (file.Size < f.minSize || file.Size > f.maxSize) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"math"
+	"testing"
+
+	"github.com/0x0FACED/rapid/internal/model"
+)
+
+func TestMatch(t *testing.T) {
+	const mb = 1 << 20
+
+	files := map[string]model.File{
+		"report":  {Name: "Annual Report 2024.pdf", Size: 3 * mb},
+		"archive": {Name: "backup.tar.gz", Size: 700 * mb},
+		"photo":   {Name: "IMG_0042.JPG", Size: 4 * mb},
+		"empty":   {Name: "notes.txt", Size: 0},
+		"noext":   {Name: "Makefile", Size: 512},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"report", "archive", "photo", "empty", "noext"}},
+		{"report", []string{"report"}},
+		{"REPORT 2024", []string{"report"}},
+		{"report 2023", nil},
+		{"*.tar.gz", []string{"archive"}},
+		{"img_????.jpg", []string{"photo"}},
+		{"[mn]*", []string{"empty", "noext"}},
+		{`/^img_\d+\./`, []string{"photo"}},
+		{"/^backup$/", nil},
+		{"ext:jpg", []string{"photo"}},
+		{"ext:.PDF,txt", []string{"report", "empty"}},
+		{"ext:gz", []string{"archive"}},
+		{"ext:tar.gz", []string{"archive"}},
+		{"ext:ar.gz", nil},
+		{"ext:", []string{"report", "archive", "photo", "empty", "noext"}},
+		{"size:>4mb", []string{"archive"}},
+		{"size:>=4mb", []string{"archive", "photo"}},
+		{"size:<512", []string{"empty"}},
+		{"size:<=512", []string{"empty", "noext"}},
+		{"size:512b", []string{"noext"}},
+		{"size:0", []string{"empty"}},
+		{"size:1mb..5MiB", []string{"report", "photo"}},
+		{"size:..1k", []string{"empty", "noext"}},
+		{"size:100m..", []string{"archive"}},
+		{"size:1.5k..3.5m", []string{"report"}},
+		{"size:>1mb size:<100mb", []string{"report", "photo"}},
+		{"size:<0", nil},
+		{"size:<999999tb", []string{"report", "archive", "photo", "empty", "noext"}},
+		{"ext:jpg,pdf size:<3.5mb", []string{"report"}},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+
+		want := make(map[string]bool)
+		for _, name := range tt.want {
+			want[name] = true
+		}
+		for name, file := range files {
+			if got := q.Match(file); got != want[name] {
+				t.Errorf("%q matches %s = %v, want %v", tt.query, file.Name, got, want[name])
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"/[a/",
+		"[a",
+		"size:",
+		"size:abc",
+		"size:-1",
+		"size:1xb",
+		"size:>",
+		"size:1mb..x",
+		"size:nan",
+		"size:inf",
+		"size:>inf",
+		"size:9999999tb",
+		"size:8589934592gb",
+		"size:1e30",
+	}
+	for _, query := range tests {
+		if _, err := Parse(query); err == nil {
+			t.Errorf("Parse(%q) has no error", query)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"10kb", 10 << 10},
+		{"1.5gib", 3 << 29},
+		{"2t", 2 << 40},
+		{"8388607tb", 8388607 << 40},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+
+	// the largest size is still a valid upper bound
+	q, err := Parse("size:>8388607tb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.minSize <= 0 || q.maxSize != math.MaxInt64 {
+		t.Errorf("range %d..%d", q.minSize, q.maxSize)
+	}
+}
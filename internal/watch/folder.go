@@ -0,0 +1,195 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is quiet period after the last write before
+// the file is reported, files still being written keep resetting it
+const DefaultDebounce = 2 * time.Second
+
+var ErrNotDirectory = errors.New("not a directory")
+
+type Op int
+
+const (
+	// Changed is reported for new and modified files
+	Changed Op = iota + 1
+	Removed
+)
+
+type Event struct {
+	Op   Op
+	Path string
+}
+
+// Folder reports changes of regular files in one directory,
+// subdirectories and hidden files are ignored
+type Folder struct {
+	dir      string
+	debounce time.Duration
+	watcher  *fsnotify.Watcher
+	events   chan Event
+
+	timers map[string]*time.Timer
+	mu     sync.Mutex
+}
+
+func New(dir string, debounce time.Duration) (*Folder, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, ErrNotDirectory
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return &Folder{
+		dir:      dir,
+		debounce: debounce,
+		watcher:  watcher,
+		events:   make(chan Event, 64),
+		timers:   make(map[string]*time.Timer),
+	}, nil
+}
+
+func (f *Folder) Dir() string {
+	return f.dir
+}
+
+// Events is closed when Run returns
+func (f *Folder) Events() <-chan Event {
+	return f.events
+}
+
+// Run reports files that are already in the folder and then
+// watches it until ctx is done
+func (f *Folder) Run(ctx context.Context) error {
+	defer f.stop()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !hidden(entry.Name()) {
+			f.emit(ctx, Event{Op: Changed, Path: filepath.Join(f.dir, entry.Name())})
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-f.watcher.Events:
+			if !ok {
+				return nil
+			}
+			f.handle(ctx, event)
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (f *Folder) handle(ctx context.Context, event fsnotify.Event) {
+	if hidden(filepath.Base(event.Name)) {
+		return
+	}
+
+	switch {
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		f.cancel(event.Name)
+		f.emit(ctx, Event{Op: Removed, Path: event.Name})
+	case event.Has(fsnotify.Create), event.Has(fsnotify.Write):
+		f.schedule(ctx, event.Name)
+	}
+}
+
+// schedule reports the file after debounce period without writes
+func (f *Folder) schedule(ctx context.Context, path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if timer, ok := f.timers[path]; ok {
+		timer.Reset(f.debounce)
+		return
+	}
+
+	f.timers[path] = time.AfterFunc(f.debounce, func() {
+		f.mu.Lock()
+		delete(f.timers, path)
+		f.mu.Unlock()
+
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			return
+		}
+		f.emit(ctx, Event{Op: Changed, Path: path})
+	})
+}
+
+func (f *Folder) cancel(path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if timer, ok := f.timers[path]; ok {
+		timer.Stop()
+		delete(f.timers, path)
+	}
+}
+
+func (f *Folder) emit(ctx context.Context, event Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.timers == nil {
+		return
+	}
+	select {
+	case f.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+func (f *Folder) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, timer := range f.timers {
+		timer.Stop()
+	}
+	// emit checks it to not send after close
+	f.timers = nil
+	f.watcher.Close()
+	close(f.events)
+}
+
+func hidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
@@ -0,0 +1,105 @@
+// Package cdc implements FastCDC content-defined chunking and
+// a content-addressed store of chunks.
+package cdc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// Chunk sizes, boundaries depend only on content, so an insertion
+// changes chunks around it and the rest of the file stays the same
+const (
+	MinSize = 16 * 1024
+	AvgSize = 64 * 1024
+	MaxSize = 256 * 1024
+)
+
+// normalized chunking: harder mask before AvgSize, easier after it
+const (
+	maskS uint64 = 0xffff_c000_0000_0000 // 18 bits
+	maskL uint64 = 0xfffc_0000_0000_0000 // 14 bits
+)
+
+var gear [256]uint64
+
+func init() {
+	// splitmix64, fixed seed keeps boundaries stable between versions
+	seed := uint64(0x726170696463_6463)
+	for i := range gear {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+// cut returns size of the first chunk of data
+func cut(data []byte) int {
+	n := len(data)
+	if n <= MinSize {
+		return n
+	}
+	n = min(n, MaxSize)
+	normal := min(n, AvgSize)
+
+	var fp uint64
+	i := MinSize
+	for ; i < normal; i++ {
+		fp = fp<<1 + gear[data[i]]
+		if fp&maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = fp<<1 + gear[data[i]]
+		if fp&maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// Hash returns content address of the chunk
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Split reads r and calls fn for every chunk, data is valid only during the call
+func Split(r io.Reader, fn func(offset int64, data []byte) error) error {
+	buf := make([]byte, 2*MaxSize)
+	start, end := 0, 0
+	var offset int64
+	eof := false
+
+	for {
+		if end-start < MaxSize && !eof {
+			end = copy(buf, buf[start:end])
+			start = 0
+
+			for end < len(buf) && !eof {
+				n, err := r.Read(buf[end:])
+				end += n
+				if errors.Is(err, io.EOF) {
+					eof = true
+				} else if err != nil {
+					return err
+				}
+			}
+		}
+		if start == end {
+			return nil
+		}
+
+		n := cut(buf[start:end])
+		if err := fn(offset, buf[start:start+n]); err != nil {
+			return err
+		}
+		start += n
+		offset += int64(n)
+	}
+}
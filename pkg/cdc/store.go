@@ -0,0 +1,113 @@
+package cdc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrInvalidHash   = errors.New("invalid chunk hash")
+	ErrChunkNotFound = errors.New("chunk not found")
+	ErrHashMismatch  = errors.New("chunk hash mismatch")
+)
+
+// Store keeps chunks as files named by their hash,
+// root/ab/abcdef... so directories stay small
+type Store struct {
+	root string
+}
+
+func NewStore(root string) *Store {
+	return &Store{root: root}
+}
+
+func (s *Store) path(hash string) (string, error) {
+	if len(hash) != 64 {
+		return "", ErrInvalidHash
+	}
+	for _, c := range hash {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return "", ErrInvalidHash
+		}
+	}
+	return filepath.Join(s.root, hash[:2], hash), nil
+}
+
+func (s *Store) Has(hash string) bool {
+	name, err := s.path(hash)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(name)
+	return err == nil
+}
+
+// Missing returns hashes that are not in the store, duplicates are removed
+func (s *Store) Missing(hashes []string) []string {
+	seen := make(map[string]bool, len(hashes))
+	var missing []string
+	for _, hash := range hashes {
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		if !s.Has(hash) {
+			missing = append(missing, hash)
+		}
+	}
+	return missing
+}
+
+// Put verifies data against hash and stores it
+func (s *Store) Put(hash string, data []byte) error {
+	name, err := s.path(hash)
+	if err != nil {
+		return err
+	}
+	if Hash(data) != hash {
+		return ErrHashMismatch
+	}
+	if s.Has(hash) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".chunk-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// WriteTo copies chunk to w
+func (s *Store) WriteTo(hash string, w io.Writer) (int64, error) {
+	name, err := s.path(hash)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("%w: %s", ErrChunkNotFound, hash)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return io.Copy(w, f)
+}
@@ -0,0 +1,237 @@
+// Package compression negotiates content encoding of transfers and
+// skips data that is already compressed.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type Encoding string
+
+const (
+	Identity Encoding = ""
+	Zstd     Encoding = "zstd"
+	Gzip     Encoding = "gzip"
+)
+
+// AcceptEncoding is sent by clients, encodings are in order of preference
+const AcceptEncoding = "zstd, gzip"
+
+var ErrUnsupported = errors.New("unsupported content encoding")
+
+// Parse parses Content-Encoding value
+func Parse(s string) (Encoding, error) {
+	switch enc := Encoding(strings.ToLower(strings.TrimSpace(s))); enc {
+	case Identity, "identity":
+		return Identity, nil
+	case Zstd, Gzip:
+		return enc, nil
+	default:
+		return Identity, ErrUnsupported
+	}
+}
+
+// Negotiate picks the best encoding allowed by Accept-Encoding value,
+// Identity if none of supported ones is accepted
+func Negotiate(accept string) Encoding {
+	best, bestQ := Identity, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		enc := Encoding(strings.ToLower(strings.TrimSpace(name)))
+		if enc != Zstd && enc != Gzip {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		// zstd wins ties, it is faster and compresses better
+		if q > bestQ || (q == bestQ && enc == Zstd) {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// NewWriter compresses everything written to w, Close flushes the stream
+// but doesn't close w
+func NewWriter(w io.Writer, enc Encoding) (io.WriteCloser, error) {
+	switch enc {
+	case Identity:
+		return nopCloser{w}, nil
+	case Zstd:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	case Gzip:
+		return gzip.NewWriterLevel(w, gzip.BestSpeed)
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// NewReader decompresses r
+func NewReader(r io.Reader, enc Encoding) (io.ReadCloser, error) {
+	switch enc {
+	case Identity:
+		return io.NopCloser(r), nil
+	case Zstd:
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// shared by Encode and Decode, EncodeAll and DecodeAll are safe for concurrent use
+var codec = sync.OnceValues(func() (*zstd.Encoder, *zstd.Decoder) {
+	enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	dec, _ := zstd.NewReader(nil, zstd.WithDecodeAllCapLimit(true))
+	return enc, dec
+})
+
+// Encode compresses single message
+func Encode(src []byte, enc Encoding) ([]byte, error) {
+	switch enc {
+	case Zstd:
+		encoder, _ := codec()
+		return encoder.EncodeAll(src, nil), nil
+	case Gzip:
+		var buf bytes.Buffer
+		w, _ := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+		if _, err := w.Write(src); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// Decode decompresses single message, result bigger than maxSize is an error
+func Decode(src []byte, enc Encoding, maxSize int) ([]byte, error) {
+	switch enc {
+	case Zstd:
+		_, decoder := codec()
+		return decoder.DecodeAll(src, make([]byte, 0, maxSize))
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxSize {
+			return nil, errors.New("decoded message is too big")
+		}
+		return data, nil
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// types that are compressed by their format, system mime tables often lack some of them
+var compressedExtensions = map[string]string{
+	".7z":   "application/x-7z-compressed",
+	".apk":  "application/vnd.android.package-archive",
+	".br":   "application/x-brotli",
+	".bz2":  "application/x-bzip2",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".epub": "application/epub+zip",
+	".jar":  "application/java-archive",
+	".lz4":  "application/x-lz4",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".rar":  "application/vnd.rar",
+	".tgz":  "application/gzip",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".xz":   "application/x-xz",
+	".zst":  "application/zstd",
+}
+
+// CompressedType reports whether data of the MIME type is already compressed
+func CompressedType(mimeType string) bool {
+	mimeType, _, _ = strings.Cut(strings.ToLower(mimeType), ";")
+	mimeType = strings.TrimSpace(mimeType)
+
+	switch mimeType {
+	case "image/svg+xml", "image/bmp", "image/x-ms-bmp", "image/tiff", "audio/wave", "audio/wav", "audio/x-wav":
+		return false
+	case "application/pdf", "application/zip", "application/gzip", "application/x-gzip",
+		"application/x-rar-compressed", "application/vnd.rar", "application/zstd",
+		"application/x-xz", "application/x-bzip2", "application/x-7z-compressed":
+		return true
+	}
+	for _, prefix := range []string{"image/", "video/", "audio/", "font/woff"} {
+		if strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	for _, known := range compressedExtensions {
+		if mimeType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Compressible guesses by file name and first bytes whether compression is worth it
+func Compressible(name string, head []byte) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	if _, ok := compressedExtensions[ext]; ok {
+		return false
+	}
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return !CompressedType(mimeType)
+	}
+	if len(head) == 0 {
+		return true
+	}
+	return !CompressedType(http.DetectContentType(head))
+}
+
+// CompressibleFile is Compressible for the file at path, name is
+// the shared name that may differ from the path
+func CompressibleFile(name, path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return Compressible(name, head[:n])
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,234 @@
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Delta stream is a sequence of instructions:
+//
+//	opCopy    uvarint block index
+//	opLiteral uvarint size, data
+//	opEnd     uvarint size of the new file, sha256 of the new file
+const (
+	opCopy byte = iota + 1
+	opLiteral
+	opEnd
+)
+
+// literals are flushed after maxLiteral bytes to bound memory
+const maxLiteral = 64 * 1024
+
+var (
+	ErrInvalidDelta     = errors.New("invalid delta")
+	ErrChecksumMismatch = errors.New("rebuilt file checksum mismatch")
+)
+
+// Stats describes how the new file was rebuilt
+type Stats struct {
+	// bytes taken from the basis file
+	Copied int64
+	// bytes sent in the delta
+	Literal int64
+}
+
+// Write reads the new version from r and writes delta against sig to w
+func Write(w io.Writer, sig *Signature, r io.Reader) (Stats, error) {
+	bw := bufio.NewWriter(w)
+	hash := sha256.New()
+	r = io.TeeReader(r, hash)
+
+	index := make(map[uint32][]int, len(sig.blocks))
+	for i, block := range sig.blocks {
+		index[block.weak] = append(index[block.weak], i)
+	}
+
+	var stats Stats
+	var header []byte
+	emitLiteral := func(data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		header = append(header[:0], opLiteral)
+		header = binary.AppendUvarint(header, uint64(len(data)))
+		_, _ = bw.Write(header)
+		_, _ = bw.Write(data)
+		stats.Literal += int64(len(data))
+	}
+	emitCopy := func(i int) {
+		header = append(header[:0], opCopy)
+		header = binary.AppendUvarint(header, uint64(i))
+		_, _ = bw.Write(header)
+		stats.Copied += int64(sig.blocks[i].size)
+	}
+
+	bs := sig.BlockSize
+	buf := make([]byte, 0, 2*bs+maxLiteral)
+	// buf[lit:pos] is pending literal, buf[pos:pos+bs] is current window
+	pos, lit := 0, 0
+	eof := false
+
+	fill := func() error {
+		n := copy(buf, buf[lit:])
+		buf = buf[:n]
+		pos -= lit
+		lit = 0
+
+		for len(buf) < cap(buf) && !eof {
+			n, err := r.Read(buf[len(buf):cap(buf)])
+			buf = buf[:len(buf)+n]
+			if errors.Is(err, io.EOF) {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var roll rolling
+	rolled := false
+	for {
+		if pos+bs > len(buf) && !eof {
+			if err := fill(); err != nil {
+				return stats, err
+			}
+		}
+		if pos >= len(buf) {
+			break
+		}
+
+		end := min(pos+bs, len(buf))
+		window := buf[pos:end]
+		if !rolled {
+			roll.reset(window)
+			rolled = true
+		}
+
+		if i, ok := sig.match(index, roll.sum(), window); ok {
+			emitLiteral(buf[lit:pos])
+			emitCopy(i)
+			pos = end
+			lit = pos
+			rolled = false
+			continue
+		}
+
+		// short window is the tail of the file, nothing else can match
+		if len(window) < bs {
+			pos = len(buf)
+			break
+		}
+
+		pos++
+		if pos+bs <= len(buf) {
+			roll.roll(buf[pos-1], buf[pos+bs-1])
+		} else {
+			rolled = false
+		}
+		if pos-lit >= maxLiteral {
+			emitLiteral(buf[lit:pos])
+			lit = pos
+		}
+	}
+	emitLiteral(buf[lit:pos])
+
+	header = append(header[:0], opEnd)
+	header = binary.AppendUvarint(header, uint64(stats.Copied+stats.Literal))
+	_, _ = bw.Write(header)
+	_, _ = bw.Write(hash.Sum(nil))
+
+	return stats, bw.Flush()
+}
+
+func (s *Signature) match(index map[uint32][]int, weak uint32, window []byte) (int, bool) {
+	candidates, ok := index[weak]
+	if !ok {
+		return 0, false
+	}
+
+	var strong [strongSize]byte
+	computed := false
+	for _, i := range candidates {
+		if s.blocks[i].size != len(window) {
+			continue
+		}
+		if !computed {
+			strong = strongSum(window)
+			computed = true
+		}
+		if s.blocks[i].strong == strong {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Apply rebuilds the new version from basis and delta, the result is
+// written to w and checked against checksum from the delta
+func Apply(w io.Writer, sig *Signature, basis io.ReaderAt, delta io.Reader) (Stats, error) {
+	br := bufio.NewReader(delta)
+	hash := sha256.New()
+	out := io.MultiWriter(w, hash)
+
+	var stats Stats
+	block := make([]byte, sig.BlockSize)
+	for {
+		op, err := br.ReadByte()
+		if err != nil {
+			return stats, fmt.Errorf("%w: %w", ErrInvalidDelta, err)
+		}
+
+		switch op {
+		case opCopy:
+			i, err := binary.ReadUvarint(br)
+			if err != nil {
+				return stats, fmt.Errorf("%w: %w", ErrInvalidDelta, err)
+			}
+			if i >= uint64(len(sig.blocks)) {
+				return stats, fmt.Errorf("%w: block %d out of range", ErrInvalidDelta, i)
+			}
+
+			size := sig.blocks[i].size
+			if _, err := basis.ReadAt(block[:size], int64(i)*int64(sig.BlockSize)); err != nil && !errors.Is(err, io.EOF) {
+				return stats, err
+			}
+			if _, err := out.Write(block[:size]); err != nil {
+				return stats, err
+			}
+			stats.Copied += int64(size)
+		case opLiteral:
+			size, err := binary.ReadUvarint(br)
+			if err != nil {
+				return stats, fmt.Errorf("%w: %w", ErrInvalidDelta, err)
+			}
+			if size > maxLiteral {
+				return stats, fmt.Errorf("%w: literal of %d bytes", ErrInvalidDelta, size)
+			}
+			if _, err := io.CopyN(out, br, int64(size)); err != nil {
+				return stats, fmt.Errorf("%w: %w", ErrInvalidDelta, err)
+			}
+			stats.Literal += int64(size)
+		case opEnd:
+			size, err := binary.ReadUvarint(br)
+			if err != nil {
+				return stats, fmt.Errorf("%w: %w", ErrInvalidDelta, err)
+			}
+			sum := make([]byte, sha256.Size)
+			if _, err := io.ReadFull(br, sum); err != nil {
+				return stats, fmt.Errorf("%w: %w", ErrInvalidDelta, err)
+			}
+			if size != uint64(stats.Copied+stats.Literal) || !bytes.Equal(sum, hash.Sum(nil)) {
+				return stats, ErrChecksumMismatch
+			}
+			return stats, nil
+		default:
+			return stats, fmt.Errorf("%w: unknown op %d", ErrInvalidDelta, op)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package delta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// generate returns reproducible pseudo-random file content
+func generate(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// roundTrip sends signature and delta through their wire formats
+// and returns rebuilt file
+func roundTrip(t *testing.T, basis, target []byte) ([]byte, Stats) {
+	t.Helper()
+
+	sig, err := NewSignature(bytes.NewReader(basis), BlockSizeFor(int64(len(basis))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sigBuf bytes.Buffer
+	if err := sig.MarshalTo(&sigBuf); err != nil {
+		t.Fatal(err)
+	}
+	received, err := ReadSignature(&sigBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var delta bytes.Buffer
+	written, err := Write(&delta, received, bytes.NewReader(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	applied, err := Apply(&out, sig, bytes.NewReader(basis), &delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != applied {
+		t.Errorf("stats of Write %+v and Apply %+v differ", written, applied)
+	}
+	return out.Bytes(), applied
+}
+
+func TestWriteApply(t *testing.T) {
+	base := generate(1, 1<<20)
+	other := generate(2, 1<<20)
+	insert := generate(3, 1000)
+
+	tests := []struct {
+		name   string
+		basis  []byte
+		target []byte
+		// upper bound of literal bytes
+		maxLiteral int64
+	}{
+		{"identical", base, base, 0},
+		{"insert in the middle", base, concat(base[:500000], insert, base[500000:]), 3 * MaxBlockSize},
+		{"delete from the middle", base, concat(base[:300000], base[310000:]), 3 * MaxBlockSize},
+		{"prepend", base, concat(insert, base), 2 * MaxBlockSize},
+		{"append", base, concat(base, insert), 2 * MaxBlockSize},
+		{"truncate", base, base[:700001], MaxBlockSize},
+		{"unrelated", base, other, int64(len(other))},
+		{"empty basis", nil, base[:100000], 100000},
+		{"empty target", base, nil, 0},
+		{"smaller than block", base[:100], base[:150], 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rebuilt, stats := roundTrip(t, tt.basis, tt.target)
+			if !bytes.Equal(rebuilt, tt.target) {
+				t.Fatalf("rebuilt %d bytes differ from target of %d bytes", len(rebuilt), len(tt.target))
+			}
+			if stats.Copied+stats.Literal != int64(len(tt.target)) {
+				t.Errorf("stats %+v don't sum to %d", stats, len(tt.target))
+			}
+			if stats.Literal > tt.maxLiteral {
+				t.Errorf("%d literal bytes, want at most %d", stats.Literal, tt.maxLiteral)
+			}
+		})
+	}
+}
+
+func TestApplyDetectsWrongBasis(t *testing.T) {
+	basis := generate(1, 200000)
+	target := concat(basis[:100000], generate(3, 500), basis[100000:])
+
+	sig, err := NewSignature(bytes.NewReader(basis), BlockSizeFor(int64(len(basis))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delta bytes.Buffer
+	if _, err := Write(&delta, sig, bytes.NewReader(target)); err != nil {
+		t.Fatal(err)
+	}
+
+	// basis changed after signature was made
+	changed := bytes.Clone(basis)
+	changed[0] ^= 0xff
+	_, err = Apply(&bytes.Buffer{}, sig, bytes.NewReader(changed), &delta)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("error = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestReadSignatureErrors(t *testing.T) {
+	header := func(blockSize, count, lastSize uint64) []byte {
+		var b []byte
+		b = binary.AppendUvarint(b, blockSize)
+		b = binary.AppendUvarint(b, count)
+		return binary.AppendUvarint(b, lastSize)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"small block size", header(MinBlockSize-1, 1, 1)},
+		{"large block size", header(MaxBlockSize+1, 1, 1)},
+		{"too many blocks", header(MinBlockSize, maxBlocks+1, 1)},
+		{"zero last size", header(MinBlockSize, 1, 0)},
+		{"last size over block", header(MinBlockSize, 1, MinBlockSize+1)},
+		// the peer claims many blocks and sends none of them
+		{"truncated", header(MinBlockSize, maxBlocks, MinBlockSize)},
+	}
+	for _, tt := range tests {
+		if _, err := ReadSignature(bytes.NewReader(tt.data)); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: error = %v, want ErrInvalidSignature", tt.name, err)
+		}
+	}
+}
+
+func TestReadSignatureAllocatesReadBlocksOnly(t *testing.T) {
+	var data []byte
+	data = binary.AppendUvarint(data, MinBlockSize)
+	data = binary.AppendUvarint(data, maxBlocks)
+	data = binary.AppendUvarint(data, MinBlockSize)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		_, _ = ReadSignature(bytes.NewReader(data))
+	})
+	// header of 4M blocks must not allocate them before they arrive
+	if allocs > 20 {
+		t.Fatalf("%v allocations for empty signature", allocs)
+	}
+}
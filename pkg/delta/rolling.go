@@ -0,0 +1,33 @@
+package delta
+
+// rolling is rsync weak checksum of a window, it can be moved
+// by one byte without reading the whole window again
+type rolling struct {
+	a, b uint32
+	n    uint32
+}
+
+func (r *rolling) reset(window []byte) {
+	r.a, r.b = 0, 0
+	r.n = uint32(len(window))
+	for i, c := range window {
+		r.a += uint32(c)
+		r.b += (r.n - uint32(i)) * uint32(c)
+	}
+}
+
+// roll removes out from the start of the window and appends in
+func (r *rolling) roll(out, in byte) {
+	r.a += uint32(in) - uint32(out)
+	r.b += r.a - r.n*uint32(out)
+}
+
+func (r *rolling) sum() uint32 {
+	return r.a&0xffff | r.b<<16
+}
+
+func weakSum(block []byte) uint32 {
+	var r rolling
+	r.reset(block)
+	return r.sum()
+}
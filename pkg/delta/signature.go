@@ -0,0 +1,159 @@
+// Package delta implements rsync-style delta transfer: receiver describes
+// blocks of its old copy with a signature, sender replies with copy and
+// literal instructions that rebuild the new version from the old one.
+package delta
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	MinBlockSize = 2 * 1024
+	MaxBlockSize = 128 * 1024
+
+	strongSize = 16
+	// limits decoded signature, 4 GiB file with max blocks is ~650 KiB
+	maxBlocks = 1 << 22
+)
+
+var ErrInvalidSignature = errors.New("invalid delta signature")
+
+// BlockSizeFor returns block size for a file, square root of the
+// size balances signature size and matching granularity
+func BlockSizeFor(size int64) int {
+	bs := int(math.Sqrt(float64(size)))
+	return max(MinBlockSize, min(MaxBlockSize, bs))
+}
+
+type blockSum struct {
+	weak   uint32
+	strong [strongSize]byte
+	size   int
+}
+
+// Signature describes blocks of the basis file
+type Signature struct {
+	BlockSize int
+	blocks    []blockSum
+}
+
+// Blocks returns number of blocks in the signature
+func (s *Signature) Blocks() int {
+	return len(s.blocks)
+}
+
+func strongSum(block []byte) [strongSize]byte {
+	sum := sha256.Sum256(block)
+	var strong [strongSize]byte
+	copy(strong[:], sum[:strongSize])
+	return strong
+}
+
+// NewSignature reads basis and describes it block by block,
+// the last block may be shorter
+func NewSignature(basis io.Reader, blockSize int) (*Signature, error) {
+	if blockSize < MinBlockSize || blockSize > MaxBlockSize {
+		return nil, fmt.Errorf("%w: block size %d", ErrInvalidSignature, blockSize)
+	}
+
+	sig := &Signature{BlockSize: blockSize}
+	block := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(basis, block)
+		if n > 0 {
+			sig.blocks = append(sig.blocks, blockSum{
+				weak:   weakSum(block[:n]),
+				strong: strongSum(block[:n]),
+				size:   n,
+			})
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return sig, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// MarshalTo writes signature:
+//
+//	block size uvarint
+//	blocks     uvarint
+//	last size  uvarint, size of the last block
+//	blocks * (weak uint32, strong 16 bytes)
+func (s *Signature) MarshalTo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	lastSize := 0
+	if len(s.blocks) > 0 {
+		lastSize = s.blocks[len(s.blocks)-1].size
+	}
+
+	var header []byte
+	header = binary.AppendUvarint(header, uint64(s.BlockSize))
+	header = binary.AppendUvarint(header, uint64(len(s.blocks)))
+	header = binary.AppendUvarint(header, uint64(lastSize))
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+
+	var weak [4]byte
+	for _, block := range s.blocks {
+		binary.BigEndian.PutUint32(weak[:], block.weak)
+		_, _ = bw.Write(weak[:])
+		_, _ = bw.Write(block.strong[:])
+	}
+	return bw.Flush()
+}
+
+// ReadSignature reads signature written by MarshalTo
+func ReadSignature(r io.Reader) (*Signature, error) {
+	br := bufio.NewReader(r)
+
+	var header [3]uint64
+	for i := range header {
+		v, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+		}
+		header[i] = v
+	}
+	blockSize, count, lastSize := header[0], header[1], header[2]
+
+	if blockSize < MinBlockSize || blockSize > MaxBlockSize || count > maxBlocks {
+		return nil, ErrInvalidSignature
+	}
+	if count > 0 && (lastSize == 0 || lastSize > blockSize) {
+		return nil, ErrInvalidSignature
+	}
+
+	// count comes from the peer, blocks are allocated as they are read
+	sig := &Signature{
+		BlockSize: int(blockSize),
+		blocks:    make([]blockSum, 0, min(count, 1024)),
+	}
+	var weak [4]byte
+	for range count {
+		var block blockSum
+		if _, err := io.ReadFull(br, weak[:]); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+		}
+		if _, err := io.ReadFull(br, block.strong[:]); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+		}
+		block.weak = binary.BigEndian.Uint32(weak[:])
+		block.size = int(blockSize)
+		sig.blocks = append(sig.blocks, block)
+	}
+	if count > 0 {
+		sig.blocks[count-1].size = int(lastSize)
+	}
+	return sig, nil
+}
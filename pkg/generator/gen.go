@@ -1,6 +1,8 @@
 package generator
 
 import (
+	crand "crypto/rand"
+	"math/big"
 	"math/rand"
 	"strconv"
 	"strings"
@@ -59,3 +61,26 @@ func GenerateName() (string, error) {
 
 	return builder.String(), nil
 }
+
+// without similar looking characters
+const codeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// GenerateCode returns short room code like "k7m-q2x".
+// crypto/rand is used because the code is known only to both peers.
+func GenerateCode() (string, error) {
+	var builder strings.Builder
+
+	for i := 0; i < 6; i++ {
+		if i == 3 {
+			builder.WriteByte('-')
+		}
+
+		n, err := crand.Int(crand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		builder.WriteByte(codeAlphabet[n.Int64()])
+	}
+
+	return builder.String(), nil
+}
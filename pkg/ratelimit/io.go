@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+)
+
+// chunk of io that waits for tokens at once, small enough
+// to keep the stream smooth at low rates
+const ioChunk = 32 * 1024
+
+// WaitAll takes n tokens from every limiter, nil limiters are skipped
+func WaitAll(ctx context.Context, n int, limiters ...*Limiter) error {
+	for _, l := range limiters {
+		if err := l.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitFunc blocks until n bytes may be transferred
+type WaitFunc func(n int) error
+
+type reader struct {
+	r    io.Reader
+	wait WaitFunc
+}
+
+// NewReader limits reads from r by all limiters
+func NewReader(ctx context.Context, r io.Reader, limiters ...*Limiter) io.Reader {
+	return NewWaitReader(r, func(n int) error {
+		return WaitAll(ctx, n, limiters...)
+	})
+}
+
+// NewWaitReader calls wait after every read, so limiters
+// can be chosen anew for every chunk
+func NewWaitReader(r io.Reader, wait WaitFunc) io.Reader {
+	return &reader{r: r, wait: wait}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > ioChunk {
+		p = p[:ioChunk]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.wait(n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+type writer struct {
+	w    io.Writer
+	wait WaitFunc
+}
+
+// NewWriter limits writes to w by all limiters
+func NewWriter(ctx context.Context, w io.Writer, limiters ...*Limiter) io.Writer {
+	return NewWaitWriter(w, func(n int) error {
+		return WaitAll(ctx, n, limiters...)
+	})
+}
+
+// NewWaitWriter calls wait before every chunk of the write
+func NewWaitWriter(w io.Writer, wait WaitFunc) io.Writer {
+	return &writer{w: w, wait: wait}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p[:min(len(p), ioChunk)]
+		if err := w.wait(len(chunk)); err != nil {
+			return written, err
+		}
+
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,137 @@
+// Package ratelimit implements token bucket limiter for byte streams.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket where one token is one byte.
+// Rate 0 means unlimited. Limiter is safe for concurrent use
+// and rate can be changed at any time.
+type Limiter struct {
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	mu sync.Mutex
+}
+
+// burst is limited, so a long pause doesn't allow huge spike
+const burstDuration = 250 * time.Millisecond
+
+// minimal burst, so small rates still allow full network packets
+const minBurst = 64 * 1024
+
+func New(rate int64) *Limiter {
+	l := &Limiter{last: time.Now()}
+	l.SetRate(rate)
+	return l
+}
+
+// SetRate sets rate in bytes per second, 0 disables limiting
+func (l *Limiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if rate < 0 {
+		rate = 0
+	}
+
+	l.advance(time.Now())
+	l.rate = float64(rate)
+	l.burst = max(l.rate*burstDuration.Seconds(), minBurst)
+	l.tokens = min(l.tokens, l.burst)
+}
+
+func (l *Limiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.rate)
+}
+
+// Allow takes n tokens if they are available right now
+func (l *Limiter) Allow(n int) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate == 0 {
+		return true
+	}
+
+	l.advance(time.Now())
+	if l.tokens < float64(n) {
+		return false
+	}
+	l.tokens -= float64(n)
+	return true
+}
+
+// WaitN blocks until n tokens are taken. Requests bigger than burst
+// are split, so any n is allowed.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	for n > 0 {
+		delay, taken := l.reserve(n)
+		n -= taken
+
+		if delay <= 0 {
+			continue
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// reserve takes up to burst tokens, possibly going into debt,
+// and returns how long the caller must wait before using them
+func (l *Limiter) reserve(n int) (time.Duration, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate == 0 {
+		return 0, n
+	}
+
+	now := time.Now()
+	l.advance(now)
+
+	take := min(float64(n), l.burst)
+	l.tokens -= take
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	return delay, int(take)
+}
+
+func (l *Limiter) advance(now time.Time) {
+	elapsed := now.Sub(l.last).Seconds()
+	l.last = now
+	if elapsed <= 0 || l.rate == 0 {
+		return
+	}
+	l.tokens = min(l.tokens+elapsed*l.rate, l.burst)
+}
@@ -0,0 +1,237 @@
+// Package spake2 implements SPAKE2 (RFC 9382) over P-256 with key confirmation.
+package spake2
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+
+	"filippo.io/nistec"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+type Role int
+
+const (
+	// RoleA is the side that starts the exchange (offerer)
+	RoleA Role = iota
+	// RoleB is the responder (answerer)
+	RoleB
+)
+
+var (
+	ErrInvalidMessage      = errors.New("spake2: invalid peer message")
+	ErrConfirmationFailed  = errors.New("spake2: key confirmation failed")
+	ErrNotFinished         = errors.New("spake2: exchange not finished")
+	ErrAlreadyFinished     = errors.New("spake2: exchange already finished")
+	errInvalidConstantSeed = errors.New("spake2: invalid constant")
+)
+
+// M and N for P-256 from RFC 9382, section 6
+const (
+	seedM = "02886e2f97ace46e55ba9dd7242579f2993b64e16ef3dcab95afd497333d8fa12f"
+	seedN = "03d8bbd6c639c62937b04d997f38c3770719c629d7014d49a24b4f98baa1292b49"
+)
+
+var (
+	order, _ = new(big.Int).SetString("ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551", 16)
+
+	pointM = mustPoint(seedM)
+	pointN = mustPoint(seedN)
+)
+
+func mustPoint(s string) *nistec.P256Point {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(errInvalidConstantSeed)
+	}
+	p, err := nistec.NewP256Point().SetBytes(b)
+	if err != nil {
+		panic(errInvalidConstantSeed)
+	}
+	return p
+}
+
+// State is one side of a single SPAKE2 exchange
+type State struct {
+	role Role
+	idA  []byte
+	idB  []byte
+
+	w []byte // password scalar
+	x []byte // ephemeral secret scalar
+
+	msg []byte // our public share
+
+	confirmKey     []byte // key for our confirmation
+	peerConfirmKey []byte // key for peer confirmation
+	transcript     []byte
+	sharedKey      []byte
+}
+
+// New creates exchange state. idA and idB are identities of both sides,
+// they are mixed into the transcript, so both sides must pass the same values.
+func New(role Role, password, idA, idB []byte) (*State, error) {
+	// password is stretched with the identities as salt,
+	// so precomputation for one session doesn't help with another
+	salt := sha256.Sum256(appendLen(appendLen(nil, idA), idB))
+	w := scalar(argon2.IDKey(password, salt[:], 1, 64*1024, 4, 64))
+
+	x, err := randomScalar(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	X, err := nistec.NewP256Point().ScalarBaseMult(x)
+	if err != nil {
+		return nil, err
+	}
+
+	blind := pointM
+	if role == RoleB {
+		blind = pointN
+	}
+
+	wBlind, err := nistec.NewP256Point().ScalarMult(blind, w)
+	if err != nil {
+		return nil, err
+	}
+
+	share := nistec.NewP256Point().Add(X, wBlind)
+
+	return &State{
+		role: role,
+		idA:  idA,
+		idB:  idB,
+		w:    w,
+		x:    x,
+		msg:  share.Bytes(),
+	}, nil
+}
+
+// Message returns our share that must be sent to the peer
+func (s *State) Message() []byte {
+	return s.msg
+}
+
+// Finish processes peer share and returns our key confirmation message
+func (s *State) Finish(peerMsg []byte) ([]byte, error) {
+	if s.sharedKey != nil {
+		return nil, ErrAlreadyFinished
+	}
+
+	peer, err := nistec.NewP256Point().SetBytes(peerMsg)
+	if err != nil || isIdentity(peer) {
+		return nil, ErrInvalidMessage
+	}
+
+	blind := pointN
+	if s.role == RoleB {
+		blind = pointM
+	}
+
+	wBlind, err := nistec.NewP256Point().ScalarMult(blind, s.w)
+	if err != nil {
+		return nil, err
+	}
+	wBlind.Negate(wBlind)
+
+	unblinded := nistec.NewP256Point().Add(peer, wBlind)
+	K, err := nistec.NewP256Point().ScalarMult(unblinded, s.x)
+	if err != nil {
+		return nil, err
+	}
+	if isIdentity(K) {
+		return nil, ErrInvalidMessage
+	}
+
+	pA, pB := s.msg, peerMsg
+	if s.role == RoleB {
+		pA, pB = peerMsg, s.msg
+	}
+
+	var tt []byte
+	tt = appendLen(tt, s.idA)
+	tt = appendLen(tt, s.idB)
+	tt = appendLen(tt, pA)
+	tt = appendLen(tt, pB)
+	tt = appendLen(tt, K.Bytes())
+	tt = appendLen(tt, s.w)
+
+	hash := sha256.Sum256(tt)
+	ke, ka := hash[:16], hash[16:]
+
+	kc := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ka, nil, []byte("ConfirmationKeys")), kc); err != nil {
+		return nil, err
+	}
+	kcA, kcB := kc[:16], kc[16:]
+
+	s.transcript = tt
+	s.sharedKey = ke
+	s.confirmKey, s.peerConfirmKey = kcA, kcB
+	if s.role == RoleB {
+		s.confirmKey, s.peerConfirmKey = kcB, kcA
+	}
+
+	return mac(s.confirmKey, s.transcript), nil
+}
+
+// Verify checks peer key confirmation, after it succeeds Key can be used
+func (s *State) Verify(peerConfirm []byte) error {
+	if s.sharedKey == nil {
+		return ErrNotFinished
+	}
+	if !hmac.Equal(peerConfirm, mac(s.peerConfirmKey, s.transcript)) {
+		return ErrConfirmationFailed
+	}
+	return nil
+}
+
+// Key returns shared secret, only valid after successful Verify
+func (s *State) Key() []byte {
+	return s.sharedKey
+}
+
+// identity point is encoded as a single zero byte
+func isIdentity(p *nistec.P256Point) bool {
+	return len(p.Bytes()) == 1
+}
+
+func mac(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// appendLen appends 8-byte little endian length and data, as in RFC 9382
+func appendLen(dst, data []byte) []byte {
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(len(data)))
+	return append(dst, data...)
+}
+
+// scalar reduces wide input modulo group order
+func scalar(b []byte) []byte {
+	k := new(big.Int).SetBytes(b)
+	k.Mod(k, order)
+	return k.FillBytes(make([]byte, 32))
+}
+
+func randomScalar(r io.Reader) ([]byte, error) {
+	for {
+		b := make([]byte, 48)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		k := scalar(b)
+		if new(big.Int).SetBytes(k).Sign() != 0 {
+			return k, nil
+		}
+	}
+}
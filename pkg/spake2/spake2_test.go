@@ -0,0 +1,186 @@
+package spake2
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"filippo.io/nistec"
+)
+
+var (
+	idA = []byte("offerer fingerprint")
+	idB = []byte("answerer fingerprint")
+)
+
+func newState(t *testing.T, role Role, password string, idA, idB []byte) *State {
+	t.Helper()
+
+	s, err := New(role, []byte(password), idA, idB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// exchange runs the protocol and returns errors of both verifications
+func exchange(t *testing.T, a, b *State) (error, error) {
+	t.Helper()
+
+	confirmA, err := a.Finish(b.Message())
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmB, err := b.Finish(a.Message())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.Verify(confirmB), b.Verify(confirmA)
+}
+
+func TestMatchingPasswords(t *testing.T) {
+	a := newState(t, RoleA, "correct horse", idA, idB)
+	b := newState(t, RoleB, "correct horse", idA, idB)
+
+	errA, errB := exchange(t, a, b)
+	if errA != nil || errB != nil {
+		t.Fatalf("verification failed: %v, %v", errA, errB)
+	}
+	if len(a.Key()) != 16 || !bytes.Equal(a.Key(), b.Key()) {
+		t.Fatalf("keys differ: %x and %x", a.Key(), b.Key())
+	}
+
+	// every exchange has fresh shares and key
+	c := newState(t, RoleA, "correct horse", idA, idB)
+	if bytes.Equal(a.Message(), c.Message()) {
+		t.Error("shares of two exchanges are equal")
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *State
+	}{
+		{
+			"wrong password",
+			newState(t, RoleA, "correct horse", idA, idB),
+			newState(t, RoleB, "battery staple", idA, idB),
+		},
+		{
+			"another fingerprint",
+			newState(t, RoleA, "correct horse", idA, idB),
+			newState(t, RoleB, "correct horse", idA, []byte("attacker fingerprint")),
+		},
+		{
+			"swapped identities",
+			newState(t, RoleA, "correct horse", idA, idB),
+			newState(t, RoleB, "correct horse", idB, idA),
+		},
+		{
+			"same role",
+			newState(t, RoleA, "correct horse", idA, idB),
+			newState(t, RoleA, "correct horse", idA, idB),
+		},
+	}
+	for _, tt := range tests {
+		errA, errB := exchange(t, tt.a, tt.b)
+		if !errors.Is(errA, ErrConfirmationFailed) || !errors.Is(errB, ErrConfirmationFailed) {
+			t.Errorf("%s: errors = %v, %v, want ErrConfirmationFailed", tt.name, errA, errB)
+		}
+		if bytes.Equal(tt.a.Key(), tt.b.Key()) {
+			t.Errorf("%s: keys are equal", tt.name)
+		}
+	}
+}
+
+func TestInvalidMessages(t *testing.T) {
+	a := newState(t, RoleA, "correct horse", idA, idB)
+
+	// share that cancels blinding of the peer, K becomes identity
+	wN, err := nistec.NewP256Point().ScalarMult(pointN, a.w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// y with another parity doesn't match x
+	offCurve := bytes.Clone(a.Message())
+	offCurve[len(offCurve)-1] ^= 1
+
+	tests := map[string][]byte{
+		"empty":        nil,
+		"identity":     {0},
+		"zero point":   append([]byte{4}, make([]byte, 64)...),
+		"truncated":    a.Message()[:20],
+		"bad prefix":   append([]byte{5}, a.Message()[1:]...),
+		"too long":     append(bytes.Clone(a.Message()), 0),
+		"off curve":    offCurve,
+		"identity key": wN.Bytes(),
+	}
+	for name, msg := range tests {
+		if _, err := a.Finish(msg); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("%s: error = %v, want ErrInvalidMessage", name, err)
+		}
+	}
+}
+
+func TestStateOrder(t *testing.T) {
+	a := newState(t, RoleA, "correct horse", idA, idB)
+	b := newState(t, RoleB, "correct horse", idA, idB)
+
+	if err := a.Verify(nil); !errors.Is(err, ErrNotFinished) {
+		t.Errorf("Verify before Finish: error = %v, want ErrNotFinished", err)
+	}
+	if _, err := a.Finish(b.Message()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Finish(b.Message()); !errors.Is(err, ErrAlreadyFinished) {
+		t.Errorf("second Finish: error = %v, want ErrAlreadyFinished", err)
+	}
+	if err := a.Verify(make([]byte, 32)); !errors.Is(err, ErrConfirmationFailed) {
+		t.Errorf("forged confirmation: error = %v, want ErrConfirmationFailed", err)
+	}
+}
+
+// TestConstants derives M and N from their seeds with the algorithm
+// from RFC 9382, appendix A
+func TestConstants(t *testing.T) {
+	iterated := func(seed []byte, n int) []byte {
+		h := seed
+		for range n {
+			sum := sha256.Sum256(h)
+			h = sum[:]
+		}
+		return h
+	}
+
+	derive := func(seed string) string {
+		for i := 1; i < 1000; i++ {
+			b := append(iterated([]byte(seed), i), iterated([]byte(seed), i+1)...)[:33]
+			b[0] = b[0]&1 | 2
+			if _, err := nistec.NewP256Point().SetBytes(b); err == nil {
+				return hex.EncodeToString(b)
+			}
+		}
+		return ""
+	}
+
+	tests := []struct {
+		seed string
+		want string
+		got  *nistec.P256Point
+	}{
+		{"1.2.840.10045.3.1.7 point generation seed (M)", seedM, pointM},
+		{"1.2.840.10045.3.1.7 point generation seed (N)", seedN, pointN},
+	}
+	for _, tt := range tests {
+		if derived := derive(tt.seed); derived != tt.want {
+			t.Errorf("%s: derived %s, constant is %s", tt.seed, derived, tt.want)
+		}
+		if encoded := hex.EncodeToString(tt.got.BytesCompressed()); encoded != tt.want {
+			t.Errorf("%s: point is %s, want %s", tt.seed, encoded, tt.want)
+		}
+	}
+}
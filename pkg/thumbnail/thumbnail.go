@@ -0,0 +1,188 @@
+// Package thumbnail makes small previews of images and text files.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+type Kind string
+
+const (
+	KindImage Kind = "image"
+	KindText  Kind = "text"
+	// no preview, clients show generic icon
+	KindNone Kind = "none"
+)
+
+const (
+	// DefaultSize is the longest side of image previews in pixels
+	DefaultSize = 128
+	MaxSize     = 512
+	// text previews are the first lines of the file
+	MaxLines = 12
+	maxText  = 2048
+	// bigger images are not decoded, it would take too much memory:
+	// 16 Mpx is up to 64 MiB of decoded pixels
+	maxImageFile   = 64 * 1024 * 1024
+	maxImagePixels = 16 * 1024 * 1024
+	// images decoded at once, the rest wait for their turn
+	maxDecoding = 2
+)
+
+// Sizes are the only sizes of image previews, other sizes are snapped to
+// them, so callers can cache few previews per file
+var Sizes = []int{64, 128, 256, MaxSize}
+
+var decoding = make(chan struct{}, maxDecoding)
+
+var ErrTooBig = errors.New("file is too big for preview")
+
+type Thumbnail struct {
+	Kind        Kind
+	ContentType string
+	Data        []byte
+}
+
+// Snap returns the smallest of Sizes that is not less than size,
+// invalid sizes give DefaultSize
+func Snap(size int) int {
+	if size <= 0 || size > MaxSize {
+		return DefaultSize
+	}
+	for _, s := range Sizes {
+		if size <= s {
+			return s
+		}
+	}
+	return MaxSize
+}
+
+// Generate makes preview of the file at path, name is used to guess its type.
+// Size is snapped to Sizes. Unsupported types give KindNone without error.
+func Generate(name, path string, size int) (Thumbnail, error) {
+	size = Snap(size)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Thumbnail{}, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Thumbnail{}, err
+	}
+	head = head[:n]
+
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(head)
+	}
+
+	switch {
+	case strings.HasPrefix(mimeType, "image/") && mimeType != "image/svg+xml":
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return Thumbnail{}, err
+		}
+		return imageThumbnail(f, size)
+	case strings.HasPrefix(mimeType, "text/") || isText(head):
+		return textThumbnail(head, f)
+	default:
+		return Thumbnail{Kind: KindNone}, nil
+	}
+}
+
+func imageThumbnail(f *os.File, size int) (Thumbnail, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return Thumbnail{}, err
+	}
+	if info.Size() > maxImageFile {
+		return Thumbnail{}, ErrTooBig
+	}
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		// unknown image format
+		return Thumbnail{Kind: KindNone}, nil
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return Thumbnail{}, ErrTooBig
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Thumbnail{}, err
+	}
+
+	decoding <- struct{}{}
+	defer func() { <-decoding }()
+
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return Thumbnail{}, err
+	}
+
+	bounds := src.Bounds()
+	w, h := fit(bounds.Dx(), bounds.Dy(), size)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	// transparent parts become white, jpeg has no alpha
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return Thumbnail{}, err
+	}
+	return Thumbnail{Kind: KindImage, ContentType: "image/jpeg", Data: buf.Bytes()}, nil
+}
+
+// fit keeps aspect ratio, images smaller than size are not enlarged
+func fit(w, h, size int) (int, int) {
+	if w <= size && h <= size {
+		return max(w, 1), max(h, 1)
+	}
+	if w >= h {
+		return size, max(h*size/w, 1)
+	}
+	return max(w*size/h, 1), size
+}
+
+func textThumbnail(head []byte, r io.Reader) (Thumbnail, error) {
+	data := make([]byte, maxText)
+	n := copy(data, head)
+	m, err := io.ReadFull(r, data[n:])
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Thumbnail{}, err
+	}
+	data = data[:n+m]
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if len(lines) > MaxLines {
+		lines = lines[:MaxLines]
+	}
+	// multibyte rune may be cut by the size limit
+	text := strings.ToValidUTF8(strings.Join(lines, ""), "")
+
+	return Thumbnail{Kind: KindText, ContentType: "text/plain; charset=utf-8", Data: []byte(text)}, nil
+}
+
+// isText reports files without extension that look like utf-8 text
+func isText(head []byte) bool {
+	return len(head) > 0 && strings.HasPrefix(http.DetectContentType(head), "text/plain")
+}
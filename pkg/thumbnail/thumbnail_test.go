@@ -0,0 +1,80 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnap(t *testing.T) {
+	tests := map[int]int{
+		-1:          DefaultSize,
+		0:           DefaultSize,
+		1:           64,
+		64:          64,
+		65:          128,
+		200:         256,
+		MaxSize:     MaxSize,
+		MaxSize + 1: DefaultSize,
+	}
+	for in, want := range tests {
+		if got := Snap(in); got != want {
+			t.Errorf("Snap(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+// pngHeader returns png with header only, it's enough for image.DecodeConfig
+func pngHeader(width, height uint32) []byte {
+	var ihdr []byte
+	ihdr = append(ihdr, "IHDR"...)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	// 8-bit rgba, default compression, filter and interlace
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestImageLimits(t *testing.T) {
+	dir := t.TempDir()
+
+	huge := filepath.Join(dir, "huge.png")
+	if err := os.WriteFile(huge, pngHeader(8000, 6000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate("huge.png", huge, DefaultSize); !errors.Is(err, ErrTooBig) {
+		t.Fatalf("48 Mpx image: error = %v, want ErrTooBig", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 150))); err != nil {
+		t.Fatal(err)
+	}
+	small := filepath.Join(dir, "small.png")
+	if err := os.WriteFile(small, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 100 is snapped to 128
+	thumb, err := Generate("small.png", small, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(thumb.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.Kind != KindImage || config.Width != 128 || config.Height != 64 {
+		t.Errorf("preview is %s %dx%d, want image 128x64", thumb.Kind, config.Width, config.Height)
+	}
+}